@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"go.uber.org/zap"
 	"net/http"
+	"os"
+	"os/signal"
 	"shorturl/internal/app"
 	"shorturl/internal/config"
 	"shorturl/internal/logger"
+	"syscall"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		os.Exit(runTransfer(os.Args[1], os.Args[2:]))
+	}
+
 	cfg := config.Load()
 
 	logger.InitializeLogger(cfg)
@@ -25,8 +35,56 @@ func main() {
 		}()
 	}
 
-	logger.Logger.Info("Starting server", zap.String("address", cfg.ServerAddress), zap.String("baseURL", cfg.BaseURL))
-	if err := http.ListenAndServe(cfg.ServerAddress, application.Router); err != nil {
-		logger.Logger.Fatal("Failed to start server", zap.Error(err))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: cfg.ServerAddress, Handler: application.Router}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Logger.Info("Starting server", zap.String("address", cfg.ServerAddress), zap.String("baseURL", cfg.BaseURL))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Logger.Error("Failed to start server", zap.Error(err))
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	// Сначала сообщаем о неготовности, чтобы /readyz вернул 503 и балансировщик
+	// перестал направлять трафик, затем дожидаемся завершения активных запросов.
+	logger.Logger.Info("Shutting down server")
+	application.Health.SetShuttingDown()
+
+	// Пока балансировщик не заметил 503 на /readyz, трафик продолжает поступать,
+	// поэтому сервер работает еще ShutdownDrainDelay. Повторный сигнал или
+	// падение сервера прерывают ожидание.
+	if cfg.ShutdownDrainDelay > 0 {
+		stop()
+		drainCtx, cancelDrain := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		drain := time.NewTimer(cfg.ShutdownDrainDelay)
+		select {
+		case <-drain.C:
+		case <-drainCtx.Done():
+			drain.Stop()
+		case err := <-serverErr:
+			drain.Stop()
+			cancelDrain()
+			if !errors.Is(err, http.ErrServerClosed) {
+				logger.Logger.Error("Server failed during drain", zap.Error(err))
+			}
+			return
+		}
+		cancelDrain()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Logger.Error("Failed to shut down server gracefully", zap.Error(err))
 	}
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/storage"
+	"shorturl/internal/transfer"
+	"syscall"
+)
+
+// stdio — имя файла, означающее stdin/stdout.
+const stdio = "-"
+
+// runTransfer выполняет подкоманду export или import и возвращает код выхода.
+// Хранилище выбирается теми же флагами и переменными окружения, что и у сервера.
+func runTransfer(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	format := fs.String("format", string(transfer.FormatJSONL), "Transfer file format: jsonl or csv")
+	resume := fs.Bool("resume", false, "Resume an interrupted transfer from its checkpoint")
+	var path, conflict *string
+	if name == "export" {
+		path = fs.String("o", stdio, "Output file (- for stdout)")
+	} else {
+		path = fs.String("i", stdio, "Input file (- for stdin)")
+		conflict = fs.String("conflict", string(transfer.ConflictFail), "Conflict handling: skip, overwrite or fail")
+	}
+
+	cfg, err := config.LoadFlags(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	logger.InitializeLogger(cfg)
+
+	opts := transfer.Options{Resume: *resume, Progress: progressPrinter(name)}
+	if opts.Format, err = transfer.ParseFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if conflict != nil {
+		if opts.Conflict, err = transfer.ParseConflictMode(*conflict); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := transferStore(ctx, name, cfg, *path, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		if *path != stdio {
+			fmt.Fprintf(os.Stderr, "%s: rerun with -resume to continue\n", name)
+		}
+		return 1
+	}
+	return 0
+}
+
+// transferStore открывает хранилище и переносит записи между ним и path.
+func transferStore(ctx context.Context, name string, cfg *config.Config, path string, opts transfer.Options) (err error) {
+	backend := cfg.StorageBackend
+	if backend == "" {
+		backend = storage.DefaultBackend(cfg)
+	}
+	store, err := storage.Open(backend, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open %s storage: %w", backend, err)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer func() {
+			err = errors.Join(err, closer.Close())
+		}()
+	}
+
+	if name == "export" {
+		src, ok := store.(storage.Exporter)
+		if !ok {
+			return fmt.Errorf("storage %s does not support export", backend)
+		}
+		if path == stdio {
+			_, err = transfer.Export(ctx, src, os.Stdout, opts)
+		} else {
+			_, err = transfer.ExportFile(ctx, src, path, opts)
+		}
+		return err
+	}
+
+	dst, ok := store.(storage.Importer)
+	if !ok {
+		return fmt.Errorf("storage %s does not support import", backend)
+	}
+	if path == stdio {
+		_, err = transfer.Import(ctx, dst, os.Stdin, opts)
+	} else {
+		_, err = transfer.ImportFile(ctx, dst, path, opts)
+	}
+	return err
+}
+
+// progressPrinter печатает прогресс в stderr, не мешая выгрузке в stdout.
+func progressPrinter(name string) func(transfer.Stats) {
+	return func(s transfer.Stats) {
+		if name == "export" {
+			fmt.Fprintf(os.Stderr, "export: %d records\n", s.Records)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "import: %d records (%d imported, %d skipped)\n", s.Records, s.Imported, s.Skipped)
+	}
+}
@@ -0,0 +1,119 @@
+// Package apierror реализует единый формат ошибок HTTP API
+// в соответствии с RFC 7807 (application/problem+json).
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"shorturl/internal/logger"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// ContentType — медиатип ответов с ошибками.
+const ContentType = "application/problem+json"
+
+// typePrefix — префикс URI поля type; к нему добавляется стабильный код ошибки.
+const typePrefix = "urn:shorturl:error:"
+
+// Стабильные коды ошибок. Клиенты могут полагаться на них при обработке ответов.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeInvalidURL           = "invalid_url"
+	CodeValidation           = "validation_failed"
+	CodeRequired             = "required"
+	CodeInvalidType          = "invalid_type"
+	CodeInvalidValue         = "invalid_value"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeBatchTooLarge        = "batch_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnauthorized         = "unauthorized"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeGone                 = "gone"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeTimeout              = "timeout"
+	CodeUnavailable          = "service_unavailable"
+	CodeInternal             = "internal_error"
+)
+
+// FieldError описывает ошибку конкретного поля запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem описывает тело ответа с ошибкой (RFC 7807) с расширениями
+// code, request_id и errors.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// New создает Problem с заданным статусом, кодом и описанием.
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WithField добавляет к Problem ошибку поля запроса.
+func (p *Problem) WithField(field, code, message string) *Problem {
+	p.Errors = append(p.Errors, FieldError{Field: field, Code: code, Message: message})
+	return p
+}
+
+// Error реализует интерфейс error.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Code + ": " + p.Detail
+	}
+	return p.Code
+}
+
+// WriteProblem отправляет клиенту Problem, дополняя его идентификатором
+// запроса из chiMiddleware.RequestID и путем запроса.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if r != nil {
+		if p.Instance == "" {
+			p.Instance = r.URL.Path
+		}
+		if p.RequestID == "" {
+			p.RequestID = chiMiddleware.GetReqID(r.Context())
+		}
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		logger.Logger.Error("Error writing problem response", zap.Error(err))
+	}
+}
+
+// Write отправляет клиенту ошибку с заданным статусом, кодом и описанием.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	WriteProblem(w, r, New(status, code, detail))
+}
+
+// NotFound — обработчик для неизвестных маршрутов.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusNotFound, CodeNotFound, "Resource not found")
+}
+
+// MethodNotAllowed — обработчик для неподдерживаемых методов.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
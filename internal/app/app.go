@@ -1,22 +1,35 @@
 package app
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"go.uber.org/zap"
 	"io"
 	"math/rand"
 	"net/http"
+	"os"
+	"shorturl/internal/cache"
 	"shorturl/internal/config"
 	"shorturl/internal/handlers"
+	"shorturl/internal/health"
+	"shorturl/internal/linkcheck"
 	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
+	"shorturl/internal/preview"
 	"shorturl/internal/router"
 	"shorturl/internal/service"
 	"shorturl/internal/storage"
+	"shorturl/internal/tracing"
+	"strings"
 	"time"
 )
 
 type App struct {
 	Router http.Handler
 	Closer io.Closer
+	Health *health.Checker
 }
 
 func New(cfg *config.Config) (*App, error) {
@@ -30,42 +43,217 @@ func New(cfg *config.Config) (*App, error) {
 		zap.String("LogLevel", cfg.LogLevel),
 		zap.String("LogFormat", cfg.LogFormat),
 		zap.String("DatabaseDSN", cfg.DatabaseDSN),
+		zap.String("StorageBackend", cfg.StorageBackend),
+		zap.Bool("StorageFallback", cfg.StorageFallback),
+		zap.Int64("MaxBodyBytes", cfg.MaxBodyBytes),
+		zap.Int("MaxBatchSize", cfg.MaxBatchSize),
+		zap.String("TraceExporter", cfg.TraceExporter),
+		zap.String("OTLPEndpoint", cfg.OTLPEndpoint),
+		zap.String("ServiceName", cfg.ServiceName),
+		zap.Int64("MinFreeDiskBytes", cfg.MinFreeDiskBytes),
+		zap.Duration("ShutdownTimeout", cfg.ShutdownTimeout),
+		zap.Duration("ShutdownDrainDelay", cfg.ShutdownDrainDelay),
+		zap.String("FileDurability", cfg.FileDurability),
+		zap.Duration("FileSyncInterval", cfg.FileSyncInterval),
+		zap.Int64("FileCompactMaxBytes", cfg.FileCompactMaxBytes),
+		zap.Float64("FileCompactRatio", cfg.FileCompactRatio),
+		zap.Duration("FileCompactInterval", cfg.FileCompactInterval),
+		zap.String("MemorySnapshotPath", cfg.MemorySnapshotPath),
+		zap.Duration("MemorySnapshotInterval", cfg.MemorySnapshotInterval),
+		zap.String("CacheBackend", cfg.CacheBackend),
+		zap.Int("CacheSize", cfg.CacheSize),
+		zap.Duration("CacheTTL", cfg.CacheTTL),
+		zap.Duration("CacheNegativeTTL", cfg.CacheNegativeTTL),
+		zap.String("RedisURL", config.RedactURL(cfg.RedisURL)),
+		zap.Int("PreviewWorkers", cfg.PreviewWorkers),
+		zap.Duration("PreviewTimeout", cfg.PreviewTimeout),
+		zap.Int64("PreviewMaxBytes", cfg.PreviewMaxBytes),
+		zap.Duration("LinkCheckInterval", cfg.LinkCheckInterval),
+		zap.Int("LinkCheckWorkers", cfg.LinkCheckWorkers),
+		zap.Duration("LinkCheckTimeout", cfg.LinkCheckTimeout),
+		zap.Duration("LinkCheckHostDelay", cfg.LinkCheckHostDelay),
+		zap.Duration("UnlockTTL", cfg.UnlockTTL),
+		zap.Int("PasswordAttempts", cfg.PasswordAttempts),
+		zap.Int("PasswordLinkAttempts", cfg.PasswordLinkAttempts),
+		zap.Duration("PasswordAttemptWindow", cfg.PasswordAttemptWindow),
+		zap.Bool("ComingSoon", cfg.ComingSoon),
 	)
 
-	var store service.ShortURLCreatorGetter
 	var pinger service.Pinger
-	var closer io.Closer
+	var closers multiCloser
+	checker := health.NewChecker()
 
-	if cfg.DatabaseDSN != "" {
-		dbStorage, err := storage.NewDatabaseStorage(cfg.DatabaseDSN)
-		if err == nil {
-			store = dbStorage
-			pinger = dbStorage
-			closer = dbStorage
-			logger.Logger.Info("Using PostgreSQL database storage")
+	provider, err := newTracerProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if provider != nil {
+		tracing.SetProvider(provider)
+		closers = append(closers, provider)
+		checker.Register(health.QueueCheck("trace_export_queue", provider.QueueLen, provider.QueueCap))
+	}
+
+	opened, backend, err := openStorage(cfg)
+	if err != nil {
+		return nil, errors.Join(err, closers.Close())
+	}
+	if c, ok := opened.(io.Closer); ok {
+		closers = append(closers, c)
+	}
+	store, ok := opened.(service.ShortURLCreatorGetter)
+	if !ok {
+		err := fmt.Errorf("storage backend %q does not implement the service storage interface", backend)
+		return nil, errors.Join(err, closers.Close())
+	}
+	if p, ok := opened.(service.Pinger); ok {
+		pinger = p
+		checker.Register(health.PingCheck("database", p))
+	}
+	if s, ok := opened.(interface{ Stats() sql.DBStats }); ok {
+		metrics.RegisterDBStats(s.Stats)
+	}
+	if f, ok := opened.(interface{ FilePath() string }); ok {
+		checker.Register(health.FileCheck(f.FilePath(), uint64(cfg.MinFreeDiskBytes)))
+	}
+	logger.Logger.Info("Using storage backend", zap.String("backend", backend))
+
+	store = metrics.InstrumentStorage(store, backend)
+	c, err := newCache(cfg)
+	if err != nil {
+		return nil, errors.Join(err, closers.Close())
+	}
+	if c != nil {
+		if closer, ok := c.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+		store = cache.NewStorage(store, c, cfg.CacheBackend, cfg.CacheTTL, cfg.CacheNegativeTTL)
+		logger.Logger.Info("Redirect cache enabled", zap.String("backend", cfg.CacheBackend))
+	}
+
+	if cfg.PreviewWorkers > 0 {
+		fetcher := preview.NewFetcher(store,
+			preview.WithWorkers(cfg.PreviewWorkers),
+			preview.WithTimeout(cfg.PreviewTimeout),
+			preview.WithMaxBodyBytes(cfg.PreviewMaxBytes))
+		closers = append(closers, fetcher)
+		checker.Register(health.QueueCheck("preview_fetch_queue", fetcher.QueueLen, fetcher.QueueCap))
+		metrics.NewGaugeFunc("preview_queue_depth", "Number of links waiting for a preview fetch.",
+			func() float64 { return float64(fetcher.QueueLen()) })
+		store = preview.NewStorage(store, fetcher)
+		logger.Logger.Info("Link preview fetcher enabled", zap.Int("workers", cfg.PreviewWorkers))
+	}
+
+	if cfg.LinkCheckInterval > 0 {
+		// Ссылки читаются из бэкенда напрямую, а результаты пишутся через
+		// обертки, чтобы кэш переходов сбрасывался.
+		if source, ok := opened.(storage.Exporter); ok {
+			linkChecker := linkcheck.NewChecker(source, store,
+				linkcheck.WithInterval(cfg.LinkCheckInterval),
+				linkcheck.WithWorkers(cfg.LinkCheckWorkers),
+				linkcheck.WithTimeout(cfg.LinkCheckTimeout),
+				linkcheck.WithHostDelay(cfg.LinkCheckHostDelay))
+			linkChecker.Start()
+			closers = append(closers, linkChecker)
+			logger.Logger.Info("Link health checker enabled", zap.Duration("interval", cfg.LinkCheckInterval))
 		} else {
-			logger.Logger.Error("Failed to initialize database storage, falling back to file or memory", zap.Error(err))
+			logger.Logger.Warn("Storage backend cannot list links, link health checker disabled", zap.String("backend", backend))
+		}
+	}
+
+	svc := service.NewURLService(store, pinger)
+	h := handlers.NewHandlers(svc)
+	r := router.New(h, cfg, checker)
+
+	return &App{Router: r, Closer: closers, Health: checker}, nil
+}
+
+// openStorage открывает бэкенд хранилища, выбранный в cfg. По умолчанию ошибка
+// открытия фатальна; переход на следующий бэкенд цепочки выполняется только
+// при включенном cfg.StorageFallback.
+func openStorage(cfg *config.Config) (storage.Store, string, error) {
+	backend := cfg.StorageBackend
+	if backend == "" {
+		backend = storage.DefaultBackend(cfg)
+	}
+
+	store, err := storage.Open(backend, cfg)
+	if err == nil || !cfg.StorageFallback || errors.Is(err, storage.ErrUnknownBackend) {
+		return store, backend, err
+	}
+
+	next := len(storage.FallbackChain)
+	for i, name := range storage.FallbackChain {
+		if name == backend {
+			next = i + 1
+			break
+		}
+	}
+	for _, name := range storage.FallbackChain[next:] {
+		logger.Logger.Error("Failed to initialize storage, falling back",
+			zap.String("backend", backend), zap.String("fallback", name), zap.Error(err))
+		backend = name
+		store, err = storage.Open(backend, cfg)
+		if err == nil {
+			return store, backend, nil
 		}
 	}
+	return nil, backend, err
+}
 
-	if store == nil && cfg.FileStoragePath != "" {
-		fileStorage, err := storage.NewFileStorage(cfg.FileStoragePath)
+// newCache создает кэш редиректов по настройкам cfg. Для CACHE_BACKEND=none
+// возвращает nil. Недоступный при старте Redis — ошибка конфигурации.
+func newCache(cfg *config.Config) (cache.Cache, error) {
+	switch strings.ToLower(cfg.CacheBackend) {
+	case "", cache.BackendNone:
+		return nil, nil
+	case cache.BackendMemory:
+		lru := cache.NewLRU(cfg.CacheSize)
+		metrics.NewGaugeFunc("cache_entries", "Number of entries in the in-process cache.",
+			func() float64 { return float64(lru.Len()) })
+		return lru, nil
+	case cache.BackendRedis:
+		r, err := cache.NewRedis(cfg.RedisURL)
 		if err != nil {
 			return nil, err
 		}
-		store = fileStorage
-		logger.Logger.Info("Using file storage")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.PingContext(ctx); err != nil {
+			return nil, errors.Join(fmt.Errorf("redis cache is unavailable: %w", err), r.Close())
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q (expected none, memory or redis)", cfg.CacheBackend)
 	}
+}
 
-	if store == nil {
-		memStorage := storage.NewInMemoryStorage()
-		store = memStorage
-		logger.Logger.Info("Using only in-memory storage")
+// newTracerProvider создает провайдер трассировки по настройкам cfg.
+// Для TRACE_EXPORTER=none возвращает nil: спаны создаются, но не экспортируются.
+func newTracerProvider(cfg *config.Config) (*tracing.Provider, error) {
+	var exporter tracing.Exporter
+	switch strings.ToLower(cfg.TraceExporter) {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		exporter = tracing.NewStdoutExporter(os.Stdout)
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(cfg.OTLPEndpoint, cfg.ServiceName)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (expected none, stdout or otlp)", cfg.TraceExporter)
 	}
+	logger.Logger.Info("Tracing enabled", zap.String("exporter", cfg.TraceExporter))
+	return tracing.NewProvider(exporter, logger.Logger), nil
+}
 
-	svc := service.NewURLService(store, pinger)
-	h := handlers.NewHandlers(svc)
-	r := router.New(h, cfg)
+// multiCloser закрывает ресурсы в обратном порядке открытия и объединяет ошибки.
+type multiCloser []io.Closer
 
-	return &App{Router: r, Closer: closer}, nil
+func (m multiCloser) Close() error {
+	var errs []error
+	for i := len(m) - 1; i >= 0; i-- {
+		if err := m[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
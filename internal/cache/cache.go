@@ -0,0 +1,23 @@
+// Package cache содержит кэши для горячего пути редиректа: in-process LRU с TTL
+// и клиент Redis-совместимого сервера, а также кэширующий декоратор хранилища.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Имена бэкендов кэша в CACHE_BACKEND.
+const (
+	BackendNone   = "none"
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Cache — хранилище строковых значений с ограниченным временем жизни.
+// Отсутствие ключа не является ошибкой: Get возвращает ok == false.
+type Cache interface {
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"shorturl/internal/metrics"
+	"shorturl/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	_ = c.Set(ctx, "a", "1", time.Minute)
+	_ = c.Set(ctx, "b", "2", 0)
+	if v, ok, _ := c.Get(ctx, "a"); !ok || v != "1" {
+		t.Fatalf("Expected hit for a, got %q, %v", v, ok)
+	}
+
+	// a использовался последним, поэтому вытесняется b.
+	_ = c.Set(ctx, "c", "3", time.Minute)
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", c.Len())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Errorf("Expected a to expire")
+	}
+
+	_ = c.Delete(ctx, "c")
+	if c.Len() != 0 {
+		t.Errorf("Expected empty cache, got %d entries", c.Len())
+	}
+}
+
+// countingStore считает обращения к хранилищу.
+type countingStore struct {
+	urls   map[string]string
+	hashes map[string]string
+	gets   int
+	next   string
+	err    error
+	lists  int
+}
+
+func (s *countingStore) CreateShortURL(_ context.Context, _, originalURL string) (string, error) {
+	s.urls[s.next] = originalURL
+	return s.next, nil
+}
+
+func (s *countingStore) CreateURL(_ context.Context, pair storage.URLPair) (string, error) {
+	s.urls[s.next] = pair.OriginalURL
+	return s.next, nil
+}
+
+func (s *countingStore) UpdateURLMetadata(context.Context, string, string, storage.MetadataPatch) (storage.URLPair, error) {
+	return storage.URLPair{}, storage.ErrNotFound
+}
+
+func (s *countingStore) UpdateOriginalURL(_ context.Context, _, shortID, originalURL string) (storage.URLPair, error) {
+	s.urls[shortID] = originalURL
+	return storage.URLPair{ShortURL: shortID, OriginalURL: originalURL}, nil
+}
+
+func (s *countingStore) GetURLHistory(context.Context, string, string) ([]storage.HistoryEntry, error) {
+	return nil, nil
+}
+
+func (s *countingStore) SetURLPreview(context.Context, string, string, storage.Preview) error {
+	return nil
+}
+
+func (s *countingStore) SetURLHealth(context.Context, string, string, storage.LinkHealth) error {
+	return nil
+}
+
+func (s *countingStore) GetOriginalURL(_ context.Context, shortID string) (string, error) {
+	return s.urls[shortID], nil
+}
+
+func (s *countingStore) GetRedirect(_ context.Context, shortID string) (storage.Redirect, error) {
+	s.gets++
+	if s.err != nil {
+		return storage.Redirect{}, s.err
+	}
+	originalURL, ok := s.urls[shortID]
+	if !ok {
+		return storage.Redirect{}, storage.ErrNotFound
+	}
+	return storage.Redirect{URL: originalURL, PasswordHash: s.hashes[shortID]}, nil
+}
+
+func (s *countingStore) ConsumeClick(context.Context, string) error {
+	return nil
+}
+
+func (s *countingStore) GetURLsByUserID(context.Context, string) ([]storage.URLPair, error) {
+	s.lists++
+	return nil, nil
+}
+
+func (s *countingStore) ForEachUserURL(context.Context, string, func(storage.URLPair) error) error {
+	s.lists++
+	return nil
+}
+
+func (s *countingStore) ListUserURLs(context.Context, string, storage.ListOptions) ([]storage.URLPair, error) {
+	s.lists++
+	return nil, nil
+}
+
+func (s *countingStore) SearchUserURLs(context.Context, string, storage.SearchOptions) ([]storage.SearchHit, error) {
+	return nil, nil
+}
+
+// failingCache имитирует недоступный кэш.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) (string, bool, error) {
+	return "", false, errors.New("connection refused")
+}
+func (failingCache) Set(context.Context, string, string, time.Duration) error {
+	return errors.New("connection refused")
+}
+func (failingCache) Delete(context.Context, ...string) error { return errors.New("connection refused") }
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	srv := startFakeRedis(t, "")
+	r, err := NewRedis(srv.url())
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	for name, c := range map[string]Cache{"test_memory": NewLRU(100), "test_redis": r} {
+		t.Run(name, func(t *testing.T) {
+			inner := &countingStore{urls: map[string]string{"abc": "https://example.com"}, hashes: map[string]string{}, next: "new"}
+			s := NewStorage(inner, c, name, time.Minute, time.Minute)
+
+			for i := 0; i < 3; i++ {
+				if got, err := s.GetOriginalURL(ctx, "abc"); err != nil || got != "https://example.com" {
+					t.Fatalf("Expected original URL, got %q, %v", got, err)
+				}
+			}
+			if inner.gets != 1 {
+				t.Errorf("Expected 1 storage read, got %d", inner.gets)
+			}
+			if hits := metrics.CacheRequests.Value(name, "hit"); hits != 2 {
+				t.Errorf("Expected 2 hits, got %v", hits)
+			}
+
+			// Промах кэшируется, пока ссылка не будет создана.
+			for i := 0; i < 2; i++ {
+				if got, err := s.GetOriginalURL(ctx, "new"); err != nil || got != "" {
+					t.Fatalf("Expected empty result, got %q, %v", got, err)
+				}
+			}
+			if metrics.CacheRequests.Value(name, "negative_hit") != 1 {
+				t.Errorf("Expected negative hit")
+			}
+			if _, err := s.CreateShortURL(ctx, "user", "https://example.com/new"); err != nil {
+				t.Fatalf("CreateShortURL failed: %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "new"); got != "https://example.com/new" {
+				t.Errorf("Expected negative entry to be invalidated, got %q", got)
+			}
+
+			inner.urls["abc"] = "https://example.com/changed"
+			if err := s.Invalidate(ctx, "abc"); err != nil {
+				t.Fatalf("Invalidate failed: %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "abc"); got != "https://example.com/changed" {
+				t.Errorf("Expected fresh value after invalidation, got %q", got)
+			}
+
+			// Смена адреса сбрасывает закэшированный прежний адрес.
+			if _, err := s.UpdateOriginalURL(ctx, "user", "abc", "https://example.com/moved"); err != nil {
+				t.Fatalf("UpdateOriginalURL failed: %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "abc"); got != "https://example.com/moved" {
+				t.Errorf("Expected new destination after update, got %q", got)
+			}
+
+			// Ссылка, признанная мертвой, сразу ведет на запасной адрес.
+			inner.urls["abc"] = "https://example.com/fallback"
+			if err := s.SetURLHealth(ctx, "abc", "https://example.com/moved", storage.LinkHealth{Failures: 3}); err != nil {
+				t.Fatalf("SetURLHealth failed: %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "abc"); got != "https://example.com/fallback" {
+				t.Errorf("Expected fallback after health update, got %q", got)
+			}
+
+			// Хэш пароля кэшируется вместе с адресом: защищенная ссылка не
+			// открывается без пароля и при попадании в кэш.
+			inner.urls["locked"], inner.hashes["locked"] = "https://example.com/secret", "$2a$10$hash"
+			for i := 0; i < 2; i++ {
+				if got, err := s.GetRedirect(ctx, "locked"); err != nil || !got.Protected() || got.URL != "https://example.com/secret" {
+					t.Fatalf("Expected protected redirect, got %+v, %v", got, err)
+				}
+			}
+			if _, err := s.GetRedirect(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+
+			if _, err := s.GetURLsByUserID(ctx, "user"); err != nil || inner.lists != 1 {
+				t.Errorf("Expected GetURLsByUserID to pass through")
+			}
+		})
+	}
+}
+
+func TestStorageDegradesOnCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingStore{urls: map[string]string{"abc": "https://example.com"}}
+	s := NewStorage(inner, failingCache{}, "test_failing", time.Minute, time.Minute)
+
+	if got, err := s.GetOriginalURL(ctx, "abc"); err != nil || got != "https://example.com" {
+		t.Fatalf("Expected fallback to storage, got %q, %v", got, err)
+	}
+	if metrics.CacheRequests.Value("test_failing", "error") != 1 {
+		t.Errorf("Expected cache error to be counted")
+	}
+
+	inner.err = errors.New("db down")
+	if _, err := s.GetOriginalURL(ctx, "abc"); err == nil {
+		t.Errorf("Expected storage error to propagate")
+	}
+}
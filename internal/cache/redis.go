@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для клиента Redis.
+const (
+	DefaultRedisTimeout  = time.Second
+	DefaultRedisPoolSize = 16
+)
+
+// ErrClosed возвращается при обращении к закрытому клиенту.
+var ErrClosed = errors.New("cache: client is closed")
+
+// RedisError — ошибка, которую вернул сервер (ответ с префиксом '-').
+type RedisError string
+
+func (e RedisError) Error() string { return "redis: " + string(e) }
+
+// Redis — минимальный клиент протокола RESP2 с пулом соединений. Поддерживает
+// только команды, нужные кэшу, и работает с любым Redis-совместимым сервером
+// (Redis, Valkey, KeyDB, Dragonfly).
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+	wr *bufio.Writer
+}
+
+// NewRedis создает клиент по URL вида redis://[user:password@]host:port[/db].
+// Соединения устанавливаются лениво, при первой команде.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis URL scheme %q", u.Scheme)
+	}
+	r := &Redis{addr: u.Host, timeout: DefaultRedisTimeout}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+		r.username = u.User.Username()
+		if r.password == "" {
+			// redis://:password@host или redis://password@host
+			r.password, r.username = r.username, ""
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q: %w", db, err)
+		}
+	}
+	return r, nil
+}
+
+// Get реализует Cache.
+func (r *Redis) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+	if reply == nil {
+		return "", false, nil
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set реализует Cache. Срок жизни округляется до миллисекунд.
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+// Delete реализует Cache.
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// PingContext проверяет доступность сервера.
+func (r *Redis) PingContext(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+// Close закрывает простаивающие соединения; соединения, занятые командами,
+// закрываются по их завершении.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	idle := r.idle
+	r.idle = nil
+	r.closed = true
+	r.mu.Unlock()
+
+	var errs []error
+	for _, c := range idle {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// do выполняет одну команду. Соединение с ошибкой ввода-вывода закрывается,
+// а ошибка сервера (RedisError) соединение не портит.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(ctx, r.timeout, args)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		_ = c.Close()
+		return nil, err
+	}
+	r.put(c)
+	return reply, err
+}
+
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+	return r.dial(ctx)
+}
+
+func (r *Redis) put(c *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || len(r.idle) >= DefaultRedisPoolSize {
+		_ = c.Close()
+		return
+	}
+	r.idle = append(r.idle, c)
+}
+
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: r.timeout}
+	nc, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to connect to %s: %w", r.addr, err)
+	}
+	c := &redisConn{Conn: nc, rd: bufio.NewReader(nc), wr: bufio.NewWriter(nc)}
+
+	var handshake [][]string
+	switch {
+	case r.password != "" && r.username != "":
+		handshake = append(handshake, []string{"AUTH", r.username, r.password})
+	case r.password != "":
+		handshake = append(handshake, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		handshake = append(handshake, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, args := range handshake {
+		if _, err := c.roundTrip(ctx, r.timeout, args); err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("redis: %s failed: %w", args[0], err)
+		}
+	}
+	return c, nil
+}
+
+// roundTrip отправляет команду и читает ответ. Дедлайн берется из контекста,
+// но не превышает timeout.
+func (c *redisConn) roundTrip(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if err := writeCommand(c.wr, args); err != nil {
+		return nil, err
+	}
+	if err := c.wr.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(c.rd)
+}
+
+// writeCommand кодирует команду как массив bulk-строк RESP.
+func writeCommand(w *bufio.Writer, args []string) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readReply читает один ответ RESP2. Возвращает string для простых и bulk-строк,
+// int64 для целых, []any для массивов и nil для null-значений. Ответ-ошибка
+// возвращается как RedisError.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	prefix, body := line[0], line[1:len(line)-2]
+
+	switch prefix {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			// Ошибка внутри массива — часть ответа: дочитываем массив целиком,
+			// чтобы не рассинхронизировать соединение.
+			item, err := readReply(rd)
+			var redisErr RedisError
+			if errors.As(err, &redisErr) {
+				item, err = redisErr, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", prefix)
+	}
+}
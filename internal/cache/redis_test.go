@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis — локальная замена Redis-сервера: понимает GET, SET [PX], DEL,
+// PING, AUTH и SELECT поверх настоящего TCP и протокола RESP.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	data     map[string]string
+	expires  map[string]time.Time
+	commands []string
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := &fakeRedis{ln: ln, password: password, data: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) url() string {
+	if s.password != "" {
+		return "redis://:" + s.password + "@" + s.ln.Addr().String() + "/2"
+	}
+	return "redis://" + s.ln.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	rd := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readReply(rd)
+		if err != nil {
+			return
+		}
+		items := reply.([]any)
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+		cmd := strings.ToUpper(args[0])
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		var out string
+		switch {
+		case cmd == "AUTH":
+			authed = args[len(args)-1] == s.password
+			out = "+OK\r\n"
+			if !authed {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			out = "+PONG\r\n"
+		case cmd == "SELECT":
+			out = "+OK\r\n"
+		case cmd == "GET":
+			v, ok := s.data[args[1]]
+			if exp, has := s.expires[args[1]]; has && !time.Now().Before(exp) {
+				ok = false
+			}
+			out = "$-1\r\n"
+			if ok {
+				out = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			}
+		case cmd == "SET":
+			s.data[args[1]] = args[2]
+			delete(s.expires, args[1])
+			if len(args) == 5 && strings.EqualFold(args[3], "PX") {
+				ms, _ := strconv.Atoi(args[4])
+				s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			out = "+OK\r\n"
+		case cmd == "DEL":
+			n := 0
+			for _, k := range args[1:] {
+				if _, ok := s.data[k]; ok {
+					n++
+				}
+				delete(s.data, k)
+			}
+			out = ":" + strconv.Itoa(n) + "\r\n"
+		default:
+			out = "-ERR unknown command '" + args[0] + "'\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	srv := startFakeRedis(t, "secret")
+
+	r, err := NewRedis(srv.url())
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	if err := r.PingContext(ctx); err != nil {
+		t.Fatalf("PingContext failed: %v", err)
+	}
+	if _, ok, err := r.Get(ctx, "k"); ok || err != nil {
+		t.Errorf("Expected miss, got %v, %v", ok, err)
+	}
+	if err := r.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, ok, err := r.Get(ctx, "k"); !ok || v != "v" || err != nil {
+		t.Errorf("Expected hit v, got %q, %v, %v", v, ok, err)
+	}
+	if err := r.Set(ctx, "empty", "", time.Millisecond); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := r.Get(ctx, "empty"); ok {
+		t.Errorf("Expected expired key to be missing")
+	}
+	if err := r.Delete(ctx, "k", "other"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok, _ := r.Get(ctx, "k"); ok {
+		t.Errorf("Expected deleted key to be missing")
+	}
+
+	// Ошибка сервера возвращается как RedisError и не закрывает соединение.
+	_, err = r.do(ctx, "FLUSHALL")
+	var redisErr RedisError
+	if !errors.As(err, &redisErr) {
+		t.Errorf("Expected RedisError, got %v", err)
+	}
+
+	srv.mu.Lock()
+	auths, selects := 0, 0
+	for _, cmd := range srv.commands {
+		switch cmd {
+		case "AUTH":
+			auths++
+		case "SELECT":
+			selects++
+		}
+	}
+	srv.mu.Unlock()
+	if auths != 1 || selects != 1 {
+		t.Errorf("Expected a single pooled connection (1 AUTH, 1 SELECT), got %d, %d", auths, selects)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := startFakeRedis(t, "secret")
+
+	r, err := NewRedis("redis://:wrong@" + srv.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	if err := r.PingContext(ctx); err == nil {
+		t.Errorf("Expected AUTH error")
+	}
+
+	for _, raw := range []string{"http://localhost", "redis://localhost/db"} {
+		if _, err := NewRedis(raw); err == nil {
+			t.Errorf("Expected error for %q", raw)
+		}
+	}
+
+	r, _ = NewRedis(srv.url())
+	_ = r.Close()
+	if err := r.PingContext(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
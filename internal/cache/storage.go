@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
+	"shorturl/internal/service"
+	"shorturl/internal/storage"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// keyPrefix отделяет ключи сервиса в общем Redis.
+const keyPrefix = "shorturl:url:"
+
+// missValue — значение отрицательной записи. Запись о ссылке — JSON
+// storage.Redirect, поэтому пустая строка однозначно означает «такой ссылки нет».
+const missValue = ""
+
+// Storage — кэширующий декоратор хранилища. Кэширует GetRedirect, включая
+// отрицательные ответы; GetOriginalURL отвечает из той же записи, остальные
+// методы проксируются как есть. Ошибки кэша не ломают запрос: чтение идет в
+// хранилище, ошибка только логируется.
+type Storage struct {
+	service.ShortURLCreatorGetter
+	cache       Cache
+	backend     string
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// NewStorage оборачивает store кэшем c. backend — метка для метрик,
+// ttl — время жизни найденных ссылок, negativeTTL — отсутствующих
+// (0 отключает отрицательное кэширование).
+func NewStorage(store service.ShortURLCreatorGetter, c Cache, backend string, ttl, negativeTTL time.Duration) *Storage {
+	return &Storage{
+		ShortURLCreatorGetter: store,
+		cache:                 c,
+		backend:               backend,
+		ttl:                   ttl,
+		negativeTTL:           negativeTTL,
+	}
+}
+
+// GetOriginalURL возвращает адрес перехода по ссылке из кэша или из
+// хранилища (см. GetRedirect); пустую строку, если ссылки нет.
+func (s *Storage) GetOriginalURL(ctx context.Context, shortID string) (string, error) {
+	redirect, err := s.GetRedirect(ctx, shortID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", nil
+	}
+	return redirect.URL, err
+}
+
+// GetRedirect возвращает сведения для перехода по ссылке из кэша или из
+// хранилища, запоминая результат. Запись, которую не удалось разобрать
+// (например, оставшуюся от прежней версии сервиса), считается промахом.
+func (s *Storage) GetRedirect(ctx context.Context, shortID string) (storage.Redirect, error) {
+	key := keyPrefix + shortID
+
+	value, ok, err := s.cache.Get(ctx, key)
+	var redirect storage.Redirect
+	switch {
+	case err != nil:
+		metrics.CacheRequests.Inc(s.backend, "error")
+		logger.Logger.Warn("cache get failed", zap.String("backend", s.backend), zap.Error(err))
+	case ok && value == missValue:
+		metrics.CacheRequests.Inc(s.backend, "negative_hit")
+		return storage.Redirect{}, storage.ErrNotFound
+	case ok && json.Unmarshal([]byte(value), &redirect) == nil && redirect.URL != "":
+		metrics.CacheRequests.Inc(s.backend, "hit")
+		return redirect, nil
+	default:
+		metrics.CacheRequests.Inc(s.backend, "miss")
+	}
+
+	redirect, err = s.ShortURLCreatorGetter.GetRedirect(ctx, shortID)
+	value, ttl := missValue, s.negativeTTL
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		if ttl <= 0 {
+			return redirect, err
+		}
+	case err != nil:
+		return storage.Redirect{}, err
+	default:
+		data, marshalErr := json.Marshal(redirect)
+		if marshalErr != nil {
+			return redirect, nil
+		}
+		value, ttl = string(data), s.ttl
+	}
+	if setErr := s.cache.Set(ctx, key, value, ttl); setErr != nil {
+		logger.Logger.Warn("cache set failed", zap.String("backend", s.backend), zap.Error(setErr))
+	}
+	return redirect, err
+}
+
+// CreateShortURL создает ссылку и сбрасывает отрицательную запись для нового
+// ID: его могли запросить до создания.
+func (s *Storage) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
+	shortID, err := s.ShortURLCreatorGetter.CreateShortURL(ctx, userID, originalURL)
+	s.invalidateChanged(ctx, shortID, err)
+	return shortID, err
+}
+
+// CreateURL создает ссылку по образцу pair, как и CreateShortURL сбрасывая
+// отрицательную запись для нового ID.
+func (s *Storage) CreateURL(ctx context.Context, pair storage.URLPair) (string, error) {
+	shortID, err := s.ShortURLCreatorGetter.CreateURL(ctx, pair)
+	s.invalidateChanged(ctx, shortID, err)
+	return shortID, err
+}
+
+// UpdateOriginalURL меняет адрес назначения ссылки и удаляет ее прежний
+// адрес из кэша, чтобы переход сразу вел на новый.
+func (s *Storage) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (storage.URLPair, error) {
+	pair, err := s.ShortURLCreatorGetter.UpdateOriginalURL(ctx, userID, shortID, originalURL)
+	s.invalidateChanged(ctx, shortID, err)
+	return pair, err
+}
+
+// UpdateURLMetadata изменяет метаданные ссылки и сбрасывает ее запись в кэше:
+// от запасного адреса зависит переход по мертвой ссылке.
+func (s *Storage) UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (storage.URLPair, error) {
+	pair, err := s.ShortURLCreatorGetter.UpdateURLMetadata(ctx, userID, shortID, patch)
+	s.invalidateChanged(ctx, shortID, err)
+	return pair, err
+}
+
+// SetURLHealth сохраняет результат проверки ссылки и сбрасывает ее запись в
+// кэше: ссылка могла стать мертвой или ожить.
+func (s *Storage) SetURLHealth(ctx context.Context, shortID, originalURL string, health storage.LinkHealth) error {
+	err := s.ShortURLCreatorGetter.SetURLHealth(ctx, shortID, originalURL, health)
+	s.invalidateChanged(ctx, shortID, err)
+	return err
+}
+
+// invalidateChanged сбрасывает запись ссылки shortID после успешного изменения;
+// ошибка кэша только логируется.
+func (s *Storage) invalidateChanged(ctx context.Context, shortID string, err error) {
+	if err != nil {
+		return
+	}
+	if invErr := s.Invalidate(ctx, shortID); invErr != nil {
+		logger.Logger.Warn("cache invalidation failed", zap.String("backend", s.backend), zap.Error(invErr))
+	}
+}
+
+// Invalidate удаляет записи коротких ссылок из кэша. Вызывается после любого
+// изменения или удаления ссылок в хранилище.
+func (s *Storage) Invalidate(ctx context.Context, shortIDs ...string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(shortIDs))
+	for i, id := range shortIDs {
+		keys[i] = keyPrefix + id
+	}
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("failed to invalidate cache: %w", err)
+	}
+	metrics.CacheInvalidations.Add(float64(len(keys)), s.backend)
+	return nil
+}
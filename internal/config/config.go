@@ -1,10 +1,70 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Значения по умолчанию для ограничений входящих запросов.
+const (
+	DefaultMaxBodyBytes = 1 << 20 // 1 MiB после распаковки
+	DefaultMaxBatchSize = 1000
+)
+
+// Значения по умолчанию для проверок готовности и остановки сервиса.
+const (
+	DefaultMinFreeDiskBytes = 64 << 20 // 64 MiB
+	DefaultShutdownTimeout  = 15 * time.Second
+	// DefaultShutdownDrainDelay — пауза между переводом /readyz в 503 и
+	// остановкой сервера, за которую балансировщик успевает исключить инстанс.
+	DefaultShutdownDrainDelay = 5 * time.Second
+)
+
+// Значения по умолчанию для компактизации журнала файлового хранилища.
+const (
+	DefaultFileCompactMaxBytes = 64 << 20 // 64 MiB
+	DefaultFileCompactRatio    = 0.5
+	DefaultFileSyncInterval    = time.Second
+)
+
+// DefaultMemorySnapshotInterval — период сохранения снимка хранилища в памяти.
+const DefaultMemorySnapshotInterval = 5 * time.Minute
+
+// Значения по умолчанию для кэша редиректов.
+const (
+	DefaultCacheSize        = 10000
+	DefaultCacheTTL         = 10 * time.Minute
+	DefaultCacheNegativeTTL = 30 * time.Second
+	DefaultRedisURL         = "redis://localhost:6379/0"
+)
+
+// Значения по умолчанию для фоновой загрузки страниц ссылок.
+const (
+	DefaultPreviewWorkers  = 4
+	DefaultPreviewTimeout  = 10 * time.Second
+	DefaultPreviewMaxBytes = 512 << 10 // 512 KiB
+)
+
+// Значения по умолчанию для проверки доступности адресов назначения.
+const (
+	DefaultLinkCheckInterval  = 24 * time.Hour
+	DefaultLinkCheckWorkers   = 4
+	DefaultLinkCheckTimeout   = 10 * time.Second
+	DefaultLinkCheckHostDelay = time.Second
+)
+
+// Значения по умолчанию для защищенных паролем ссылок.
+const (
+	DefaultUnlockTTL             = 15 * time.Minute
+	DefaultPasswordAttempts      = 5
+	DefaultPasswordLinkAttempts  = 50
+	DefaultPasswordAttemptWindow = time.Minute
 )
 
 type Config struct {
@@ -14,6 +74,67 @@ type Config struct {
 	LogLevel        string `env:"LOG_LEVEL" envDefault:"info"`
 	LogFormat       string `env:"LOG_FORMAT" envDefault:"json"`
 	DatabaseDSN     string `env:"DATABASE_DSN"`
+	// StorageBackend — имя бэкенда хранилища (postgres, sqlite, file, bolt, memory).
+	// Если не задан, выбирается по DatabaseDSN и FileStoragePath.
+	StorageBackend string `env:"STORAGE_BACKEND"`
+	// StorageFallback разрешает переход на следующий бэкенд цепочки
+	// postgres → sqlite → file → memory, если выбранный не удалось открыть.
+	StorageFallback bool   `env:"STORAGE_FALLBACK" envDefault:"false"`
+	MaxBodyBytes    int64  `env:"MAX_BODY_BYTES" envDefault:"1048576"`
+	MaxBatchSize    int    `env:"MAX_BATCH_SIZE" envDefault:"1000"`
+	TraceExporter   string `env:"TRACE_EXPORTER" envDefault:"none"`
+	OTLPEndpoint    string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:"http://localhost:4318"`
+	ServiceName     string `env:"OTEL_SERVICE_NAME" envDefault:"shorturl"`
+	// MinFreeDiskBytes — минимальный объем свободного места для файлового хранилища,
+	// ниже которого сервис считается не готовым.
+	MinFreeDiskBytes int64         `env:"MIN_FREE_DISK_BYTES" envDefault:"67108864"`
+	ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+	// ShutdownDrainDelay — сколько сервис продолжает принимать запросы после
+	// перевода /readyz в 503, прежде чем начать остановку сервера.
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" envDefault:"5s"`
+	// FileDurability — режим fsync журнала файлового хранилища (none, interval, always).
+	FileDurability   string        `env:"FILE_DURABILITY" envDefault:"interval"`
+	FileSyncInterval time.Duration `env:"FILE_SYNC_INTERVAL" envDefault:"1s"`
+	// FileCompactMaxBytes, FileCompactRatio и FileCompactInterval — условия
+	// компактизации журнала файлового хранилища; 0 отключает условие.
+	FileCompactMaxBytes int64         `env:"FILE_COMPACT_MAX_BYTES" envDefault:"67108864"`
+	FileCompactRatio    float64       `env:"FILE_COMPACT_RATIO" envDefault:"0.5"`
+	FileCompactInterval time.Duration `env:"FILE_COMPACT_INTERVAL" envDefault:"0s"`
+	// MemorySnapshotPath — файл снимка хранилища в памяти; пустое значение
+	// отключает снимки. MemorySnapshotInterval 0 — сохранение только при остановке.
+	MemorySnapshotPath     string        `env:"MEMORY_SNAPSHOT_PATH"`
+	MemorySnapshotInterval time.Duration `env:"MEMORY_SNAPSHOT_INTERVAL" envDefault:"5m"`
+	// CacheBackend — кэш перед хранилищем для редиректов (none, memory, redis).
+	CacheBackend string `env:"CACHE_BACKEND" envDefault:"none"`
+	// CacheSize — максимальное число записей in-process кэша.
+	CacheSize int `env:"CACHE_SIZE" envDefault:"10000"`
+	// CacheTTL и CacheNegativeTTL — время жизни найденных и отсутствующих ссылок.
+	CacheTTL         time.Duration `env:"CACHE_TTL" envDefault:"10m"`
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" envDefault:"30s"`
+	RedisURL         string        `env:"REDIS_URL" envDefault:"redis://localhost:6379/0"`
+	// PreviewWorkers — число параллельных загрузок страниц новых ссылок для
+	// заголовка, описания и иконки; 0 отключает загрузку.
+	PreviewWorkers  int           `env:"PREVIEW_WORKERS" envDefault:"4"`
+	PreviewTimeout  time.Duration `env:"PREVIEW_TIMEOUT" envDefault:"10s"`
+	PreviewMaxBytes int64         `env:"PREVIEW_MAX_BYTES" envDefault:"524288"`
+	// LinkCheckInterval — как часто проверяется доступность адреса назначения
+	// каждой ссылки; 0 отключает проверку. LinkCheckHostDelay — минимальная
+	// пауза между запросами к одному хосту.
+	LinkCheckInterval  time.Duration `env:"LINK_CHECK_INTERVAL" envDefault:"24h"`
+	LinkCheckWorkers   int           `env:"LINK_CHECK_WORKERS" envDefault:"4"`
+	LinkCheckTimeout   time.Duration `env:"LINK_CHECK_TIMEOUT" envDefault:"10s"`
+	LinkCheckHostDelay time.Duration `env:"LINK_CHECK_HOST_DELAY" envDefault:"1s"`
+	// UnlockTTL — сколько действует cookie доступа к защищенной ссылке после
+	// ввода верного пароля. PasswordAttempts — сколько попыток ввода пароля
+	// одной ссылки разрешено с одного адреса за PasswordAttemptWindow,
+	// PasswordLinkAttempts — сколько всего попыток на ссылку от всех адресов.
+	UnlockTTL             time.Duration `env:"UNLOCK_TTL" envDefault:"15m"`
+	PasswordAttempts      int           `env:"PASSWORD_ATTEMPTS" envDefault:"5"`
+	PasswordLinkAttempts  int           `env:"PASSWORD_LINK_ATTEMPTS" envDefault:"50"`
+	PasswordAttemptWindow time.Duration `env:"PASSWORD_ATTEMPT_WINDOW" envDefault:"1m"`
+	// ComingSoon — отвечать на ссылку, окно действия которой еще не началось,
+	// страницей «скоро откроется» со временем открытия вместо 404.
+	ComingSoon bool `env:"COMING_SOON" envDefault:"false"`
 }
 
 // String реализует интерфейс fmt.Stringer для структуры Config.
@@ -25,19 +146,110 @@ func (c *Config) String() string {
 			"FileStoragePath='%s', "+
 			"LogLevel='%s', "+
 			"LogFormat='%s', "+
-			"DatabaseDSN='%s'",
+			"DatabaseDSN='%s', "+
+			"StorageBackend='%s', "+
+			"StorageFallback=%t, "+
+			"MaxBodyBytes=%d, "+
+			"MaxBatchSize=%d, "+
+			"TraceExporter='%s', "+
+			"OTLPEndpoint='%s', "+
+			"ServiceName='%s', "+
+			"MinFreeDiskBytes=%d, "+
+			"ShutdownTimeout=%s, "+
+			"ShutdownDrainDelay=%s, "+
+			"FileDurability='%s', "+
+			"FileSyncInterval=%s, "+
+			"FileCompactMaxBytes=%d, "+
+			"FileCompactRatio=%g, "+
+			"FileCompactInterval=%s, "+
+			"MemorySnapshotPath='%s', "+
+			"MemorySnapshotInterval=%s, "+
+			"CacheBackend='%s', "+
+			"CacheSize=%d, "+
+			"CacheTTL=%s, "+
+			"CacheNegativeTTL=%s, "+
+			"RedisURL='%s', "+
+			"PreviewWorkers=%d, "+
+			"PreviewTimeout=%s, "+
+			"PreviewMaxBytes=%d, "+
+			"LinkCheckInterval=%s, "+
+			"LinkCheckWorkers=%d, "+
+			"LinkCheckTimeout=%s, "+
+			"LinkCheckHostDelay=%s, "+
+			"UnlockTTL=%s, "+
+			"PasswordAttempts=%d, "+
+			"PasswordLinkAttempts=%d, "+
+			"PasswordAttemptWindow=%s, "+
+			"ComingSoon=%t",
 		c.ServerAddress,
 		c.BaseURL,
 		c.FileStoragePath,
 		c.LogLevel,
 		c.LogFormat,
 		c.DatabaseDSN,
+		c.StorageBackend,
+		c.StorageFallback,
+		c.MaxBodyBytes,
+		c.MaxBatchSize,
+		c.TraceExporter,
+		c.OTLPEndpoint,
+		c.ServiceName,
+		c.MinFreeDiskBytes,
+		c.ShutdownTimeout,
+		c.ShutdownDrainDelay,
+		c.FileDurability,
+		c.FileSyncInterval,
+		c.FileCompactMaxBytes,
+		c.FileCompactRatio,
+		c.FileCompactInterval,
+		c.MemorySnapshotPath,
+		c.MemorySnapshotInterval,
+		c.CacheBackend,
+		c.CacheSize,
+		c.CacheTTL,
+		c.CacheNegativeTTL,
+		RedactURL(c.RedisURL),
+		c.PreviewWorkers,
+		c.PreviewTimeout,
+		c.PreviewMaxBytes,
+		c.LinkCheckInterval,
+		c.LinkCheckWorkers,
+		c.LinkCheckTimeout,
+		c.LinkCheckHostDelay,
+		c.UnlockTTL,
+		c.PasswordAttempts,
+		c.PasswordLinkAttempts,
+		c.PasswordAttemptWindow,
+		c.ComingSoon,
 	)
 }
 
+// RedactURL возвращает адрес raw для журнала: пароль из userinfo заменяется
+// на "xxxxx". Неразбираемый адрес не выводится, чтобы не раскрыть его части.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return u.Redacted()
+}
+
 // Load загружает конфигурацию из переменных окружения и флагов командной строки.
-// Приоритет: переменные окружения > флаги > значения по умолчанию.
+// Приоритет: переменные окружения > флаги > значения по умолчанию. Как и
+// flag.CommandLine при ошибке флагов, некорректная переменная окружения
+// завершает процесс с кодом 2.
 func Load() *Config {
+	cfg, err := LoadFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return cfg
+}
+
+// LoadFlags загружает конфигурацию, разбирая args набором флагов fs. Позволяет
+// подкомандам добавить в fs собственные флаги к общим флагам конфигурации.
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := &Config{}
 
 	envServerAddress := os.Getenv("SERVER_ADDRESS")
@@ -46,20 +258,34 @@ func Load() *Config {
 	envLogLevel := os.Getenv("LOG_LEVEL")
 	envLogFormat := os.Getenv("LOG_FORMAT")
 	envDatabaseDSN := os.Getenv("DATABASE_DSN")
+	envStorageBackend := os.Getenv("STORAGE_BACKEND")
 
 	var flagServerAddress string
 	var flagBaseURL string
 	var flagLogLevel string
 	var flagFileStoragePath string
 	var flagDatabaseDSN string
+	var flagStorageBackend string
+	var flagStorageFallback bool
+	var flagMaxBodyBytes int64
+	var flagMaxBatchSize int
+	var flagCacheBackend string
+
+	fs.StringVar(&flagServerAddress, "a", "localhost:8080", "HTTP server address")
+	fs.StringVar(&flagBaseURL, "b", "", "Base URL for shortened links")
+	fs.StringVar(&flagLogLevel, "l", "info", "Log level (debug, info, warn, error, fatal)")
+	fs.StringVar(&flagDatabaseDSN, "d", "", "Database connection string (DSN)")
+	fs.StringVar(&flagFileStoragePath, "f", "", "File storage path")
+	fs.StringVar(&flagStorageBackend, "s", "", "Storage backend (postgres, sqlite, file, bolt, memory); inferred from -d/-f if empty")
+	fs.BoolVar(&flagStorageFallback, "storage-fallback", false, "Fall back to the next storage backend if the selected one fails to open")
+	fs.Int64Var(&flagMaxBodyBytes, "max-body", DefaultMaxBodyBytes, "Maximum request body size in bytes (after decompression)")
+	fs.IntVar(&flagMaxBatchSize, "max-batch", DefaultMaxBatchSize, "Maximum number of items in a batch request")
 
-	flag.StringVar(&flagServerAddress, "a", "localhost:8080", "HTTP server address")
-	flag.StringVar(&flagBaseURL, "b", "", "Base URL for shortened links")
-	flag.StringVar(&flagLogLevel, "l", "info", "Log level (debug, info, warn, error, fatal)")
-	flag.StringVar(&flagDatabaseDSN, "d", "", "Database connection string (DSN)")
-	flag.StringVar(&flagFileStoragePath, "f", "", "File storage path")
+	fs.StringVar(&flagCacheBackend, "cache", "none", "Redirect cache backend (none, memory, redis)")
 
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
 	if envServerAddress != "" {
 		cfg.ServerAddress = envServerAddress
@@ -95,11 +321,106 @@ func Load() *Config {
 		cfg.DatabaseDSN = flagDatabaseDSN
 	}
 
+	if envStorageBackend != "" {
+		cfg.StorageBackend = envStorageBackend
+	} else {
+		cfg.StorageBackend = flagStorageBackend
+	}
+
+	env := &envParser{}
+	cfg.StorageFallback = env.Bool("STORAGE_FALLBACK", flagStorageFallback)
+
+	cfg.MaxBodyBytes = env.Int64("MAX_BODY_BYTES", flagMaxBodyBytes)
+	cfg.MaxBatchSize = int(env.Int64("MAX_BATCH_SIZE", int64(flagMaxBatchSize)))
+	cfg.TraceExporter = envString("TRACE_EXPORTER", "none")
+	cfg.OTLPEndpoint = envString("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+	cfg.ServiceName = envString("OTEL_SERVICE_NAME", "shorturl")
+	cfg.MinFreeDiskBytes = env.Int64("MIN_FREE_DISK_BYTES", DefaultMinFreeDiskBytes)
+	cfg.ShutdownTimeout = env.Duration("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout)
+	cfg.ShutdownDrainDelay = env.Duration("SHUTDOWN_DRAIN_DELAY", DefaultShutdownDrainDelay)
+	cfg.FileDurability = envString("FILE_DURABILITY", "interval")
+	cfg.FileSyncInterval = env.Duration("FILE_SYNC_INTERVAL", DefaultFileSyncInterval)
+	cfg.FileCompactMaxBytes = env.Int64("FILE_COMPACT_MAX_BYTES", DefaultFileCompactMaxBytes)
+	cfg.FileCompactRatio = env.Float64("FILE_COMPACT_RATIO", DefaultFileCompactRatio)
+	cfg.FileCompactInterval = env.Duration("FILE_COMPACT_INTERVAL", 0)
+	cfg.MemorySnapshotPath = envString("MEMORY_SNAPSHOT_PATH", "")
+	cfg.MemorySnapshotInterval = env.Duration("MEMORY_SNAPSHOT_INTERVAL", DefaultMemorySnapshotInterval)
+	cfg.CacheBackend = envString("CACHE_BACKEND", flagCacheBackend)
+	cfg.CacheSize = int(env.Int64("CACHE_SIZE", DefaultCacheSize))
+	cfg.CacheTTL = env.Duration("CACHE_TTL", DefaultCacheTTL)
+	cfg.CacheNegativeTTL = env.Duration("CACHE_NEGATIVE_TTL", DefaultCacheNegativeTTL)
+	cfg.RedisURL = envString("REDIS_URL", DefaultRedisURL)
+	cfg.PreviewWorkers = int(env.Int64("PREVIEW_WORKERS", DefaultPreviewWorkers))
+	cfg.PreviewTimeout = env.Duration("PREVIEW_TIMEOUT", DefaultPreviewTimeout)
+	cfg.PreviewMaxBytes = env.Int64("PREVIEW_MAX_BYTES", DefaultPreviewMaxBytes)
+	cfg.LinkCheckInterval = env.Duration("LINK_CHECK_INTERVAL", DefaultLinkCheckInterval)
+	cfg.LinkCheckWorkers = int(env.Int64("LINK_CHECK_WORKERS", DefaultLinkCheckWorkers))
+	cfg.LinkCheckTimeout = env.Duration("LINK_CHECK_TIMEOUT", DefaultLinkCheckTimeout)
+	cfg.LinkCheckHostDelay = env.Duration("LINK_CHECK_HOST_DELAY", DefaultLinkCheckHostDelay)
+	cfg.UnlockTTL = env.Duration("UNLOCK_TTL", DefaultUnlockTTL)
+	cfg.PasswordAttempts = int(env.Int64("PASSWORD_ATTEMPTS", DefaultPasswordAttempts))
+	cfg.PasswordLinkAttempts = int(env.Int64("PASSWORD_LINK_ATTEMPTS", DefaultPasswordLinkAttempts))
+	cfg.PasswordAttemptWindow = env.Duration("PASSWORD_ATTEMPT_WINDOW", DefaultPasswordAttemptWindow)
+	cfg.ComingSoon = env.Bool("COMING_SOON", false)
+
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = fmt.Sprintf("http://%s", cfg.ServerAddress)
 	} else {
 		cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
 	}
 
-	return cfg
+	if err := errors.Join(env.errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// envString возвращает значение переменной окружения или fallback, если она не задана.
+func envString(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envParser читает типизированные переменные окружения и запоминает ошибки
+// разбора: некорректное значение не должно молча заменяться значением по умолчанию.
+type envParser struct {
+	errs []error
+}
+
+// parse возвращает значение переменной name, разобранное parseFn, или fallback,
+// если переменная не задана. Ошибка разбора запоминается с именем и значением переменной.
+func parse[T any](p *envParser, name string, fallback T, parseFn func(string) (T, error)) T {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := parseFn(v)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s=%q: %w", name, v, err))
+		return fallback
+	}
+	return parsed
+}
+
+// Bool возвращает логическое значение переменной окружения или fallback, если она не задана.
+func (p *envParser) Bool(name string, fallback bool) bool {
+	return parse(p, name, fallback, strconv.ParseBool)
+}
+
+// Int64 возвращает целое значение переменной окружения или fallback, если она не задана.
+func (p *envParser) Int64(name string, fallback int64) int64 {
+	return parse(p, name, fallback, func(v string) (int64, error) { return strconv.ParseInt(v, 10, 64) })
+}
+
+// Float64 возвращает дробное значение переменной окружения или fallback, если она не задана.
+func (p *envParser) Float64(name string, fallback float64) float64 {
+	return parse(p, name, fallback, func(v string) (float64, error) { return strconv.ParseFloat(v, 64) })
+}
+
+// Duration возвращает значение переменной окружения в формате time.ParseDuration
+// или fallback, если она не задана.
+func (p *envParser) Duration(name string, fallback time.Duration) time.Duration {
+	return parse(p, name, fallback, time.ParseDuration)
 }
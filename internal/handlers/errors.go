@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/logger"
+	"shorturl/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// errUnauthorized возвращается, если в контексте запроса нет идентификатора пользователя.
+var errUnauthorized = errors.New("user ID not found in request context")
+
+// problemFromError транслирует ошибки сервиса в Problem с соответствующим HTTP-статусом.
+// Это единственное место, где ошибки сервиса сопоставляются со статусами.
+func problemFromError(err error) *apierror.Problem {
+	var problem *apierror.Problem
+	var validationErr *service.ValidationError
+	var conflictErr *service.ErrConflict
+	var maxBytesErr *http.MaxBytesError
+	var notActiveErr *service.ErrNotYetActive
+
+	switch {
+	case errors.As(err, &problem):
+		return problem
+	case errors.As(err, &conflictErr):
+		return apierror.New(http.StatusConflict, apierror.CodeConflict,
+			fmt.Sprintf("Original URL is already shortened as %s", conflictErr.ExistingShortID))
+	case errors.As(err, &validationErr):
+		code := validationErr.Code
+		if code == "" {
+			code = apierror.CodeInvalidURL
+		}
+		return apierror.New(http.StatusBadRequest, apierror.CodeValidation, "Request validation failed").
+			WithField(validationErr.Field, code, validationErr.Reason)
+	case errors.As(err, &maxBytesErr):
+		return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, service.ErrNotFound), errors.As(err, &notActiveErr):
+		// До начала окна действия ссылка неотличима от отсутствующей.
+		return apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Short URL not found")
+	case errors.Is(err, service.ErrClicksExhausted):
+		return apierror.New(http.StatusGone, apierror.CodeGone, "Short URL has no clicks left")
+	case errors.Is(err, service.ErrExpired):
+		return apierror.New(http.StatusGone, apierror.CodeGone, "Short URL has expired")
+	case errors.Is(err, errUnauthorized):
+		return apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "User is not authenticated")
+	case errors.Is(err, context.DeadlineExceeded):
+		return apierror.New(http.StatusGatewayTimeout, apierror.CodeTimeout, "Request timed out")
+	default:
+		return apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
+	}
+}
+
+// errUnknownRedirect — ответ на переход по неизвестной ссылке. Переход отвечает
+// 400, а не 404, как и до появления problem+json: на этот статус рассчитывают
+// существующие клиенты.
+func errUnknownRedirect() *apierror.Problem {
+	return apierror.New(http.StatusBadRequest, apierror.CodeNotFound, "Invalid or non-existent short URL")
+}
+
+// writeError отправляет клиенту ошибку err в формате application/problem+json.
+// Внутренние ошибки логируются, их текст клиенту не раскрывается.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := problemFromError(err)
+	if problem.Status >= http.StatusInternalServerError {
+		logger.Logger.Error("Request failed", zap.Error(err), zap.String("uri", r.RequestURI))
+	}
+	apierror.WriteProblem(w, r, problem)
+}
+
+// conflictShortID возвращает короткий ID уже существующей записи, если err — конфликт.
+func conflictShortID(err error) (string, bool) {
+	var conflictErr *service.ErrConflict
+	if errors.As(err, &conflictErr) {
+		return conflictErr.ExistingShortID, true
+	}
+	return "", false
+}
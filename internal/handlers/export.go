@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/storage"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// userURLExportTypes — поддерживаемые форматы выгрузки и их Content-Type.
+var userURLExportTypes = map[string]string{
+	"json":  "application/json",
+	"jsonl": "application/x-ndjson",
+	"csv":   "text/csv; charset=utf-8",
+}
+
+// HandleExportUserURLs отдает ссылки пользователя файлом в формате csv, json
+// или jsonl. Записи пишутся в ответ по мере чтения из хранилища, поэтому
+// размер выгрузки не ограничен памятью сервера.
+func (h *Handlers) HandleExportUserURLs(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		contentType, ok := userURLExportTypes[format]
+		if !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "format must be one of csv, json, jsonl")
+			return
+		}
+
+		// Заголовки отправляются с первой записью: ошибку хранилища до нее
+		// еще можно вернуть клиенту обычным ответом об ошибке.
+		var out *userURLExportWriter
+		start := func() {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "urls." + format}))
+			w.WriteHeader(http.StatusOK)
+			out = newUserURLExportWriter(w, format)
+		}
+
+		err = h.Service.ForEachUserURL(r.Context(), userID, func(pair storage.URLPair) error {
+			if out == nil {
+				start()
+			}
+			return out.write(newUserURLResponse(cfg, pair))
+		})
+		if err != nil && out == nil {
+			writeError(w, r, err)
+			return
+		}
+		if err != nil {
+			// Ответ уже начат: обрываем соединение, чтобы клиент не принял
+			// усеченный файл за полный.
+			logger.Logger.Error("Failed to stream user URLs export", zap.Error(err), zap.String("uri", r.RequestURI))
+			panic(http.ErrAbortHandler)
+		}
+		if out == nil {
+			start()
+		}
+		if err := out.close(); err != nil {
+			logger.Logger.Error("Error writing user URLs export", zap.Error(err))
+		}
+	}
+}
+
+// userURLExportWriter буферизует записи выгрузки и оформляет их в выбранном формате.
+type userURLExportWriter struct {
+	format  string
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	written int
+}
+
+func newUserURLExportWriter(w http.ResponseWriter, format string) *userURLExportWriter {
+	out := &userURLExportWriter{format: format, buf: bufio.NewWriter(w)}
+	switch format {
+	case "json":
+		_ = out.buf.WriteByte('[')
+	case "csv":
+		out.csv = csv.NewWriter(out.buf)
+		_ = out.csv.Write([]string{"short_url", "original_url", "created_at", "title", "tags", "notes", "folder"})
+	}
+	return out
+}
+
+func (o *userURLExportWriter) write(rec UserURLResponse) error {
+	defer func() { o.written++ }()
+	if o.format == "csv" {
+		createdAt := ""
+		if !rec.CreatedAt.IsZero() {
+			createdAt = rec.CreatedAt.Format(time.RFC3339)
+		}
+		return o.csv.Write([]string{rec.ShortURL, rec.OriginalURL, createdAt,
+			rec.Title, strings.Join(rec.Tags, ","), rec.Notes, rec.Folder})
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if o.format == "json" && o.written > 0 {
+		_ = o.buf.WriteByte(',')
+	}
+	_, _ = o.buf.Write(data)
+	if o.format == "jsonl" {
+		_ = o.buf.WriteByte('\n')
+	}
+	// Ошибки записи в bufio.Writer «залипают» и возвращаются из Flush.
+	return nil
+}
+
+// close завершает документ и сбрасывает буфер в ответ.
+func (o *userURLExportWriter) close() error {
+	switch o.format {
+	case "json":
+		_, _ = o.buf.WriteString("]\n")
+	case "csv":
+		o.csv.Flush()
+		if err := o.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return o.buf.Flush()
+}
@@ -8,11 +8,14 @@ import (
 	"github.com/go-chi/chi/v5"
 	"io"
 	"net/http"
+	"shorturl/internal/apierror"
 	"shorturl/internal/config"
 	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
 	"shorturl/internal/middleware"
 	"shorturl/internal/service"
-	"strings"
+	"shorturl/internal/storage"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
@@ -40,77 +43,162 @@ func NewHandlers(svc service.URLShortener) *Handlers {
 	return &Handlers{Service: svc}
 }
 
+// ShortenRequest — тело POST /api/shorten. Метаданные ссылки необязательны.
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL    string   `json:"url"`
+	Title  string   `json:"title,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Notes  string   `json:"notes,omitempty"`
+	Folder string   `json:"folder,omitempty"`
+	// FallbackURL — куда перенаправлять, если адрес назначения перестанет отвечать.
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// Password — пароль, который спросят при переходе по ссылке.
+	Password string `json:"password,omitempty"`
+	// MaxClicks — после стольких переходов ссылка перестает открываться (410).
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// NotBefore и NotAfter — окно действия ссылки в формате RFC 3339.
+	NotBefore string `json:"not_before,omitempty"`
+	NotAfter  string `json:"not_after,omitempty"`
 }
 
 type ShortenResponse struct {
 	Result string `json:"result"`
 }
 
+// UserURLResponse — ссылка пользователя в списке и выгрузке. CreatedAt
+// пропускается, если бэкенд не знает время создания ссылки.
 type UserURLResponse struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	Title       string    `json:"title,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+	Folder      string    `json:"folder,omitempty"`
+	FallbackURL string    `json:"fallback_url,omitempty"`
+	// Protected — переход по ссылке требует пароля.
+	Protected bool `json:"protected,omitempty"`
+	// MaxClicks и ClicksLeft — ограничение числа переходов и их остаток; есть
+	// только у ссылок с ограничением.
+	MaxClicks  int  `json:"max_clicks,omitempty"`
+	ClicksLeft *int `json:"clicks_left,omitempty"`
+	// NotBefore и NotAfter — окно действия ссылки; нет, если оно не задано.
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+	// Preview — сведения о странице назначения; нет, пока страница не загружена.
+	Preview *LinkPreview `json:"preview,omitempty"`
+	// Health — результат последней проверки адреса назначения; нет, пока
+	// ссылка не проверялась.
+	Health *LinkHealth `json:"health,omitempty"`
+}
+
+// LinkPreview — заголовок, описание и иконка страницы назначения,
+// загруженные в фоне после создания ссылки.
+type LinkPreview struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	FaviconURL  string    `json:"favicon_url,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// LinkHealth — результат последней проверки доступности адреса назначения.
+// Status равен 0, если сервер не ответил; причина тогда в Error.
+type LinkHealth struct {
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Dead      bool      `json:"dead"`
+	DeadSince time.Time `json:"dead_since,omitzero"`
+}
+
+// newUserURLResponse представляет запись хранилища в ответе API.
+func newUserURLResponse(cfg *config.Config, pair storage.URLPair) UserURLResponse {
+	resp := UserURLResponse{
+		ShortURL:    fmt.Sprintf("%s/%s", cfg.BaseURL, pair.ShortURL),
+		OriginalURL: pair.OriginalURL,
+		CreatedAt:   pair.CreatedAt,
+		Title:       pair.Title,
+		Tags:        pair.Tags,
+		Notes:       pair.Notes,
+		Folder:      pair.Folder,
+		FallbackURL: pair.FallbackURL,
+		Protected:   pair.PasswordHash != "",
+		NotBefore:   pair.NotBefore,
+		NotAfter:    pair.NotAfter,
+	}
+	if pair.MaxClicks > 0 {
+		resp.MaxClicks, resp.ClicksLeft = pair.MaxClicks, &pair.ClicksLeft
+	}
+	if p := pair.Preview; !p.IsZero() {
+		resp.Preview = &LinkPreview{Title: p.Title, Description: p.Description, FaviconURL: p.FaviconURL, FetchedAt: p.FetchedAt}
+	}
+	if h := pair.Health; !h.IsZero() {
+		resp.Health = &LinkHealth{Status: h.Status, Error: h.Error, LatencyMS: h.LatencyMS, CheckedAt: h.CheckedAt,
+			Dead: h.Dead(), DeadSince: h.DeadSince}
+	}
+	return resp
 }
 
 func (h *Handlers) HandleAPIShorten(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req ShortenRequest
-		body, err := io.ReadAll(r.Body)
+		body, err := readBody(r)
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			writeError(w, r, err)
 			return
 		}
-		defer func() {
-			if errClose := r.Body.Close(); errClose != nil {
-				logger.Logger.Error("Error closing request body", zap.Error(errClose))
-			}
-		}()
 
 		if err := json.Unmarshal(body, &req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
 
-		originalURL := req.URL
-		if !strings.HasPrefix(originalURL, "http://") && !strings.HasPrefix(originalURL, "https://") {
-			http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		if err := service.ValidateURL("url", req.URL); err != nil {
+			writeError(w, r, err)
 			return
 		}
-
-		userID, ok := r.Context().Value(middleware.UserIDKey).(string)
-		if !ok {
-			logger.Logger.Error("userID not found in context")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		notBefore, err := parseWindowBound("not_before", req.NotBefore)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		notAfter, err := parseWindowBound("not_after", req.NotAfter)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		shortID, err := h.Service.CreateShortURL(r.Context(), userID, originalURL) // Используем метод интерфейса
-		var conflictErr *service.ErrConflict                                       // Объявляем conflictErr здесь
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
+		status := http.StatusCreated
+		shortID, err := h.Service.CreateURL(r.Context(), userID, service.CreateRequest{
+			OriginalURL: req.URL,
+			Metadata: storage.Metadata{Title: req.Title, Tags: req.Tags, Notes: req.Notes, Folder: req.Folder,
+				FallbackURL: req.FallbackURL, NotBefore: notBefore, NotAfter: notAfter},
+			Password:  req.Password,
+			MaxClicks: req.MaxClicks,
+		})
 		if err != nil {
-			if errors.As(err, &conflictErr) {
-				w.Header().Set("Content-Type", "application/json") // Устанавливаем заголовок
-				w.WriteHeader(http.StatusConflict)
-				response := ShortenResponse{
-					Result: fmt.Sprintf("%s/%s", cfg.BaseURL, shortID),
-				}
-				if err := json.NewEncoder(w).Encode(response); err != nil {
-					logger.Logger.Error("Error writing JSON response", zap.Error(err))
-				}
-				return
-			} else {
-				http.Error(w, "Failed to create short URL", http.StatusInternalServerError)
+			existingID, ok := conflictShortID(err)
+			if !ok {
+				writeError(w, r, err)
 				return
 			}
+			shortID, status = existingID, http.StatusConflict
 		}
+		countShortened(status)
 
 		response := ShortenResponse{
 			Result: fmt.Sprintf("%s/%s", cfg.BaseURL, shortID),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(status)
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			logger.Logger.Error("Error writing JSON response", zap.Error(err))
 		}
@@ -121,42 +209,52 @@ func (h *Handlers) HandleAPIShorten(cfg *config.Config) http.HandlerFunc {
 func (h *Handlers) HandleAPIShortenBatch(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var requests []BatchShortenRequest
-		body, err := io.ReadAll(r.Body)
+		body, err := readBody(r)
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			writeError(w, r, err)
 			return
 		}
-		defer func() {
-			if err := r.Body.Close(); err != nil {
-				logger.Logger.Error("error closing request body", zap.Error(err))
-			}
-		}()
 
 		if err := json.Unmarshal(body, &requests); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "Request body is not valid JSON")
 			return
 		}
 
-		responses := make([]BatchShortenResponse, len(requests))
+		if cfg.MaxBatchSize > 0 && len(requests) > cfg.MaxBatchSize {
+			apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBatchTooLarge,
+				fmt.Sprintf("Batch contains %d items, maximum is %d", len(requests), cfg.MaxBatchSize))
+			return
+		}
+
+		// Проверяем весь пакет заранее, чтобы вернуть ошибки всех полей сразу
+		// и не создавать часть ссылок при невалидном пакете.
+		problem := apierror.New(http.StatusBadRequest, apierror.CodeValidation, "Request validation failed")
 		for i, req := range requests {
-			if !strings.HasPrefix(req.OriginalURL, "http://") && !strings.HasPrefix(req.OriginalURL, "https://") {
-				http.Error(w, fmt.Sprintf("Invalid URL format for correlation_id: %s", req.CorrelationID), http.StatusBadRequest)
-				return // Прерываем обработку всего пакета, если хотя бы один URL невалиден
+			var validationErr *service.ValidationError
+			if err := service.ValidateURL("original_url", req.OriginalURL); errors.As(err, &validationErr) {
+				problem.WithField(fmt.Sprintf("[%d].original_url", i), apierror.CodeInvalidURL, validationErr.Reason)
 			}
+		}
+		if len(problem.Errors) > 0 {
+			apierror.WriteProblem(w, r, problem)
+			return
+		}
 
-			userID, ok := r.Context().Value(middleware.UserIDKey).(string)
-			if !ok {
-				logger.Logger.Error("userID not found in context")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
+		responses := make([]BatchShortenResponse, len(requests))
+		for i, req := range requests {
 			shortID, err := h.Service.CreateShortURL(r.Context(), userID, req.OriginalURL)
 			if err != nil {
 				logger.Logger.Error("Failed to create short URL for batch", zap.Error(err), zap.String("correlation_id", req.CorrelationID), zap.String("original_url", req.OriginalURL))
-				http.Error(w, "Failed to create short URL for batch", http.StatusInternalServerError)
+				writeError(w, r, err)
 				return // Прерываем обработку всего пакета при ошибке создания.
 			}
+			countShortened(http.StatusCreated)
 
 			responses[i] = BatchShortenResponse{
 				CorrelationID: req.CorrelationID,
@@ -175,48 +273,37 @@ func (h *Handlers) HandleAPIShortenBatch(cfg *config.Config) http.HandlerFunc {
 // HandlePost обрабатывает POST-запросы (текст).
 func (h *Handlers) HandlePost(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		body, err := io.ReadAll(r.Body)
+		body, err := readBody(r)
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			writeError(w, r, err)
 			return
 		}
-		defer func() {
-			if errClose := r.Body.Close(); errClose != nil {
-				logger.Logger.Error("Error closing request body", zap.Error(errClose))
-			}
-		}()
 
 		originalURL := string(body)
-		if !strings.HasPrefix(originalURL, "http://") && !strings.HasPrefix(originalURL, "https://") {
-			http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		if err := service.ValidateURL("body", originalURL); err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		userID, ok := r.Context().Value(middleware.UserIDKey).(string)
-		if !ok {
-			logger.Logger.Error("userID not found in context")
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		shortID, err := h.Service.CreateShortURL(r.Context(), userID, originalURL) // Используем метод интерфейса
+		status := http.StatusCreated
+		shortID, err := h.Service.CreateShortURL(r.Context(), userID, originalURL)
 		if err != nil {
-			var conflictErr *service.ErrConflict
-			if errors.As(err, &conflictErr) {
-				w.Header().Set("Content-Type", "text/plain")
-				w.WriteHeader(http.StatusConflict)
-				_, err = fmt.Fprintf(w, "%s/%s", cfg.BaseURL, shortID)
-				if err != nil {
-					logger.Logger.Error("Error writing conflict response", zap.Error(err))
-				}
-				return
-			} else {
-				http.Error(w, "Failed to create short URL", http.StatusInternalServerError)
+			existingID, ok := conflictShortID(err)
+			if !ok {
+				writeError(w, r, err)
 				return
 			}
+			shortID, status = existingID, http.StatusConflict
 		}
+		countShortened(status)
 		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(status)
 		_, err = fmt.Fprintf(w, "%s/%s", cfg.BaseURL, shortID)
 		if err != nil {
 			logger.Logger.Error("Error writing response", zap.Error(err))
@@ -224,54 +311,117 @@ func (h *Handlers) HandlePost(cfg *config.Config) http.HandlerFunc {
 	}
 }
 
-// HandleGet обрабатывает GET-запросы с параметром shortID
-func (h *Handlers) HandleGet() http.HandlerFunc {
+// HandleGet обрабатывает GET-запросы с параметром shortID. Вместо перехода по
+// защищенной паролем ссылке отдает форму ввода пароля (см. HandleUnlock),
+// если у клиента нет действующей cookie доступа. Вне окна действия ссылки
+// отвечает как writeRedirectError.
+func (h *Handlers) HandleGet(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		shortID := chi.URLParam(r, "shortID")
-		if len(shortID) != shortURLLength {
-			http.Error(w, fmt.Sprintf("Invalid short URL format (expected %d characters)", shortURLLength), http.StatusBadRequest)
+		shortID, ok := shortIDParam(w, r)
+		if !ok {
 			return
 		}
-		defer func() {
-			if errClose := r.Body.Close(); errClose != nil {
-				logger.Logger.Error("Error closing request body", zap.Error(errClose))
-			}
-		}()
-		originalURL, err := h.Service.GetOriginalURL(r.Context(), shortID) // Используем метод интерфейса
+		redirect, err := h.Service.ResolveURL(r.Context(), shortID)
 		if err != nil {
-			http.Error(w, "Invalid or non-existent short URL", http.StatusBadRequest)
+			writeRedirectError(w, r, cfg, err)
 			return
 		}
-		w.Header().Set("Location", originalURL)
+		if redirect.Protected() && !unlocked(r, shortID, redirect, h.Service.Now()) {
+			metrics.Redirects.Inc("password_required")
+			renderPasswordForm(w, http.StatusOK, shortID, "")
+			return
+		}
+		if !h.consumeClick(w, r, shortID, redirect) {
+			return
+		}
+		metrics.Redirects.Inc("redirected")
+		w.Header().Set("Location", redirect.URL)
 		w.WriteHeader(http.StatusTemporaryRedirect)
 	}
 }
 
+// consumeClick списывает переход по ссылке с ограничением числа переходов.
+// Если переходы закончились или списать не удалось, отвечает ошибкой (410 —
+// для исчерпанной ссылки) и возвращает false.
+func (h *Handlers) consumeClick(w http.ResponseWriter, r *http.Request, shortID string, redirect storage.Redirect) bool {
+	if !redirect.Limited {
+		return true
+	}
+	err := h.Service.ConsumeClick(r.Context(), shortID)
+	if err == nil {
+		return true
+	}
+	switch {
+	case errors.Is(err, service.ErrClicksExhausted):
+		metrics.Redirects.Inc("exhausted")
+	case errors.Is(err, service.ErrNotFound):
+		metrics.Redirects.Inc("not_found")
+		apierror.WriteProblem(w, r, errUnknownRedirect())
+		return false
+	}
+	writeError(w, r, err)
+	return false
+}
+
+// shortIDParam возвращает короткий ID из пути запроса. Если ID неверной длины,
+// отвечает 400 и возвращает false.
+func shortIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	shortID := chi.URLParam(r, "shortID")
+	if len(shortID) != shortURLLength {
+		apierror.WriteProblem(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation, "Invalid short URL format").
+			WithField("shortID", apierror.CodeBadRequest, fmt.Sprintf("expected %d characters", shortURLLength)))
+		return "", false
+	}
+	return shortID, true
+}
+
+// HandleGetUserURLs отдает страницу ссылок пользователя. Параметры limit,
+// cursor, sort, domain и q передаются в service.ListRequest; ссылка на
+// следующую страницу возвращается в заголовках Link и X-Next-Cursor.
 func (h *Handlers) HandleGetUserURLs(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := r.Context().Value(middleware.UserIDKey).(string)
-		if !ok || userID == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		userURLs, err := h.Service.GetURLsByUserID(r.Context(), userID)
+		query := r.URL.Query()
+		req := service.ListRequest{
+			Cursor: query.Get("cursor"),
+			Sort:   query.Get("sort"),
+			Domain: query.Get("domain"),
+			Query:  query.Get("q"),
+			Tag:    query.Get("tag"),
+			Folder: query.Get("folder"),
+		}
+		if limit := query.Get("limit"); limit != "" {
+			if req.Limit, err = strconv.Atoi(limit); err != nil {
+				writeError(w, r, &service.ValidationError{Field: "limit", Code: apierror.CodeInvalidType, Reason: "must be an integer"})
+				return
+			}
+			// Нулевой Limit сервис понимает как размер по умолчанию, явный 0 — ошибка.
+			if req.Limit < 1 {
+				req.Limit = -1
+			}
+		}
+
+		page, err := h.Service.ListUserURLs(r.Context(), userID, req)
 		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 
-		if len(userURLs) == 0 {
+		setNextPage(w, r, page.NextCursor)
+
+		if len(page.URLs) == 0 {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
-		response := make([]UserURLResponse, len(userURLs))
-		for i, urlPair := range userURLs {
-			response[i] = UserURLResponse{
-				ShortURL:    fmt.Sprintf("%s/%s", cfg.BaseURL, urlPair.ShortURL),
-				OriginalURL: urlPair.OriginalURL,
-			}
+		response := make([]UserURLResponse, len(page.URLs))
+		for i, urlPair := range page.URLs {
+			response[i] = newUserURLResponse(cfg, urlPair)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -282,6 +432,18 @@ func (h *Handlers) HandleGetUserURLs(cfg *config.Config) http.HandlerFunc {
 	}
 }
 
+// setNextPage сообщает клиенту курсор следующей страницы в заголовках Link и
+// X-Next-Cursor. Остальные параметры запроса переносятся в ссылку без изменений.
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	if cursor == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+	w.Header().Set("X-Next-Cursor", cursor)
+}
+
 func (h *Handlers) HandlePing() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
@@ -289,10 +451,47 @@ func (h *Handlers) HandlePing() http.HandlerFunc {
 
 		if err := h.Service.Ping(ctx); err != nil {
 			logger.Logger.Error("Database ping failed", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeUnavailable, "Storage is unavailable")
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
 	}
-}
\ No newline at end of file
+}
+
+// countShortened учитывает результат сокращения ссылки в метриках.
+func countShortened(status int) {
+	if status == http.StatusConflict {
+		metrics.LinksShortened.Inc("conflict")
+		return
+	}
+	metrics.LinksShortened.Inc("created")
+}
+
+// readBody читает тело запроса целиком. Ошибка превышения лимита,
+// установленного middleware.BodyLimit, транслируется writeError в 413.
+func readBody(r *http.Request) ([]byte, error) {
+	defer func() {
+		if errClose := r.Body.Close(); errClose != nil {
+			logger.Logger.Error("Error closing request body", zap.Error(errClose))
+		}
+	}()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, err
+		}
+		return nil, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "Failed to read request body")
+	}
+	return body, nil
+}
+
+// userIDFromContext возвращает идентификатор пользователя, установленный middleware.Auth.
+func userIDFromContext(r *http.Request) (string, error) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		return "", errUnauthorized
+	}
+	return userID, nil
+}
@@ -1,22 +1,30 @@
 package handlers_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"shorturl/internal/apierror"
 	"shorturl/internal/config"
 	"shorturl/internal/handlers"
 	"shorturl/internal/logger"
 	"shorturl/internal/middleware"
 	"shorturl/internal/service"
 	"shorturl/internal/storage"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestMain(m *testing.M) {
@@ -33,8 +41,20 @@ func TestMain(m *testing.M) {
 
 // MockURLService заглушка для тестирования, реализует интерфейс service.URLShortener.
 type MockURLService struct {
-	URLs map[string]storage.URLPair
+	URLs            map[string]storage.URLPair
+	History         map[string][]storage.HistoryEntry
 	PingShouldError bool
+	// Clock — часы сервиса, по которым проверяются окно действия ссылок и срок
+	// cookie доступа; nil — time.Now.
+	Clock func() time.Time
+}
+
+// Now возвращает время по часам заглушки.
+func (m *MockURLService) Now() time.Time {
+	if m.Clock != nil {
+		return m.Clock()
+	}
+	return time.Now()
 }
 
 func (m *MockURLService) CreateShortURL(_ context.Context, userID, originalURL string) (string, error) {
@@ -46,11 +66,45 @@ func (m *MockURLService) CreateShortURL(_ context.Context, userID, originalURL s
 func (m *MockURLService) GetOriginalURL(_ context.Context, shortID string) (string, error) {
 	pair, ok := m.URLs[shortID]
 	if !ok {
-		return "", fmt.Errorf("URL not found")
+		return "", service.ErrNotFound
 	}
 	return pair.OriginalURL, nil
 }
 
+// ResolveURL находит ссылку настоящим сервисом, чтобы учитывалось окно действия.
+func (m *MockURLService) ResolveURL(ctx context.Context, shortID string) (storage.Redirect, error) {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return storage.Redirect{}, err
+	}
+	return svc.ResolveURL(ctx, shortID)
+}
+
+// ConsumeClick списывает переход настоящим сервисом и сохраняет остаток в заглушке.
+func (m *MockURLService) ConsumeClick(ctx context.Context, shortID string) error {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return err
+	}
+	if err := svc.ConsumeClick(ctx, shortID); err != nil {
+		return err
+	}
+	if pair := m.URLs[shortID]; pair.MaxClicks > 0 {
+		pair.ClicksLeft--
+		m.URLs[shortID] = pair
+	}
+	return nil
+}
+
+// UnlockURL проверяет пароль настоящим сервисом.
+func (m *MockURLService) UnlockURL(ctx context.Context, shortID, password string) (storage.Redirect, error) {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return storage.Redirect{}, err
+	}
+	return svc.UnlockURL(ctx, shortID, password)
+}
+
 func (m *MockURLService) GetURLsByUserID(_ context.Context, userID string) ([]storage.URLPair, error) {
 	var result []storage.URLPair
 	for _, pair := range m.URLs {
@@ -61,6 +115,102 @@ func (m *MockURLService) GetURLsByUserID(_ context.Context, userID string) ([]st
 	return result, nil
 }
 
+func (m *MockURLService) ForEachUserURL(ctx context.Context, userID string, fn func(storage.URLPair) error) error {
+	urls, _ := m.GetURLsByUserID(ctx, userID)
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ShortURL < urls[j].ShortURL })
+	for _, pair := range urls {
+		if err := fn(pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockURLService) CreateURL(_ context.Context, userID string, req service.CreateRequest) (string, error) {
+	shortID := generateMockShortID()
+	m.URLs[shortID] = storage.URLPair{UserID: userID, OriginalURL: req.OriginalURL, ShortURL: shortID, Metadata: req.Metadata,
+		MaxClicks: req.MaxClicks, ClicksLeft: req.MaxClicks}
+	return shortID, nil
+}
+
+// memoryService загружает ссылки заглушки в InMemoryStorage и возвращает
+// настоящий сервис поверх него.
+func (m *MockURLService) memoryService(ctx context.Context) (*service.URLService, error) {
+	store := storage.NewInMemoryStorage()
+	for shortID, pair := range m.URLs {
+		pair.ShortURL = shortID
+		if err := store.ImportURL(ctx, pair, false); err != nil {
+			return nil, err
+		}
+	}
+	svc := service.NewURLService(store, nil)
+	if m.Clock != nil {
+		svc.SetClock(m.Clock)
+	}
+	return svc, nil
+}
+
+// ListUserURLs постранично выбирает ссылки заглушки настоящим сервисом.
+func (m *MockURLService) ListUserURLs(ctx context.Context, userID string, req service.ListRequest) (service.ListPage, error) {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return service.ListPage{}, err
+	}
+	return svc.ListUserURLs(ctx, userID, req)
+}
+
+// SearchUserURLs ищет по ссылкам заглушки настоящим сервисом.
+func (m *MockURLService) SearchUserURLs(ctx context.Context, userID string, req service.SearchRequest) (service.SearchPage, error) {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return service.SearchPage{}, err
+	}
+	return svc.SearchUserURLs(ctx, userID, req)
+}
+
+// UpdateURLMetadata меняет метаданные настоящим сервисом и сохраняет результат в заглушке.
+func (m *MockURLService) UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (storage.URLPair, error) {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return storage.URLPair{}, err
+	}
+	pair, err := svc.UpdateURLMetadata(ctx, userID, shortID, patch)
+	if err != nil {
+		return storage.URLPair{}, err
+	}
+	m.URLs[shortID] = pair
+	return pair, nil
+}
+
+// UpdateOriginalURL меняет адрес настоящим сервисом и сохраняет результат в
+// заглушке; история хранится в History.
+func (m *MockURLService) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (storage.URLPair, error) {
+	svc, err := m.memoryService(ctx)
+	if err != nil {
+		return storage.URLPair{}, err
+	}
+	for id, pair := range m.URLs {
+		if pair.OriginalURL == originalURL && id != shortID {
+			return storage.URLPair{}, service.NewErrConflict(id)
+		}
+	}
+	old := m.URLs[shortID]
+	pair, err := svc.UpdateOriginalURL(ctx, userID, shortID, originalURL)
+	if err != nil {
+		return storage.URLPair{}, err
+	}
+	m.URLs[shortID] = pair
+	m.History[shortID] = append(m.History[shortID], storage.HistoryEntry{ChangedBy: userID, OldURL: old.OriginalURL, NewURL: originalURL})
+	return pair, nil
+}
+
+func (m *MockURLService) GetURLHistory(_ context.Context, userID, shortID string) ([]storage.HistoryEntry, error) {
+	if pair, ok := m.URLs[shortID]; !ok || pair.UserID != userID {
+		return nil, service.ErrNotFound
+	}
+	return m.History[shortID], nil
+}
+
 func (m *MockURLService) Ping(_ context.Context) error {
 	if m.PingShouldError {
 		return fmt.Errorf("ping error")
@@ -69,7 +219,7 @@ func (m *MockURLService) Ping(_ context.Context) error {
 }
 
 func NewMockURLService() *MockURLService {
-	return &MockURLService{URLs: make(map[string]storage.URLPair)}
+	return &MockURLService{URLs: make(map[string]storage.URLPair), History: make(map[string][]storage.HistoryEntry)}
 }
 
 func NewHandlers(svc service.URLShortener) *handlers.Handlers {
@@ -191,7 +341,7 @@ func TestHandleGet(t *testing.T) {
 	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
 	req = req.WithContext(ctx)
 
-	h.HandleGet().ServeHTTP(rr, req)
+	h.HandleGet(&config.Config{}).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusTemporaryRedirect {
 		t.Errorf("Expected %d, got %d", http.StatusTemporaryRedirect, rr.Code)
@@ -201,3 +351,901 @@ func TestHandleGet(t *testing.T) {
 		t.Errorf("Invalid Location header")
 	}
 }
+
+// TestRequestLimits проверяет ограничения размера тела, количества элементов пакета и Content-Type.
+func TestRequestLimits(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080", MaxBodyBytes: 512, MaxBatchSize: 2}
+	mockSvc := NewMockURLService()
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Use(middleware.GzipRequest)
+	router.Use(middleware.BodyLimit(cfg.MaxBodyBytes))
+	router.Post("/", h.HandlePost(cfg))
+	router.With(middleware.RequireContentType("application/json")).Post("/api/shorten", h.HandleAPIShorten(cfg))
+	router.With(middleware.RequireContentType("application/json")).Post("/api/shorten/batch", h.HandleAPIShortenBatch(cfg))
+
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	_, _ = gz.Write([]byte(`{"url": "http://example.com/` + strings.Repeat("a", 1<<20) + `"}`))
+	_ = gz.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		contentType  string
+		gzip         bool
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:         "Body too large",
+			path:         "/",
+			contentType:  "text/plain",
+			body:         "http://example.com/" + strings.Repeat("a", 1000),
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedErr:  apierror.CodeBodyTooLarge,
+		},
+		{
+			name:         "Gzip bomb",
+			path:         "/api/shorten",
+			contentType:  "application/json",
+			gzip:         true,
+			body:         bomb.String(),
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedErr:  apierror.CodeBodyTooLarge,
+		},
+		{
+			name:         "Non-JSON content type",
+			path:         "/api/shorten",
+			contentType:  "text/plain",
+			body:         `{"url": "http://example.com"}`,
+			expectedCode: http.StatusUnsupportedMediaType,
+			expectedErr:  apierror.CodeUnsupportedMediaType,
+		},
+		{
+			name:         "JSON content type with charset",
+			path:         "/api/shorten",
+			contentType:  "application/json; charset=utf-8",
+			body:         `{"url": "http://example.com"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Batch too large",
+			path:         "/api/shorten/batch",
+			contentType:  "application/json",
+			body:         `[{"correlation_id":"1","original_url":"http://a.io"},{"correlation_id":"2","original_url":"http://b.io"},{"correlation_id":"3","original_url":"http://c.io"}]`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedErr:  apierror.CodeBatchTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d (%s)", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tt.expectedErr == "" {
+				return
+			}
+			var apiErr apierror.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &apiErr); err != nil {
+				t.Fatalf("Failed to unmarshal error response: %v", err)
+			}
+			if apiErr.Code != tt.expectedErr {
+				t.Errorf("Expected error code %s, got %s", tt.expectedErr, apiErr.Code)
+			}
+		})
+	}
+}
+
+// TestProblemResponses проверяет формат ошибок application/problem+json.
+func TestProblemResponses(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Use(chiMiddleware.RequestID)
+	router.Post("/api/shorten/batch", h.HandleAPIShortenBatch(cfg))
+	router.Get("/{shortID}", h.HandleGet(cfg))
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedCode   int
+		expectedErr    string
+		expectedFields []string
+	}{
+		{
+			// Статус перехода по неизвестной ссылке сохранен с первых версий.
+			name:         "Unknown short URL",
+			method:       http.MethodGet,
+			path:         "/unknown1",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  apierror.CodeNotFound,
+		},
+		{
+			name:           "Invalid batch items",
+			method:         http.MethodPost,
+			path:           "/api/shorten/batch",
+			body:           `[{"correlation_id":"1","original_url":"http://a.io"},{"correlation_id":"2","original_url":"b.io"},{"correlation_id":"3","original_url":"ftp://c.io"}]`,
+			expectedCode:   http.StatusBadRequest,
+			expectedErr:    apierror.CodeValidation,
+			expectedFields: []string{"[1].original_url", "[2].original_url"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != apierror.ContentType {
+				t.Errorf("Expected Content-Type %s, got %s", apierror.ContentType, ct)
+			}
+			var problem apierror.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("Failed to unmarshal problem: %v", err)
+			}
+			if problem.Code != tt.expectedErr || problem.Status != tt.expectedCode {
+				t.Errorf("Unexpected problem: %+v", problem)
+			}
+			if problem.RequestID == "" {
+				t.Errorf("Expected request_id to be set")
+			}
+			if len(problem.Errors) != len(tt.expectedFields) {
+				t.Fatalf("Expected %d field errors, got %+v", len(tt.expectedFields), problem.Errors)
+			}
+			for i, field := range tt.expectedFields {
+				if problem.Errors[i].Field != field {
+					t.Errorf("Expected field %s, got %s", field, problem.Errors[i].Field)
+				}
+			}
+		})
+	}
+}
+
+// TestHandleExportUserURLs проверяет выгрузку ссылок пользователя во всех форматах.
+func TestHandleExportUserURLs(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockSvc.URLs = map[string]storage.URLPair{
+		"aaaaaaaa": {ShortURL: "aaaaaaaa", OriginalURL: "http://a.io/?x=1,2", UserID: "test-user", CreatedAt: created},
+		"bbbbbbbb": {ShortURL: "bbbbbbbb", OriginalURL: "http://b.io", UserID: "test-user",
+			Metadata: storage.Metadata{Title: "B", Tags: []string{"go", "news"}, Folder: "work"}},
+		"cccccccc": {ShortURL: "cccccccc", OriginalURL: "http://c.io", UserID: "other-user"},
+	}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/api/user/urls/export", h.HandleExportUserURLs(cfg))
+
+	tests := []struct {
+		query        string
+		expectedCode int
+		expectedType string
+		expectedBody string
+	}{
+		{
+			query:        "",
+			expectedCode: http.StatusOK,
+			expectedType: "application/json",
+			expectedBody: `[{"short_url":"http://localhost:8080/aaaaaaaa","original_url":"http://a.io/?x=1,2","created_at":"2025-03-01T12:00:00Z"},` +
+				`{"short_url":"http://localhost:8080/bbbbbbbb","original_url":"http://b.io","title":"B","tags":["go","news"],"folder":"work"}]` + "\n",
+		},
+		{
+			query:        "?format=jsonl",
+			expectedCode: http.StatusOK,
+			expectedType: "application/x-ndjson",
+			expectedBody: `{"short_url":"http://localhost:8080/aaaaaaaa","original_url":"http://a.io/?x=1,2","created_at":"2025-03-01T12:00:00Z"}` + "\n" +
+				`{"short_url":"http://localhost:8080/bbbbbbbb","original_url":"http://b.io","title":"B","tags":["go","news"],"folder":"work"}` + "\n",
+		},
+		{
+			query:        "?format=csv",
+			expectedCode: http.StatusOK,
+			expectedType: "text/csv; charset=utf-8",
+			expectedBody: "short_url,original_url,created_at,title,tags,notes,folder\n" +
+				"http://localhost:8080/aaaaaaaa,\"http://a.io/?x=1,2\",2025-03-01T12:00:00Z,,,,\n" +
+				"http://localhost:8080/bbbbbbbb,http://b.io,,B,\"go,news\",,work\n",
+		},
+		{
+			query:        "?format=xml",
+			expectedCode: http.StatusBadRequest,
+			expectedType: apierror.ContentType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != tt.expectedType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedType, ct)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+				t.Errorf("Expected attachment, got %q", cd)
+			}
+			if rr.Body.String() != tt.expectedBody {
+				t.Errorf("Unexpected body:\n%s\nexpected:\n%s", rr.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
+
+// TestHandleGetUserURLsPagination проверяет постраничный список ссылок пользователя.
+func TestHandleGetUserURLsPagination(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"aaaaaaaa", "bbbbbbbb", "cccccccc"} {
+		mockSvc.URLs[id] = storage.URLPair{ShortURL: id, OriginalURL: "https://example.com/" + id, UserID: "test-user", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+	}
+	mockSvc.URLs["dddddddd"] = storage.URLPair{ShortURL: "dddddddd", OriginalURL: "https://go.dev/doc", UserID: "test-user", CreatedAt: base.Add(time.Hour),
+		Preview: storage.Preview{Title: "Documentation", FaviconURL: "https://go.dev/favicon.ico", FetchedAt: base},
+		Health:  storage.LinkHealth{Status: 404, LatencyMS: 15, CheckedAt: base, Failures: 3, DeadSince: base}}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/api/user/urls", h.HandleGetUserURLs(cfg))
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	shortIDs := func(rr *httptest.ResponseRecorder) []string {
+		var urls []handlers.UserURLResponse
+		if err := json.NewDecoder(rr.Body).Decode(&urls); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		ids := make([]string, len(urls))
+		for i, u := range urls {
+			ids[i] = strings.TrimPrefix(u.ShortURL, cfg.BaseURL+"/")
+		}
+		return ids
+	}
+
+	// Обходим все ссылки example.com от старых к новым по две за страницу.
+	var seen []string
+	target := "/api/user/urls?limit=2&sort=created_at&domain=example.com"
+	for pages := 0; target != ""; pages++ {
+		if pages > 2 {
+			t.Fatalf("Expected pagination to stop, got %v", seen)
+		}
+		rr := get(target)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		seen = append(seen, shortIDs(rr)...)
+		target = ""
+		if link := rr.Header().Get("Link"); link != "" {
+			if !strings.HasSuffix(link, `>; rel="next"`) || !strings.Contains(link, "cursor="+rr.Header().Get("X-Next-Cursor")) {
+				t.Fatalf("Unexpected Link header %q", link)
+			}
+			target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	if strings.Join(seen, ",") != "aaaaaaaa,bbbbbbbb,cccccccc" {
+		t.Errorf("Expected example.com links oldest first, got %v", seen)
+	}
+
+	rr := get("/api/user/urls?limit=1")
+	if ids := shortIDs(rr); len(ids) != 1 || ids[0] != "dddddddd" {
+		t.Errorf("Expected newest link first by default, got %v", ids)
+	}
+
+	// Загруженные в фоне сведения о странице попадают в список.
+	var urls []handlers.UserURLResponse
+	if err := json.NewDecoder(get("/api/user/urls?limit=1").Body).Decode(&urls); err != nil || len(urls) != 1 ||
+		urls[0].Preview == nil || urls[0].Preview.Title != "Documentation" || !urls[0].Preview.FetchedAt.Equal(base) {
+		t.Errorf("Expected page preview in the list, got %+v, %v", urls, err)
+	}
+	if rr := get("/api/user/urls?limit=1&sort=created_at"); strings.Contains(rr.Body.String(), `"preview"`) {
+		t.Errorf("Expected no preview for a link that was not fetched, got %s", rr.Body.String())
+	}
+	// Так же попадает и результат проверки адреса назначения.
+	if h := urls[0].Health; h == nil || h.Status != 404 || h.LatencyMS != 15 || !h.Dead || !h.DeadSince.Equal(base) {
+		t.Errorf("Expected dead destination health in the list, got %+v", h)
+	}
+	if rr := get("/api/user/urls?limit=1&sort=created_at"); strings.Contains(rr.Body.String(), `"health"`) {
+		t.Errorf("Expected no health for a link that was not checked, got %s", rr.Body.String())
+	}
+
+	if rr := get("/api/user/urls?q=nothing"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d for empty result, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	for _, query := range []string{"limit=abc", "limit=0", "limit=5000", "cursor=%21%21", "sort=name"} {
+		rr := get("/api/user/urls?" + query)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != apierror.ContentType {
+			t.Errorf("%s: expected Content-Type %s, got %s", query, apierror.ContentType, ct)
+		}
+	}
+}
+
+// TestHandleSearchUserURLs проверяет поиск по ссылкам пользователя.
+func TestHandleSearchUserURLs(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockSvc.URLs = map[string]storage.URLPair{
+		"aaaaaaaa": {ShortURL: "aaaaaaaa", OriginalURL: "https://go.dev/doc", UserID: "test-user", CreatedAt: base},
+		"bbbbbbbb": {ShortURL: "bbbbbbbb", OriginalURL: "https://example.com/gophers", UserID: "test-user", CreatedAt: base.Add(time.Minute)},
+		"cccccccc": {ShortURL: "cccccccc", OriginalURL: "https://go.dev/blog", UserID: "test-user", CreatedAt: base.Add(2 * time.Minute)},
+		"dddddddd": {ShortURL: "dddddddd", OriginalURL: "https://go.dev/other", UserID: "other-user", CreatedAt: base},
+	}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/api/user/urls/search", h.HandleSearchUserURLs(cfg))
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) []handlers.UserURLSearchResult {
+		var results []handlers.UserURLSearchResult
+		if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return results
+	}
+
+	rr := get("/api/user/urls/search?q=go&limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	results := decode(rr)
+	if len(results) != 2 || results[0].ShortURL != cfg.BaseURL+"/cccccccc" || results[1].ShortURL != cfg.BaseURL+"/aaaaaaaa" {
+		t.Fatalf("Expected exact matches newest first, got %+v", results)
+	}
+	if results[0].Score <= 0 || results[0].CreatedAt.IsZero() {
+		t.Errorf("Expected score and created_at in result, got %+v", results[0])
+	}
+	cursor := rr.Header().Get("X-Next-Cursor")
+	if cursor == "" || !strings.Contains(rr.Header().Get("Link"), "q=go") {
+		t.Fatalf("Expected next page headers, got Link %q", rr.Header().Get("Link"))
+	}
+
+	rr = get("/api/user/urls/search?q=go&limit=2&cursor=" + cursor)
+	if results := decode(rr); len(results) != 1 || results[0].ShortURL != cfg.BaseURL+"/bbbbbbbb" || rr.Header().Get("Link") != "" {
+		t.Errorf("Expected last page with the prefix match, got %+v", results)
+	}
+
+	rr = get("/api/user/urls/search?q=python")
+	if rr.Code != http.StatusOK || len(decode(rr)) != 0 {
+		t.Errorf("Expected empty array for no matches, got %d", rr.Code)
+	}
+
+	for _, query := range []string{"", "q=%20", "q=%3F%21", "q=go&limit=0", "q=go&cursor=abc"} {
+		rr := get("/api/user/urls/search?" + query)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+// TestHandleUpdateUserURL проверяет создание ссылки с метаданными, их
+// изменение и фильтр списка по тегу.
+func TestHandleUpdateUserURL(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	mockSvc.URLs["otherurl"] = storage.URLPair{ShortURL: "otherurl", OriginalURL: "https://example.com/other", UserID: "other-user"}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Post("/api/shorten", h.HandleAPIShorten(cfg))
+	router.Get("/api/user/urls", h.HandleGetUserURLs(cfg))
+	router.Patch("/api/user/urls/{id}", h.HandleUpdateUserURL(cfg))
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/shorten", `{"url":"https://go.dev","title":"Go","tags":["lang"],"folder":"dev"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	if got := mockSvc.URLs["mockID01"].Metadata; got.Title != "Go" || got.Folder != "dev" || len(got.Tags) != 1 {
+		t.Errorf("Expected metadata to reach the service, got %+v", got)
+	}
+
+	rr = do(http.MethodPatch, "/api/user/urls/mockID01", `{"tags":[" Go ","news","go"],"notes":"weekly"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var updated handlers.UserURLResponse
+	if err := json.NewDecoder(rr.Body).Decode(&updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if updated.ShortURL != cfg.BaseURL+"/mockID01" || updated.Title != "Go" || updated.Notes != "weekly" ||
+		strings.Join(updated.Tags, ",") != "go,news" || updated.Folder != "dev" {
+		t.Errorf("Unexpected updated link: %+v", updated)
+	}
+
+	rr = do(http.MethodGet, "/api/user/urls?tag=NEWS", "")
+	var urls []handlers.UserURLResponse
+	if err := json.NewDecoder(rr.Body).Decode(&urls); err != nil || len(urls) != 1 || urls[0].Notes != "weekly" {
+		t.Errorf("Expected link filtered by tag, got %+v, %v", urls, err)
+	}
+	if rr := do(http.MethodGet, "/api/user/urls?folder=home", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected no links in another folder, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPatch, "/api/user/urls/mockID01", `{"fallback_url":" https://go.dev/archive "}`)
+	if rr.Code != http.StatusOK || mockSvc.URLs["mockID01"].FallbackURL != "https://go.dev/archive" {
+		t.Errorf("Expected fallback URL to be set, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPatch, "/api/user/urls/mockID01", `{"fallback_url":""}`); rr.Code != http.StatusOK ||
+		mockSvc.URLs["mockID01"].FallbackURL != "" || strings.Contains(rr.Body.String(), "fallback_url") {
+		t.Errorf("Expected empty fallback URL to clear it, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		body         string
+		expectedCode int
+	}{
+		{"another user's link", "/api/user/urls/otherurl", `{"title":"mine"}`, http.StatusNotFound},
+		{"missing link", "/api/user/urls/missing1", `{"title":"x"}`, http.StatusNotFound},
+		{"invalid JSON", "/api/user/urls/mockID01", `{"title":`, http.StatusBadRequest},
+		{"comma in tag", "/api/user/urls/mockID01", `{"tags":["a,b"]}`, http.StatusBadRequest},
+		{"invalid fallback URL", "/api/user/urls/mockID01", `{"fallback_url":"ftp://example.com"}`, http.StatusBadRequest},
+		{"title too long", "/api/user/urls/mockID01", `{"title":"` + strings.Repeat("x", service.MaxTitleLength+1) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rr := do(http.MethodPatch, tt.target, tt.body); rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expectedCode, rr.Code)
+		}
+	}
+	if mockSvc.URLs["otherurl"].Title != "" {
+		t.Errorf("Expected another user's link to stay unchanged")
+	}
+}
+
+// TestHandleUpdateOriginalURL проверяет смену адреса назначения и историю изменений.
+func TestHandleUpdateOriginalURL(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	mockSvc.URLs["aaaaaaaa"] = storage.URLPair{ShortURL: "aaaaaaaa", OriginalURL: "https://example.com/old", UserID: "test-user"}
+	mockSvc.URLs["bbbbbbbb"] = storage.URLPair{ShortURL: "bbbbbbbb", OriginalURL: "https://example.com/taken", UserID: "other-user"}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Put("/api/user/urls/{id}", h.HandleUpdateOriginalURL(cfg))
+	router.Get("/api/user/urls/{id}/history", h.HandleGetURLHistory())
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, "/api/user/urls/aaaaaaaa/history", "")
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("Expected empty history array, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do(http.MethodPut, "/api/user/urls/aaaaaaaa", `{"url":"https://example.com/new"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var updated handlers.UserURLResponse
+	if err := json.NewDecoder(rr.Body).Decode(&updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if updated.ShortURL != cfg.BaseURL+"/aaaaaaaa" || updated.OriginalURL != "https://example.com/new" {
+		t.Errorf("Unexpected updated link: %+v", updated)
+	}
+
+	rr = do(http.MethodGet, "/api/user/urls/aaaaaaaa/history", "")
+	var history []storage.HistoryEntry
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	if len(history) != 1 || history[0].OldURL != "https://example.com/old" || history[0].NewURL != "https://example.com/new" ||
+		history[0].ChangedBy != "test-user" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		expectedCode int
+	}{
+		{"URL of another link", http.MethodPut, "/api/user/urls/aaaaaaaa", `{"url":"https://example.com/taken"}`, http.StatusConflict},
+		{"another user's link", http.MethodPut, "/api/user/urls/bbbbbbbb", `{"url":"https://example.com/mine"}`, http.StatusNotFound},
+		{"invalid URL", http.MethodPut, "/api/user/urls/aaaaaaaa", `{"url":"ftp://example.com"}`, http.StatusBadRequest},
+		{"invalid JSON", http.MethodPut, "/api/user/urls/aaaaaaaa", `{"url":`, http.StatusBadRequest},
+		{"another user's history", http.MethodGet, "/api/user/urls/bbbbbbbb/history", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.method, tt.target, tt.body); rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expectedCode, rr.Code)
+		}
+	}
+}
+
+// TestHandleQRCode проверяет форматы QR-кода, проверку параметров и ETag.
+func TestHandleQRCode(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	mockSvc.URLs["aaaaaaaa"] = storage.URLPair{ShortURL: "aaaaaaaa", OriginalURL: "https://example.com"}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/{shortID}/qr", h.HandleQRCode(cfg))
+	do := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("/aaaaaaaa/qr?size=128", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected PNG, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("\x89PNG")) {
+		t.Errorf("Expected PNG signature in body")
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected ETag header")
+	}
+
+	if rr := do("/aaaaaaaa/qr?size=128", etag); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected empty 304, got %d with %d bytes", rr.Code, rr.Body.Len())
+	}
+	if rr := do("/aaaaaaaa/qr?size=256", etag); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for other size, got %d", rr.Code)
+	}
+
+	rr = do("/aaaaaaaa/qr?format=svg&level=H&margin=0", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(rr.Body.String(), "<svg") {
+		t.Errorf("Expected SVG, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		expectedCode int
+	}{
+		{"unknown link", "/bbbbbbbb/qr", http.StatusNotFound},
+		{"invalid short ID", "/abc/qr", http.StatusBadRequest},
+		{"unknown format", "/aaaaaaaa/qr?format=gif", http.StatusBadRequest},
+		{"unknown level", "/aaaaaaaa/qr?level=X", http.StatusBadRequest},
+		{"size too large", "/aaaaaaaa/qr?size=4096", http.StatusBadRequest},
+		{"size not a number", "/aaaaaaaa/qr?size=big", http.StatusBadRequest},
+		{"negative margin", "/aaaaaaaa/qr?margin=-1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.target, ""); rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expectedCode, rr.Code)
+		}
+	}
+}
+
+// TestPasswordProtectedLink проверяет форму пароля, cookie доступа и ограничение попыток.
+func TestPasswordProtectedLink(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	cfg := &config.Config{BaseURL: "http://localhost:8080", UnlockTTL: time.Minute,
+		PasswordAttempts: 3, PasswordLinkAttempts: 5, PasswordAttemptWindow: time.Minute}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockSvc := NewMockURLService()
+	mockSvc.Clock = func() time.Time { return now }
+	mockSvc.URLs["aaaaaaaa"] = storage.URLPair{ShortURL: "aaaaaaaa", OriginalURL: "https://docs.internal/page", PasswordHash: string(hash)}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/{shortID}", h.HandleGet(cfg))
+	router.Post("/{shortID}", h.HandleUnlock(cfg))
+	get := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/aaaaaaaa", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	post := func(target, remoteAddr, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("password="+password))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get()
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(rr.Body.String(), `action="/aaaaaaaa"`) {
+		t.Fatalf("Expected password form, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("Location") != "" {
+		t.Errorf("Expected no redirect without password")
+	}
+
+	if rr := post("/aaaaaaaa", "", "wrong"); rr.Code != http.StatusUnauthorized || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected 401 without cookie for wrong password, got %d", rr.Code)
+	}
+
+	rr = post("/aaaaaaaa", "", "s3cret")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "https://docs.internal/page" {
+		t.Fatalf("Expected redirect after correct password, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].Path != "/aaaaaaaa" || cookies[0].Secure {
+		t.Fatalf("Expected one HttpOnly unlock cookie without Secure over http, got %+v", cookies)
+	}
+	if want := now.Add(cfg.UnlockTTL); !cookies[0].Expires.Equal(want) {
+		t.Errorf("Expected cookie to expire at %s by the service clock, got %s", want, cookies[0].Expires)
+	}
+
+	if rr := get(cookies[0]); rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected redirect with unlock cookie, got %d", rr.Code)
+	}
+	forged := *cookies[0]
+	exp, _, _ := strings.Cut(forged.Value, "|")
+	forged.Value = exp + "|" + strings.Repeat("0", 64)
+	if rr := get(&forged); rr.Code != http.StatusOK {
+		t.Errorf("Expected password form for forged cookie, got %d", rr.Code)
+	}
+
+	// Третья попытка с адреса исчерпывает его лимит, дальше — 429 даже с верным паролем.
+	if rr := post("/aaaaaaaa", "", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rr.Code)
+	}
+	rr = post("/aaaaaaaa", "", "s3cret")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	// Другой адрес получает свои попытки, но не больше общего лимита ссылки.
+	for i := range 2 {
+		if rr := post("/aaaaaaaa", "203.0.113.7:4242", "wrong"); rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for attempt %d from another address, got %d", i+1, rr.Code)
+		}
+	}
+	if rr := post("/aaaaaaaa", "203.0.113.8:4242", "s3cret"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 once the link budget is spent, got %d", rr.Code)
+	}
+
+	// По истечении окна попытки снова разрешены, а cookie — по истечении UnlockTTL.
+	now = now.Add(cfg.UnlockTTL)
+	if rr := get(cookies[0]); rr.Code != http.StatusOK {
+		t.Errorf("Expected password form after the cookie expires, got %d", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "https://short.example/aaaaaaaa", strings.NewReader("password=s3cret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if cookies := rr.Result().Cookies(); rr.Code != http.StatusSeeOther || len(cookies) != 1 || !cookies[0].Secure {
+		t.Errorf("Expected a Secure unlock cookie over TLS, got %d %+v", rr.Code, cookies)
+	}
+
+	// Ссылка без пароля открывается сразу.
+	mockSvc.URLs["bbbbbbbb"] = storage.URLPair{ShortURL: "bbbbbbbb", OriginalURL: "https://example.com"}
+	req = httptest.NewRequest(http.MethodGet, "/bbbbbbbb", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected redirect for open link, got %d", rr.Code)
+	}
+}
+
+// TestPasswordLimiterFlood проверяет, что поток попыток на несуществующие
+// ссылки не сбрасывает счетчик попыток настоящей.
+func TestPasswordLimiterFlood(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	cfg := &config.Config{BaseURL: "http://localhost:8080", UnlockTTL: time.Minute,
+		PasswordAttempts: 2, PasswordLinkAttempts: 10, PasswordAttemptWindow: time.Hour}
+	mockSvc := NewMockURLService()
+	mockSvc.URLs["aaaaaaaa"] = storage.URLPair{ShortURL: "aaaaaaaa", OriginalURL: "https://docs.internal/page", PasswordHash: string(hash)}
+	h := NewHandlers(mockSvc)
+	router := chi.NewRouter()
+	router.Post("/{shortID}", h.HandleUnlock(cfg))
+	post := func(shortID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/"+shortID, strings.NewReader("password=wrong"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for range 2 {
+		post("aaaaaaaa")
+	}
+	if code := post("aaaaaaaa"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 after the attempts are spent, got %d", code)
+	}
+	for i := range 10050 {
+		if code := post(fmt.Sprintf("j%07d", i)); code != http.StatusBadRequest {
+			t.Fatalf("Expected 400 for a junk ID, got %d", code)
+		}
+	}
+	if code := post("aaaaaaaa"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the target link to stay blocked after the flood, got %d", code)
+	}
+}
+
+func TestClickLimitedLink(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	mockSvc.URLs["aaaaaaaa"] = storage.URLPair{ShortURL: "aaaaaaaa", OriginalURL: "https://example.com/invite",
+		UserID: "user1", MaxClicks: 2, ClicksLeft: 2}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/{shortID}", h.HandleGet(cfg))
+	router.Get("/api/user/urls", h.HandleGetUserURLs(cfg))
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/aaaaaaaa", nil))
+		return rr
+	}
+
+	for i := range 2 {
+		if rr := get(); rr.Code != http.StatusTemporaryRedirect || rr.Header().Get("Location") != "https://example.com/invite" {
+			t.Fatalf("Click %d: expected redirect, got %d", i+1, rr.Code)
+		}
+	}
+	rr := get()
+	if rr.Code != http.StatusGone {
+		t.Fatalf("Expected 410 after clicks are exhausted, got %d", rr.Code)
+	}
+	var problem apierror.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil || problem.Code != apierror.CodeGone {
+		t.Errorf("Expected %q problem, got %+v (%v)", apierror.CodeGone, problem, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var urls []handlers.UserURLResponse
+	if err := json.NewDecoder(rr.Body).Decode(&urls); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(urls) != 1 || urls[0].MaxClicks != 2 || urls[0].ClicksLeft == nil || *urls[0].ClicksLeft != 0 {
+		t.Errorf("Expected max_clicks 2 and clicks_left 0 in listing, got %+v", urls)
+	}
+}
+
+func TestScheduledLink(t *testing.T) {
+	launch := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := launch.Add(-time.Hour)
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	mockSvc := NewMockURLService()
+	mockSvc.Clock = func() time.Time { return now }
+	mockSvc.URLs["aaaaaaaa"] = storage.URLPair{OriginalURL: "https://example.com/sale", UserID: "user1",
+		Metadata: storage.Metadata{NotBefore: launch, NotAfter: launch.Add(24 * time.Hour)}}
+	h := NewHandlers(mockSvc)
+
+	router := chi.NewRouter()
+	router.Get("/{shortID}", h.HandleGet(cfg))
+	router.Patch("/api/user/urls/{id}", h.HandleUpdateUserURL(cfg))
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/aaaaaaaa", nil))
+		return rr
+	}
+
+	if rr := get(); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 like an unknown link before launch, got %d", rr.Code)
+	}
+	cfg.ComingSoon = true
+	rr := get()
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), launch.Format(time.RFC3339)) {
+		t.Errorf("Expected coming soon page with launch time, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	now = launch
+	if rr := get(); rr.Code != http.StatusTemporaryRedirect || rr.Header().Get("Location") != "https://example.com/sale" {
+		t.Errorf("Expected redirect at launch, got %d", rr.Code)
+	}
+	now = launch.Add(24 * time.Hour)
+	if rr := get(); rr.Code != http.StatusGone {
+		t.Errorf("Expected 410 after the window closes, got %d", rr.Code)
+	}
+
+	// Владелец продлевает окно, и ссылка снова открывается.
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/aaaaaaaa", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	rr = patch(`{"not_after": "2025-03-10T09:00:00+03:00"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for window update, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var updated handlers.UserURLResponse
+	if err := json.NewDecoder(rr.Body).Decode(&updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := time.Date(2025, 3, 10, 6, 0, 0, 0, time.UTC); !updated.NotAfter.Equal(want) || !updated.NotBefore.Equal(launch) {
+		t.Errorf("Expected window %s..%s, got %s..%s", launch, want, updated.NotBefore, updated.NotAfter)
+	}
+	if rr := get(); rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected redirect after extending the window, got %d", rr.Code)
+	}
+
+	if rr := patch(`{"not_before": "tomorrow"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid timestamp, got %d", rr.Code)
+	}
+	if rr := patch(`{"not_before": "2025-03-05T00:00:00Z", "not_after": "2025-03-04T00:00:00Z"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty window, got %d", rr.Code)
+	}
+	// Одна граница проверяется вместе с сохраненной второй.
+	rr = patch(`{"not_after": "2000-01-01T00:00:00Z"}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"not_after"`) {
+		t.Errorf("Expected 400 on not_after before the stored not_before, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := patch(`{"not_before": "2030-01-01T00:00:00Z"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 on not_before after the stored not_after, got %d", rr.Code)
+	}
+	if !mockSvc.URLs["aaaaaaaa"].NotBefore.Equal(launch) {
+		t.Errorf("Expected rejected updates to keep the window, got %+v", mockSvc.URLs["aaaaaaaa"].Metadata)
+	}
+	// Пустая строка снимает ограничение.
+	if rr := patch(`{"not_after": ""}`); rr.Code != http.StatusOK || !mockSvc.URLs["aaaaaaaa"].NotAfter.IsZero() {
+		t.Errorf("Expected not_after to be cleared, got %d %+v", rr.Code, mockSvc.URLs["aaaaaaaa"].Metadata)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// UpdateOriginalURLRequest — тело PUT /api/user/urls/{id}.
+type UpdateOriginalURLRequest struct {
+	URL string `json:"url"`
+}
+
+// HandleUpdateOriginalURL меняет адрес назначения ссылки текущего пользователя
+// и возвращает ее новое состояние. Короткий URL не меняется, поэтому уже
+// распространенные ссылки и QR-коды начинают вести на новый адрес.
+func (h *Handlers) HandleUpdateOriginalURL(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		var req UpdateOriginalURLRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		pair, err := h.Service.UpdateOriginalURL(r.Context(), userID, chi.URLParam(r, "id"), req.URL)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(newUserURLResponse(cfg, pair)); err != nil {
+			logger.Logger.Error("Error writing JSON response for retargeted user URL", zap.Error(err))
+		}
+	}
+}
+
+// HandleGetURLHistory возвращает историю смены адресов ссылки текущего
+// пользователя от старых изменений к новым; у неизменявшейся ссылки — пустой массив.
+func (h *Handlers) HandleGetURLHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		entries, err := h.Service.GetURLHistory(r.Context(), userID, chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if entries == nil {
+			entries = []storage.HistoryEntry{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			logger.Logger.Error("Error writing JSON response for URL history", zap.Error(err))
+		}
+	}
+}
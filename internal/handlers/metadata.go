@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// UpdateUserURLRequest — тело PATCH /api/user/urls/{id}. Переданные поля
+// заменяют метаданные ссылки, пропущенные остаются прежними; пустая строка
+// или пустой список тегов очищают поле. Окно действия NotBefore и NotAfter
+// передается в формате RFC 3339.
+type UpdateUserURLRequest struct {
+	Title       *string   `json:"title"`
+	Tags        *[]string `json:"tags"`
+	Notes       *string   `json:"notes"`
+	Folder      *string   `json:"folder"`
+	FallbackURL *string   `json:"fallback_url"`
+	NotBefore   *string   `json:"not_before"`
+	NotAfter    *string   `json:"not_after"`
+}
+
+// HandleUpdateUserURL изменяет метаданные ссылки текущего пользователя и
+// возвращает ее новое состояние. Чужая ссылка неотличима от отсутствующей.
+func (h *Handlers) HandleUpdateUserURL(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		var req UpdateUserURLRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "Request body is not valid JSON")
+			return
+		}
+
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		patch := storage.MetadataPatch{
+			Title:       req.Title,
+			Tags:        req.Tags,
+			Notes:       req.Notes,
+			Folder:      req.Folder,
+			FallbackURL: req.FallbackURL,
+		}
+		if patch.NotBefore, err = optionalWindowBound("not_before", req.NotBefore); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if patch.NotAfter, err = optionalWindowBound("not_after", req.NotAfter); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		pair, err := h.Service.UpdateURLMetadata(r.Context(), userID, chi.URLParam(r, "id"), patch)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(newUserURLResponse(cfg, pair)); err != nil {
+			logger.Logger.Error("Error writing JSON response for updated user URL", zap.Error(err))
+		}
+	}
+}
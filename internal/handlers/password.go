@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
+	"shorturl/internal/middleware"
+	"shorturl/internal/service"
+	"shorturl/internal/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// unlockCookiePrefix — префикс имени cookie доступа к защищенной ссылке;
+// к нему добавляется короткий ID.
+const unlockCookiePrefix = "unlock_"
+
+// passwordForm — страница ввода пароля защищенной ссылки. Форма отправляется
+// POST-запросом на адрес самой ссылки.
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post" action="/{{.ShortID}}">
+<h1>This link is protected</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>
+{{end}}<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Open link</button>
+</form>
+</body>
+</html>
+`))
+
+// HandleUnlock принимает пароль защищенной ссылки из формы, которую отдает
+// HandleGet. При верном пароле ставит подписанную cookie, по которой ссылка
+// открывается без пароля cfg.UnlockTTL, и перенаправляет на адрес назначения.
+//
+// Попытки считаются только для существующих защищенных ссылок: с одного адреса
+// разрешено cfg.PasswordAttempts попыток на ссылку за cfg.PasswordAttemptWindow,
+// а от всех адресов вместе — cfg.PasswordLinkAttempts, дальше — 429 с
+// Retry-After. Общий лимит нужен потому, что за прокси адрес клиента берется из
+// заголовков X-Forwarded-For и X-Real-IP (см. middleware RealIP) и подменяется.
+func (h *Handlers) HandleUnlock(cfg *config.Config) http.HandlerFunc {
+	now := func() time.Time { return h.Service.Now() }
+	clientLimiter := newAttemptLimiter(cfg.PasswordAttempts, cfg.PasswordAttemptWindow, now)
+	linkLimiter := newAttemptLimiter(cfg.PasswordLinkAttempts, cfg.PasswordAttemptWindow, now)
+	return func(w http.ResponseWriter, r *http.Request) {
+		shortID, ok := shortIDParam(w, r)
+		if !ok {
+			return
+		}
+		redirect, err := h.Service.ResolveURL(r.Context(), shortID)
+		if err != nil {
+			writeRedirectError(w, r, cfg, err)
+			return
+		}
+		if redirect.Protected() {
+			allowed, retryAfter := clientLimiter.allow(clientIP(r) + "|" + shortID)
+			if allowed {
+				allowed, retryAfter = linkLimiter.allow(shortID)
+			}
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second).Seconds())))
+				renderPasswordForm(w, http.StatusTooManyRequests, shortID, "Too many attempts. Try again later.")
+				return
+			}
+		}
+		if err := r.ParseForm(); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if !errors.As(err, &maxBytesErr) {
+				err = apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "Failed to read request body")
+			}
+			writeError(w, r, err)
+			return
+		}
+
+		redirect, err = h.Service.UnlockURL(r.Context(), shortID, r.PostForm.Get("password"))
+		if errors.Is(err, service.ErrWrongPassword) {
+			metrics.Redirects.Inc("wrong_password")
+			renderPasswordForm(w, http.StatusUnauthorized, shortID, "Wrong password.")
+			return
+		}
+		if err != nil {
+			writeRedirectError(w, r, cfg, err)
+			return
+		}
+		if !h.consumeClick(w, r, shortID, redirect) {
+			return
+		}
+		if redirect.Protected() {
+			setUnlockCookie(w, r, cfg, shortID, redirect, h.Service.Now().Add(cfg.UnlockTTL))
+		}
+		metrics.Redirects.Inc("redirected")
+		http.Redirect(w, r, redirect.URL, http.StatusSeeOther)
+	}
+}
+
+// renderPasswordForm отдает форму ввода пароля ссылки shortID со статусом
+// status и сообщением об ошибке message.
+func renderPasswordForm(w http.ResponseWriter, status int, shortID, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := passwordForm.Execute(w, struct{ ShortID, Error string }{shortID, message})
+	if err != nil {
+		logger.Logger.Error("Error writing password form", zap.Error(err))
+	}
+}
+
+// unlockSignedData — данные, которые подписывает cookie доступа. Хэш пароля
+// входит в подпись, чтобы cookie переставала действовать при смене пароля.
+func unlockSignedData(shortID string, redirect storage.Redirect, expires int64) string {
+	return fmt.Sprintf("unlock|%s|%d|%s", shortID, expires, redirect.PasswordHash)
+}
+
+// setUnlockCookie ставит cookie доступа к ссылке shortID до момента expires.
+// Значение — время истечения и его подпись middleware.Sign. Если запрос пришел
+// по TLS или сервис опубликован по https, cookie отправляется только по HTTPS.
+func setUnlockCookie(w http.ResponseWriter, r *http.Request, cfg *config.Config, shortID string, redirect storage.Redirect, expires time.Time) {
+	exp := expires.Unix()
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookiePrefix + shortID,
+		Value:    strconv.FormatInt(exp, 10) + "|" + middleware.Sign(unlockSignedData(shortID, redirect, exp)),
+		Path:     "/" + shortID,
+		Expires:  expires,
+		Secure:   r.TLS != nil || strings.HasPrefix(strings.ToLower(cfg.BaseURL), "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// unlocked сообщает, что запрос несет действующую на момент now cookie
+// доступа к ссылке shortID.
+func unlocked(r *http.Request, shortID string, redirect storage.Redirect, now time.Time) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + shortID)
+	if err != nil {
+		return false
+	}
+	expStr, signature, ok := strings.Cut(cookie.Value, "|")
+	if !ok {
+		return false
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return false
+	}
+	return middleware.ValidSignature(unlockSignedData(shortID, redirect, exp), signature)
+}
+
+// clientIP возвращает адрес клиента без порта. Заголовки прокси уже учтены
+// middleware RealIP.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// attemptLimiter ограничивает число попыток по ключу: не больше max за окно
+// длиной window, отсчитываемое от первой попытки. max <= 0 отключает ограничение.
+type attemptLimiter struct {
+	mu      sync.Mutex
+	max     int
+	window  time.Duration
+	now     func() time.Time
+	windows map[string]attemptWindow
+	// order — ключи в порядке открытия окон, от старых к новым. Запись, окно
+	// которой с тех пор открылось заново, устарела и пропускается.
+	order []attemptKey
+}
+
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+type attemptKey struct {
+	key   string
+	start time.Time
+}
+
+// maxTrackedKeys — сколько окон хранится одновременно. Действующее окно не
+// вытесняется, иначе поток попыток с новыми ключами обнулял бы счетчики:
+// пока окна не истекут, попытки с новыми ключами отклоняются.
+const maxTrackedKeys = 10000
+
+func newAttemptLimiter(maxAttempts int, window time.Duration, now func() time.Time) *attemptLimiter {
+	return &attemptLimiter{max: maxAttempts, window: window, now: now, windows: make(map[string]attemptWindow)}
+}
+
+// allow учитывает попытку по ключу key. Если попытки исчерпаны или окон
+// слишком много, возвращает false и время до освобождения.
+func (l *attemptLimiter) allow(key string) (bool, time.Duration) {
+	if l.max <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(now)
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		if !ok && len(l.windows) >= maxTrackedKeys {
+			return false, l.order[0].start.Add(l.window).Sub(now)
+		}
+		w = attemptWindow{start: now}
+		l.order = append(l.order, attemptKey{key: key, start: now})
+	}
+	if w.count >= l.max {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	l.windows[key] = w
+	return true, 0
+}
+
+// prune снимает с начала очереди устаревшие записи и истекшие окна.
+// Вызывается под l.mu.
+func (l *attemptLimiter) prune(now time.Time) {
+	for len(l.order) > 0 {
+		head := l.order[0]
+		w, ok := l.windows[head.key]
+		if ok && w.start.Equal(head.start) {
+			if now.Sub(w.start) < l.window {
+				return
+			}
+			delete(l.windows, head.key)
+		}
+		l.order = l.order[1:]
+	}
+}
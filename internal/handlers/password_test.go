@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newAttemptLimiter(2, time.Minute, func() time.Time { return now })
+
+	for i := range 2 {
+		if ok, _ := l.allow("link"); !ok {
+			t.Fatalf("Expected attempt %d to be allowed", i+1)
+		}
+	}
+	if ok, retryAfter := l.allow("link"); ok || retryAfter != time.Minute {
+		t.Errorf("Expected third attempt to be rejected for a minute, got %t %s", ok, retryAfter)
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.allow("link"); !ok {
+		t.Errorf("Expected attempts to be allowed in the next window")
+	}
+}
+
+func TestAttemptLimiterCap(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newAttemptLimiter(1, time.Hour, func() time.Time { return now })
+
+	// Действующие окна не вытесняются: сверх лимита новые ключи отклоняются.
+	for i := range maxTrackedKeys {
+		now = now.Add(time.Millisecond)
+		if ok, _ := l.allow("key-" + strconv.Itoa(i)); !ok {
+			t.Fatalf("Expected first attempt for key %d to be allowed", i)
+		}
+	}
+	if ok, retryAfter := l.allow("extra"); ok || retryAfter <= 0 {
+		t.Errorf("Expected a new key to be rejected at the cap, got %t %s", ok, retryAfter)
+	}
+	if len(l.windows) != maxTrackedKeys {
+		t.Errorf("Expected %d tracked windows, got %d", maxTrackedKeys, len(l.windows))
+	}
+	if ok, _ := l.allow("key-0"); ok {
+		t.Errorf("Expected the oldest window to keep counting")
+	}
+
+	// Истекшие окна снимаются с начала очереди.
+	now = now.Add(time.Hour)
+	l.allow("fresh")
+	if len(l.windows) != 1 || len(l.order) != 1 {
+		t.Errorf("Expected expired windows to be pruned, got %d windows and %d queued", len(l.windows), len(l.order))
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/qrcode"
+	"shorturl/internal/service"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Параметры QR-кода по умолчанию и их допустимые пределы.
+const (
+	qrDefaultSize   = 256
+	qrMinSize       = 64
+	qrMaxSize       = 2048
+	qrDefaultMargin = 4
+	qrMaxMargin     = 16
+)
+
+// qrContentTypes — поддерживаемые форматы QR-кода и их Content-Type.
+var qrContentTypes = map[string]string{
+	"png": "image/png",
+	"svg": "image/svg+xml",
+}
+
+// qrOptions — параметры запроса QR-кода.
+type qrOptions struct {
+	size   int
+	margin int
+	format string
+	level  qrcode.Level
+}
+
+// HandleQRCode отдает QR-код короткой ссылки cfg.BaseURL/{shortID} в формате
+// png или svg. Параметры: size — сторона картинки в пикселях, margin — поле
+// в модулях, level — уровень коррекции ошибок L, M, Q или H. Картинка
+// зависит только от короткой ссылки и параметров, поэтому ответ кэшируется
+// по ETag и повторные запросы с If-None-Match получают 304.
+func (h *Handlers) HandleQRCode(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shortID, ok := shortIDParam(w, r)
+		if !ok {
+			return
+		}
+		opts, err := parseQROptions(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if _, err := h.Service.GetOriginalURL(r.Context(), shortID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		content := fmt.Sprintf("%s/%s", cfg.BaseURL, shortID)
+		etag := qrETag(content, opts)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		code, err := qrcode.Encode([]byte(content), opts.level)
+		if err != nil {
+			writeError(w, r, fmt.Errorf("failed to encode QR code: %w", err))
+			return
+		}
+		var buf bytes.Buffer
+		if opts.format == "svg" {
+			err = code.SVG(&buf, opts.size, opts.margin)
+		} else {
+			err = code.PNG(&buf, opts.size, opts.margin)
+		}
+		if err != nil {
+			writeError(w, r, fmt.Errorf("failed to render QR code: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", qrContentTypes[opts.format])
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			logger.Logger.Error("Error writing QR code response", zap.Error(err))
+		}
+	}
+}
+
+// parseQROptions читает параметры QR-кода из строки запроса, подставляя
+// значения по умолчанию для отсутствующих.
+func parseQROptions(r *http.Request) (qrOptions, error) {
+	query := r.URL.Query()
+	opts := qrOptions{size: qrDefaultSize, margin: qrDefaultMargin, format: "png", level: qrcode.Medium}
+
+	var err error
+	if format := query.Get("format"); format != "" {
+		if _, ok := qrContentTypes[format]; !ok {
+			return opts, &service.ValidationError{Field: "format", Code: apierror.CodeInvalidValue, Reason: "must be one of png, svg"}
+		}
+		opts.format = format
+	}
+	if level := query.Get("level"); level != "" {
+		if opts.level, err = qrcode.ParseLevel(level); err != nil {
+			return opts, &service.ValidationError{Field: "level", Code: apierror.CodeInvalidValue, Reason: "must be one of L, M, Q, H"}
+		}
+	}
+	if opts.size, err = intParam(query.Get("size"), "size", qrDefaultSize, qrMinSize, qrMaxSize); err != nil {
+		return opts, err
+	}
+	if opts.margin, err = intParam(query.Get("margin"), "margin", qrDefaultMargin, 0, qrMaxMargin); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// intParam разбирает целочисленный параметр запроса в пределах [lo, hi].
+func intParam(value, field string, def, lo, hi int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &service.ValidationError{Field: field, Code: apierror.CodeInvalidType, Reason: "must be an integer"}
+	}
+	if n < lo || n > hi {
+		return 0, &service.ValidationError{Field: field, Code: apierror.CodeInvalidValue, Reason: fmt.Sprintf("must be between %d and %d", lo, hi)}
+	}
+	return n, nil
+}
+
+// qrETag возвращает ETag картинки. Он слабый: при сжатии ответа байты
+// меняются, а картинка остается той же.
+func qrETag(content string, opts qrOptions) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("qr1|%s|%s|%d|%d|%s", content, opts.format, opts.size, opts.margin, opts.level)))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches сообщает, совпадает ли etag с одним из значений If-None-Match
+// (слабое сравнение, RFC 9110, раздел 13.1.2).
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
+	"shorturl/internal/service"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// comingSoonPage — страница ссылки, окно действия которой еще не началось
+// (см. config.Config.ComingSoon).
+var comingSoonPage = template.Must(template.New("coming-soon").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Coming soon</title>
+</head>
+<body>
+<h1>This link is not live yet</h1>
+<p>It opens on <time datetime="{{.ISO}}">{{.Human}}</time>.</p>
+</body>
+</html>
+`))
+
+// writeRedirectError отвечает на ошибку перехода по ссылке и учитывает ее в
+// metrics.Redirects. До начала окна действия ссылка неотличима от
+// отсутствующей (400, см. errUnknownRedirect), если не включена страница
+// cfg.ComingSoon; после закрытия окна — 410.
+func writeRedirectError(w http.ResponseWriter, r *http.Request, cfg *config.Config, err error) {
+	var notActiveErr *service.ErrNotYetActive
+	switch {
+	case errors.As(err, &notActiveErr):
+		metrics.Redirects.Inc("not_active")
+		if cfg.ComingSoon {
+			renderComingSoon(w, notActiveErr.ActiveFrom)
+			return
+		}
+		apierror.WriteProblem(w, r, errUnknownRedirect())
+		return
+	case errors.Is(err, service.ErrExpired):
+		metrics.Redirects.Inc("expired")
+	case errors.Is(err, service.ErrNotFound):
+		metrics.Redirects.Inc("not_found")
+		apierror.WriteProblem(w, r, errUnknownRedirect())
+		return
+	}
+	writeError(w, r, err)
+}
+
+// renderComingSoon отдает страницу «скоро откроется» со временем открытия activeFrom.
+func renderComingSoon(w http.ResponseWriter, activeFrom time.Time) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	err := comingSoonPage.Execute(w, struct{ ISO, Human string }{
+		activeFrom.UTC().Format(time.RFC3339), activeFrom.UTC().Format("January 2, 2006 at 15:04 MST"),
+	})
+	if err != nil {
+		logger.Logger.Error("Error writing coming soon page", zap.Error(err))
+	}
+}
+
+// parseWindowBound разбирает границу окна действия ссылки из поля field в
+// формате RFC 3339; пустая строка — нулевое время (без ограничения).
+func parseWindowBound(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, &service.ValidationError{Field: field, Code: apierror.CodeInvalidValue,
+			Reason: "must be an RFC 3339 timestamp or an empty string"}
+	}
+	return t, nil
+}
+
+// optionalWindowBound разбирает границу окна из необязательного поля
+// изменения; nil оставляет границу прежней.
+func optionalWindowBound(field string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := parseWindowBound(field, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/service"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// UserURLSearchResult — найденная ссылка пользователя и ее релевантность.
+type UserURLSearchResult struct {
+	UserURLResponse
+	Score float64 `json:"score"`
+}
+
+// HandleSearchUserURLs ищет по ссылкам пользователя (параметр q) и отдает
+// страницу результатов по убыванию релевантности. Пагинация — как у
+// HandleGetUserURLs: limit, cursor и заголовки Link и X-Next-Cursor.
+func (h *Handlers) HandleSearchUserURLs(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromContext(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		query := r.URL.Query()
+		req := service.SearchRequest{Query: query.Get("q"), Cursor: query.Get("cursor")}
+		if limit := query.Get("limit"); limit != "" {
+			if req.Limit, err = strconv.Atoi(limit); err != nil {
+				writeError(w, r, &service.ValidationError{Field: "limit", Code: apierror.CodeInvalidType, Reason: "must be an integer"})
+				return
+			}
+			if req.Limit < 1 {
+				req.Limit = -1
+			}
+		}
+
+		page, err := h.Service.SearchUserURLs(r.Context(), userID, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		setNextPage(w, r, page.NextCursor)
+
+		response := make([]UserURLSearchResult, len(page.Hits))
+		for i, hit := range page.Hits {
+			response[i] = UserURLSearchResult{UserURLResponse: newUserURLResponse(cfg, hit.URLPair), Score: hit.Score}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Logger.Error("Error writing JSON response for user URL search", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Pinger — зависимость, доступность которой проверяется запросом ping.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type pingCheck struct {
+	name   string
+	pinger Pinger
+}
+
+// PingCheck проверяет доступность зависимости (например, базы данных) через ping.
+func PingCheck(name string, pinger Pinger) Check {
+	return &pingCheck{name: name, pinger: pinger}
+}
+
+func (c *pingCheck) Name() string { return c.name }
+
+func (c *pingCheck) Check(ctx context.Context) Result {
+	if err := c.pinger.PingContext(ctx); err != nil {
+		return Result{Status: StatusDown, Error: err.Error()}
+	}
+	return Result{Status: StatusUp}
+}
+
+type fileCheck struct {
+	path         string
+	minFreeBytes uint64
+}
+
+// FileCheck проверяет, что файл хранилища доступен для записи и на диске
+// осталось не меньше minFreeBytes свободного места.
+func FileCheck(path string, minFreeBytes uint64) Check {
+	return &fileCheck{path: path, minFreeBytes: minFreeBytes}
+}
+
+func (c *fileCheck) Name() string { return "file_storage" }
+
+func (c *fileCheck) Check(context.Context) Result {
+	res := Result{Status: StatusUp, Details: map[string]any{"path": c.path}}
+
+	// Открываем файл на дозапись, ничего не записывая: так проверяются права
+	// и отсутствие read-only монтирования без изменения данных.
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = fmt.Sprintf("file is not writable: %v", err)
+		return res
+	}
+	res.Details["writable"] = true
+	if err := f.Close(); err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+		return res
+	}
+
+	free, err := diskFree(filepath.Dir(c.path))
+	switch {
+	case errors.Is(err, errDiskFreeUnsupported):
+	case err != nil:
+		res.Status = StatusDown
+		res.Error = fmt.Sprintf("failed to get free disk space: %v", err)
+	default:
+		res.Details["free_bytes"] = free
+		if free < c.minFreeBytes {
+			res.Status = StatusDown
+			res.Error = fmt.Sprintf("free disk space %d bytes is below %d bytes", free, c.minFreeBytes)
+		}
+	}
+	return res
+}
+
+type queueCheck struct {
+	name     string
+	length   func() int
+	capacity func() int
+}
+
+// QueueCheck сообщает глубину очереди фонового обработчика. Переполненная
+// очередь означает, что обработчик не успевает, и сервис считается не готовым.
+func QueueCheck(name string, length, capacity func() int) Check {
+	return &queueCheck{name: name, length: length, capacity: capacity}
+}
+
+func (c *queueCheck) Name() string { return c.name }
+
+func (c *queueCheck) Check(context.Context) Result {
+	depth, capacity := c.length(), c.capacity()
+	res := Result{Status: StatusUp, Details: map[string]any{"depth": depth, "capacity": capacity}}
+	if capacity > 0 && depth >= capacity {
+		res.Status = StatusDown
+		res.Error = "queue is full"
+	}
+	return res
+}
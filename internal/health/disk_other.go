@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package health
+
+import "errors"
+
+var errDiskFreeUnsupported = errors.New("free disk space is not supported on this platform")
+
+func diskFree(string) (uint64, error) {
+	return 0, errDiskFreeUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package health
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errDiskFreeUnsupported = errors.New("free disk space is not supported on this platform")
+
+// diskFree возвращает число байт, доступных непривилегированному пользователю в каталоге dir.
+func diskFree(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
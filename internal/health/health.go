@@ -0,0 +1,144 @@
+// Package health реализует эндпоинты проверки живости (/healthz)
+// и готовности (/readyz) с отчетом о состоянии каждой зависимости.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"shorturl/internal/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Status — состояние компонента или сервиса в целом.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// defaultCheckTimeout ограничивает время выполнения всех проверок готовности.
+const defaultCheckTimeout = 2 * time.Second
+
+// Result — результат проверки одного компонента.
+type Result struct {
+	Name      string         `json:"name"`
+	Status    Status         `json:"status"`
+	LatencyMs float64        `json:"latency_ms"`
+	Details   map[string]any `json:"details,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// Check проверяет состояние одной зависимости.
+type Check interface {
+	Name() string
+	Check(ctx context.Context) Result
+}
+
+// Report — тело ответа /readyz и /healthz.
+type Report struct {
+	Status     Status   `json:"status"`
+	Reason     string   `json:"reason,omitempty"`
+	Components []Result `json:"components,omitempty"`
+}
+
+// Checker агрегирует проверки зависимостей и состояние готовности сервиса.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []Check
+	shuttingDown atomic.Bool
+	timeout      time.Duration
+}
+
+// NewChecker создает Checker с заданными проверками.
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: defaultCheckTimeout}
+}
+
+// Register добавляет проверки готовности.
+func (c *Checker) Register(checks ...Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, checks...)
+}
+
+// SetShuttingDown переводит сервис в состояние "не готов" на время остановки,
+// чтобы балансировщик перестал направлять на него новые запросы.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Readiness выполняет все проверки параллельно и возвращает итоговый отчет.
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			start := time.Now()
+			res := check.Check(ctx)
+			res.Name = check.Name()
+			if res.Status == "" {
+				res.Status = StatusUp
+			}
+			res.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+			results[i] = res
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: results}
+	for _, res := range results {
+		if res.Status != StatusUp {
+			report.Status = StatusDown
+			report.Reason = "dependency check failed"
+		}
+	}
+	if c.shuttingDown.Load() {
+		report.Status = StatusDown
+		report.Reason = "shutting down"
+	}
+	return report
+}
+
+// LivenessHandler отвечает 200, пока процесс способен обрабатывать запросы.
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusUp})
+	}
+}
+
+// ReadinessHandler отвечает 200, если все зависимости доступны, и 503 в противном
+// случае, а также во время плавной остановки сервиса.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Readiness(r.Context())
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Logger.Error("Error writing health report", zap.Error(err))
+	}
+}
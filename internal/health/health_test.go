@@ -0,0 +1,103 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"shorturl/internal/config"
+	"shorturl/internal/health"
+	"shorturl/internal/logger"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitializeLogger(&config.Config{LogLevel: "info", LogFormat: "text"})
+	os.Exit(m.Run())
+}
+
+type stubPinger struct{ err error }
+
+func (p stubPinger) PingContext(context.Context) error { return p.err }
+
+func TestReadiness(t *testing.T) {
+	dir := t.TempDir()
+	storagePath := filepath.Join(dir, "storage.json")
+
+	tests := []struct {
+		name         string
+		checks       []health.Check
+		shuttingDown bool
+		expectedCode int
+		reason       string
+	}{
+		{
+			name: "All components up",
+			checks: []health.Check{
+				health.PingCheck("database", stubPinger{}),
+				health.FileCheck(storagePath, 0),
+				health.QueueCheck("queue", func() int { return 1 }, func() int { return 10 }),
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Database down",
+			checks:       []health.Check{health.PingCheck("database", stubPinger{err: errors.New("connection refused")})},
+			expectedCode: http.StatusServiceUnavailable,
+			reason:       "dependency check failed",
+		},
+		{
+			name:         "File not writable",
+			checks:       []health.Check{health.FileCheck(filepath.Join(dir, "missing", "storage.json"), 0)},
+			expectedCode: http.StatusServiceUnavailable,
+			reason:       "dependency check failed",
+		},
+		{
+			name:         "Queue full",
+			checks:       []health.Check{health.QueueCheck("queue", func() int { return 10 }, func() int { return 10 })},
+			expectedCode: http.StatusServiceUnavailable,
+			reason:       "dependency check failed",
+		},
+		{
+			name:         "Shutting down",
+			shuttingDown: true,
+			expectedCode: http.StatusServiceUnavailable,
+			reason:       "shutting down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(tt.checks...)
+			if tt.shuttingDown {
+				checker.SetShuttingDown()
+			}
+
+			rr := httptest.NewRecorder()
+			checker.ReadinessHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d (%s)", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			var report health.Report
+			if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Failed to decode report: %v", err)
+			}
+			if report.Reason != tt.reason {
+				t.Errorf("Expected reason %q, got %q", tt.reason, report.Reason)
+			}
+			if len(report.Components) != len(tt.checks) {
+				t.Errorf("Expected %d components, got %d", len(tt.checks), len(report.Components))
+			}
+
+			live := httptest.NewRecorder()
+			checker.LivenessHandler().ServeHTTP(live, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if live.Code != http.StatusOK {
+				t.Errorf("Liveness must stay OK, got %d", live.Code)
+			}
+		})
+	}
+}
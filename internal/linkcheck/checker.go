@@ -0,0 +1,476 @@
+// Package linkcheck периодически проверяет доступность адресов назначения
+// ссылок и сохраняет результат в storage.LinkHealth.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
+	"shorturl/internal/netguard"
+	"shorturl/internal/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Параметры проверки по умолчанию.
+const (
+	DefaultInterval  = 24 * time.Hour
+	DefaultWorkers   = 4
+	DefaultTimeout   = 10 * time.Second
+	DefaultHostDelay = time.Second
+	DefaultDeadAfter = 3
+	DefaultUserAgent = "shorturl-linkcheck/1.0"
+
+	minBackoff     = time.Minute
+	maxBackoff     = 6 * time.Hour
+	maxRedirects   = 5
+	maxSweepPeriod = time.Hour
+	queueSize      = 64
+)
+
+// Store — хранилище, в которое проверка записывает результаты.
+type Store interface {
+	SetURLHealth(ctx context.Context, shortID, originalURL string, health storage.LinkHealth) error
+}
+
+// hostState — расписание запросов к одному хосту.
+type hostState struct {
+	next    time.Time     // раньше этого времени хост не запрашивается
+	backoff time.Duration // пауза после отказа хоста; 0 — хост отвечает нормально
+}
+
+// Checker обходит все ссылки хранилища и проверяет те, что не проверялись
+// дольше интервала. Запрос HEAD (GET, если сервер не поддерживает HEAD)
+// считается успешным при ответе 2xx или 3xx. К одному хосту одновременно идет
+// не больше одного запроса и не чаще раза в hostDelay; на 429, 503 и сетевые
+// ошибки хост получает экспоненциально растущую паузу, а Retry-After
+// соблюдается. После deadAfter неудачных проверок подряд адрес считается
+// недоступным. Как и preview.Fetcher, проверка не ходит во внутреннюю сеть.
+type Checker struct {
+	source    storage.Exporter
+	store     Store
+	client    *http.Client
+	interval  time.Duration
+	workers   int
+	timeout   time.Duration
+	hostDelay time.Duration
+	deadAfter int
+	userAgent string
+	now       func() time.Time
+	// allowPrivate отключает проверку адресов; используется в тестах,
+	// где адреса назначения обслуживает httptest-сервер на 127.0.0.1.
+	allowPrivate bool
+
+	sweepMu sync.Mutex
+	mu      sync.Mutex
+	hosts   map[string]*hostState
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// Option настраивает Checker.
+type Option func(*Checker)
+
+// WithInterval задает, как часто проверяется каждая ссылка.
+func WithInterval(d time.Duration) Option {
+	return func(c *Checker) { c.interval = d }
+}
+
+// WithWorkers задает число параллельных проверок разных хостов.
+func WithWorkers(n int) Option {
+	return func(c *Checker) { c.workers = n }
+}
+
+// WithTimeout ограничивает время одной проверки.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Checker) { c.timeout = d }
+}
+
+// WithHostDelay задает минимальную паузу между запросами к одному хосту.
+func WithHostDelay(d time.Duration) Option {
+	return func(c *Checker) { c.hostDelay = d }
+}
+
+// WithDeadAfter задает число неудачных проверок подряд, после которого
+// адрес считается недоступным.
+func WithDeadAfter(n int) Option {
+	return func(c *Checker) { c.deadAfter = n }
+}
+
+// WithUserAgent задает User-Agent запросов.
+func WithUserAgent(ua string) Option {
+	return func(c *Checker) { c.userAgent = ua }
+}
+
+// NewChecker создает проверку, читающую ссылки из source и сохраняющую
+// результаты в store. Периодический обход запускает Start.
+func NewChecker(source storage.Exporter, store Store, opts ...Option) *Checker {
+	c := &Checker{
+		source:    source,
+		store:     store,
+		interval:  DefaultInterval,
+		workers:   DefaultWorkers,
+		timeout:   DefaultTimeout,
+		hostDelay: DefaultHostDelay,
+		deadAfter: DefaultDeadAfter,
+		userAgent: DefaultUserAgent,
+		now:       time.Now,
+		hosts:     make(map[string]*hostState),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.workers = max(c.workers, 1)
+	c.deadAfter = max(c.deadAfter, 1)
+
+	dialer := &net.Dialer{Timeout: c.timeout, Control: c.checkAddress}
+	c.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   c.timeout,
+			ResponseHeaderTimeout: c.timeout,
+			MaxIdleConnsPerHost:   1,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: checkRedirect,
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c
+}
+
+// Start запускает обход ссылок сразу и далее не реже раза в час, но не чаще
+// интервала проверки. Вызывается один раз.
+func (c *Checker) Start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Close прерывает текущий обход и ждет его завершения.
+func (c *Checker) Close() error {
+	c.once.Do(func() {
+		c.cancel()
+		c.wg.Wait()
+		c.client.CloseIdleConnections()
+	})
+	return nil
+}
+
+func (c *Checker) run() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(min(c.interval, maxSweepPeriod))
+	defer ticker.Stop()
+	for {
+		if err := c.Check(c.ctx); err != nil && c.ctx.Err() == nil {
+			logger.Logger.Warn("Link health check failed", zap.Error(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Check один раз обходит ссылки и проверяет те, что пора проверить. Ссылки
+// распределяются по воркерам по хосту, поэтому запросы к одному хосту идут
+// последовательно. Ссылки хоста, взявшего паузу, пропускаются до следующего
+// обхода.
+func (c *Checker) Check(ctx context.Context) error {
+	c.sweepMu.Lock()
+	defer c.sweepMu.Unlock()
+
+	now := c.now()
+	c.pruneHosts(now)
+
+	queues := make([]chan storage.URLPair, c.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan storage.URLPair, queueSize)
+		wg.Add(1)
+		go func(queue <-chan storage.URLPair) {
+			defer wg.Done()
+			for pair := range queue {
+				c.checkLink(ctx, pair)
+			}
+		}(queues[i])
+	}
+
+	err := c.source.ForEachURL(ctx, "", func(pair storage.URLPair) error {
+		if !pair.Health.CheckedAt.IsZero() && now.Sub(pair.Health.CheckedAt) < c.interval {
+			return nil
+		}
+		select {
+		case queues[shard(hostOf(pair.OriginalURL), len(queues))] <- pair:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to list urls: %w", err)
+	}
+	return nil
+}
+
+// checkLink проверяет адрес ссылки и сохраняет результат. Как и у загрузки
+// страниц, устаревший результат для ссылки со смененным адресом отбрасывается.
+func (c *Checker) checkLink(ctx context.Context, pair storage.URLPair) {
+	host := hostOf(pair.OriginalURL)
+	if !c.wait(ctx, host) {
+		return
+	}
+	res := c.probe(ctx, pair.OriginalURL)
+	if ctx.Err() != nil {
+		// Проверка прервана остановкой, а не недоступностью адреса.
+		return
+	}
+	c.schedule(host, res)
+	metrics.LinkChecks.Inc(res.label())
+	if res.status == http.StatusTooManyRequests {
+		// Ограничение частоты ничего не говорит о доступности адреса.
+		return
+	}
+
+	health := c.record(pair.Health, res)
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := c.store.SetURLHealth(saveCtx, pair.ShortURL, pair.OriginalURL, health)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logger.Logger.Warn("Failed to save link health", zap.String("short_url", pair.ShortURL), zap.Error(err))
+	}
+}
+
+// wait дожидается, когда к хосту можно обратиться. Возвращает false, если
+// хост взял паузу или ctx отменен.
+func (c *Checker) wait(ctx context.Context, host string) bool {
+	c.mu.Lock()
+	h, ok := c.hosts[host]
+	if !ok {
+		h = &hostState{}
+		c.hosts[host] = h
+	}
+	delay, backoff := h.next.Sub(c.now()), h.backoff
+	c.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	if backoff > 0 {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// schedule назначает время следующего запроса к хосту по результату проверки.
+func (c *Checker) schedule(host string, res result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.hosts[host]
+	now := c.now()
+	if !res.overloaded() {
+		h.backoff = 0
+		h.next = now.Add(c.hostDelay)
+		return
+	}
+	h.backoff = min(max(2*h.backoff, minBackoff), maxBackoff)
+	pause := h.backoff
+	if res.retryAfter > 0 {
+		pause = min(res.retryAfter, maxBackoff)
+	}
+	h.next = now.Add(max(pause, c.hostDelay))
+}
+
+// pruneHosts удаляет хосты, расписание которых больше не ограничивает запросы.
+func (c *Checker) pruneHosts(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for host, h := range c.hosts {
+		if now.After(h.next) && (h.backoff == 0 || now.Sub(h.next) > maxBackoff) {
+			delete(c.hosts, host)
+		}
+	}
+}
+
+// record вычисляет новое состояние ссылки по предыдущему и результату проверки.
+func (c *Checker) record(prev storage.LinkHealth, res result) storage.LinkHealth {
+	now := c.now().UTC().Truncate(time.Microsecond)
+	h := storage.LinkHealth{
+		Status:    res.status,
+		LatencyMS: res.latency.Milliseconds(),
+		CheckedAt: now,
+		Failures:  prev.Failures,
+		DeadSince: prev.DeadSince,
+	}
+	if res.err != nil {
+		h.Error = res.err.Error()
+	}
+	if res.ok() {
+		h.Failures, h.DeadSince = 0, time.Time{}
+		return h
+	}
+	h.Failures++
+	if h.Failures >= c.deadAfter && h.DeadSince.IsZero() {
+		h.DeadSince = now
+	}
+	return h
+}
+
+// result — исход одной проверки адреса.
+type result struct {
+	status     int
+	err        error
+	latency    time.Duration
+	retryAfter time.Duration
+}
+
+func (r result) ok() bool {
+	return r.err == nil && r.status >= 200 && r.status <= 399
+}
+
+// overloaded сообщает, что хосту нужна пауза: он просит снизить частоту
+// запросов, временно недоступен или не отвечает.
+func (r result) overloaded() bool {
+	if r.err != nil {
+		return !errors.Is(r.err, netguard.ErrBlockedAddress)
+	}
+	return r.status == http.StatusTooManyRequests || r.status == http.StatusServiceUnavailable
+}
+
+// label возвращает метку метрики LinkChecks.
+func (r result) label() string {
+	switch {
+	case r.ok():
+		return "ok"
+	case r.status == http.StatusTooManyRequests:
+		return "throttled"
+	case r.err == nil:
+		return "failed"
+	case errors.Is(r.err, netguard.ErrBlockedAddress):
+		return "blocked"
+	case errors.Is(r.err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// probe запрашивает rawURL методом HEAD, а если сервер его не поддерживает — GET.
+func (c *Checker) probe(ctx context.Context, rawURL string) result {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return result{err: err}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return result{err: fmt.Errorf("unsupported scheme %q", u.Scheme)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := c.do(ctx, http.MethodHead, u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = c.do(ctx, http.MethodGet, u)
+	}
+	res := result{err: err, latency: time.Since(start)}
+	if err != nil {
+		return res
+	}
+	res.status = resp.StatusCode
+	if res.overloaded() {
+		res.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), c.now())
+	}
+	return res
+}
+
+// do выполняет запрос и закрывает тело ответа: нужен только статус.
+func (c *Checker) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Небольшое тело дочитывается, чтобы соединение можно было переиспользовать.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// checkRedirect ограничивает число перенаправлений и разрешает только http(s).
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+	}
+	return nil
+}
+
+// checkAddress отклоняет соединения с внутренней сетью (см. netguard.Control).
+func (c *Checker) checkAddress(network, address string, conn syscall.RawConn) error {
+	if c.allowPrivate {
+		return nil
+	}
+	return netguard.Control(network, address, conn)
+}
+
+// parseRetryAfter разбирает Retry-After в секундах или в виде HTTP-даты.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// hostOf возвращает хост адреса в нижнем регистре; по нему соблюдается вежливость.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func shard(host string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,246 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shorturl/internal/storage"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestChecker создает проверку, которой разрешено ходить на httptest-серверы.
+func newTestChecker(t *testing.T, store *storage.InMemoryStorage, opts ...Option) *Checker {
+	t.Helper()
+	c := NewChecker(store, store, append([]Option{WithHostDelay(0)}, opts...)...)
+	c.allowPrivate = true
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func createLink(t *testing.T, store *storage.InMemoryStorage, originalURL, fallbackURL string) string {
+	t.Helper()
+	shortID, err := store.CreateURL(context.Background(), storage.URLPair{
+		UserID: "user", OriginalURL: originalURL, Metadata: storage.Metadata{FallbackURL: fallbackURL}})
+	if err != nil {
+		t.Fatalf("CreateURL failed: %v", err)
+	}
+	return shortID
+}
+
+func healthOf(t *testing.T, store *storage.InMemoryStorage, shortID string) storage.LinkHealth {
+	t.Helper()
+	var health storage.LinkHealth
+	_ = store.ForEachURL(context.Background(), "", func(pair storage.URLPair) error {
+		if pair.ShortURL == shortID {
+			health = pair.Health
+		}
+		return nil
+	})
+	return health
+}
+
+func TestCheck(t *testing.T) {
+	var mu sync.Mutex
+	methods := make(map[string][]string)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.URL.Path] = append(methods[r.URL.Path], r.Method)
+		mu.Unlock()
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	store := storage.NewInMemoryStorage()
+	ok := createLink(t, store, srv.URL+"/ok", "")
+	moved := createLink(t, store, srv.URL+"/moved", "")
+	noHead := createLink(t, store, srv.URL+"/no-head", "")
+	gone := createLink(t, store, srv.URL+"/gone", "https://example.com/archive")
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestChecker(t, store, WithInterval(time.Hour), WithDeadAfter(2))
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	if err := c.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	for _, shortID := range []string{ok, moved, noHead} {
+		if h := healthOf(t, store, shortID); h.Status != http.StatusOK || h.Failures != 0 || !h.CheckedAt.Equal(now) {
+			t.Errorf("Expected %s to be healthy, got %+v", shortID, h)
+		}
+	}
+	if got := methods["/no-head"]; len(got) != 2 || got[0] != http.MethodHead || got[1] != http.MethodGet {
+		t.Errorf("Expected HEAD then GET, got %v", got)
+	}
+	h := healthOf(t, store, gone)
+	if h.Status != http.StatusNotFound || h.Failures != 1 || h.Dead() {
+		t.Errorf("Expected one failure without dead mark, got %+v", h)
+	}
+
+	// Пока интервал не прошел, ссылки не проверяются повторно.
+	now = now.Add(30 * time.Minute)
+	if err := c.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if h := healthOf(t, store, gone); h.Failures != 1 {
+		t.Errorf("Expected link not to be rechecked before the interval, got %+v", h)
+	}
+
+	now = now.Add(time.Hour)
+	if err := c.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	h = healthOf(t, store, gone)
+	if h.Failures != 2 || !h.DeadSince.Equal(now) {
+		t.Errorf("Expected link to be dead after 2 failures, got %+v", h)
+	}
+	if got, _ := store.GetOriginalURL(ctx, gone); got != "https://example.com/archive" {
+		t.Errorf("Expected dead link to redirect to fallback, got %q", got)
+	}
+
+	// Ожившая ссылка снова ведет на свой адрес.
+	mux.HandleFunc("/gone", func(http.ResponseWriter, *http.Request) {})
+	now = now.Add(time.Hour)
+	if err := c.Check(ctx); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if h := healthOf(t, store, gone); h.Dead() || h.Failures != 0 {
+		t.Errorf("Expected recovered link to be healthy, got %+v", h)
+	}
+	if got, _ := store.GetOriginalURL(ctx, gone); got != srv.URL+"/gone" {
+		t.Errorf("Expected recovered link to redirect to its destination, got %q", got)
+	}
+}
+
+func TestCheckIsPolitePerHost(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	store := storage.NewInMemoryStorage()
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		createLink(t, store, srv.URL+path, "")
+	}
+	const delay = 30 * time.Millisecond
+	c := newTestChecker(t, store, WithWorkers(4), WithHostDelay(delay))
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if p := peak.Load(); p != 1 {
+		t.Errorf("Expected one request at a time per host, got %d", p)
+	}
+	if len(times) != 4 {
+		t.Fatalf("Expected 4 requests, got %d", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < delay {
+			t.Errorf("Expected at least %s between requests, got %s", delay, gap)
+		}
+	}
+}
+
+func TestCheckBacksOff(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	store := storage.NewInMemoryStorage()
+	first := createLink(t, store, srv.URL+"/a", "")
+	createLink(t, store, srv.URL+"/b", "")
+	createLink(t, store, srv.URL+"/c", "")
+
+	now := time.Now()
+	c := newTestChecker(t, store)
+	c.now = func() time.Time { return now }
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("Expected the host to be skipped after 429, got %d requests", n)
+	}
+	if h := healthOf(t, store, first); !h.IsZero() {
+		t.Errorf("Expected 429 not to be recorded as a failure, got %+v", h)
+	}
+
+	// Retry-After соблюдается и в следующем обходе.
+	now = now.Add(time.Minute)
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("Expected Retry-After to be honoured, got %d requests", n)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("Expected the host to be retried after Retry-After, got %d requests", n)
+	}
+}
+
+func TestCheckBlocksPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+
+	store := storage.NewInMemoryStorage()
+	shortID := createLink(t, store, srv.URL, "")
+	c := NewChecker(store, store, WithHostDelay(0))
+	defer func() { _ = c.Close() }()
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Expected no request to reach the server")
+	}
+	if h := healthOf(t, store, shortID); h.Failures != 1 || h.Error == "" {
+		t.Errorf("Expected blocked address to be recorded as a failure, got %+v", h)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-5":                            0,
+		"Thu, 01 Oct 2026 12:02:00 GMT": 2 * time.Minute,
+		"soon":                          0,
+	} {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("Expected parseRetryAfter(%q) = %s, got %s", value, want, got)
+		}
+	}
+}
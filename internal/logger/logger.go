@@ -5,11 +5,13 @@ import (
 	"net/http"
 	"os"
 	"shorturl/internal/config"
+	"shorturl/internal/tracing"
 	"strings"
 	"time"
 )
 
-var Logger *zap.Logger
+// Logger — логгер приложения. До InitializeLogger ничего не пишет.
+var Logger = zap.NewNop()
 
 func InitializeLogger(cfg *config.Config) {
 	zapConfig := zap.NewProductionConfig()
@@ -67,13 +69,19 @@ func Middleware(logger *zap.Logger) func(http.Handler) http.Handler {
 			statusCode := ww.statusCode
 			responseSize := ww.written
 
-			logger.Info("Request processed",
+			fields := []zap.Field{
 				zap.String("uri", uri),
 				zap.String("method", method),
 				zap.Duration("duration", duration),
 				zap.Int("status_code", statusCode),
 				zap.Int("response_size", responseSize),
-			)
+			}
+			if span := tracing.SpanFromContext(r.Context()); span != nil {
+				sc := span.SpanContext()
+				fields = append(fields, zap.String("trace_id", sc.TraceID.String()), zap.String("span_id", sc.SpanID.String()))
+			}
+
+			logger.Info("Request processed", fields...)
 		})
 	}
 }
@@ -0,0 +1,12 @@
+package metrics
+
+var (
+	// CacheRequests считает обращения к кэшу по результату (hit, negative_hit, miss, error).
+	CacheRequests = NewCounterVec("cache_requests_total",
+		"Total number of cache lookups by backend and result.",
+		"backend", "result")
+	// CacheInvalidations считает удаления ключей из кэша при изменении данных.
+	CacheInvalidations = NewCounterVec("cache_invalidations_total",
+		"Total number of cache keys invalidated by backend.",
+		"backend")
+)
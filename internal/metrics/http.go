@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute — значение метки route для запросов, не сопоставленных ни с одним маршрутом.
+// Сырые URI в метки не попадают, чтобы не раздувать число серий.
+const unmatchedRoute = "unmatched"
+
+var (
+	// HTTPRequests считает обработанные HTTP-запросы.
+	HTTPRequests = NewCounterVec("http_requests_total",
+		"Total number of HTTP requests by method, route pattern and status code.",
+		"method", "route", "status")
+	// HTTPRequestDuration измеряет длительность обработки HTTP-запросов.
+	HTTPRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency in seconds by method and route pattern.",
+		nil, "method", "route")
+	// LinksShortened считает запросы на сокращение ссылок по результату (created, conflict).
+	LinksShortened = NewCounterVec("shortener_links_shortened_total",
+		"Total number of shorten operations by result.",
+		"result")
+	// Redirects считает переходы по коротким ссылкам по результату (redirected,
+	// not_found, password_required, wrong_password, exhausted, not_active, expired).
+	Redirects = NewCounterVec("shortener_redirects_total",
+		"Total number of short link redirects by result.",
+		"result")
+)
+
+// Middleware собирает метрики HTTP-запросов. Метка route берется из шаблона
+// маршрута chi (например, /{shortID}), а не из фактического URI.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		HTTPRequests.Inc(r.Method, route, strconv.Itoa(status))
+		HTTPRequestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,7 @@
+package metrics
+
+// LinkChecks считает проверки адресов назначения по результату
+// (ok, failed, throttled, blocked, timeout, error).
+var LinkChecks = NewCounterVec("link_checks_total",
+	"Total number of link destination health checks by result.",
+	"result")
@@ -0,0 +1,349 @@
+// Package metrics реализует минимальный набор метрик (счетчики, гистограммы,
+// вычисляемые значения) и их выдачу в текстовом формате Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"shorturl/internal/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DefBuckets — границы гистограмм по умолчанию (в секундах).
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector — метрика, которая умеет записывать себя в формате Prometheus.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry хранит зарегистрированные метрики.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry создает пустой реестр метрик.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default — реестр, в котором регистрируются метрики приложения.
+var Default = NewRegistry()
+
+// register добавляет метрику в реестр, заменяя метрику с тем же именем.
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[c.name()] = c
+}
+
+// Write записывает все метрики реестра в текстовом формате Prometheus.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler отдает метрики реестра Default.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		bw := bufio.NewWriter(w)
+		Default.Write(bw)
+		if err := bw.Flush(); err != nil {
+			logger.Logger.Error("Error writing metrics", zap.Error(err))
+		}
+	}
+}
+
+// vec хранит серии метрики, различающиеся значениями меток.
+type vec[T any] struct {
+	mu     sync.Mutex
+	labels []string
+	series map[string]*T
+	values map[string][]string
+	create func() *T
+}
+
+func newVec[T any](labels []string, create func() *T) vec[T] {
+	return vec[T]{
+		labels: labels,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+		create: create,
+	}
+}
+
+func (v *vec[T]) get(labelValues []string) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.create()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+// each вызывает fn для каждой серии в детерминированном порядке.
+func (v *vec[T]) each(fn func(labels string, s *T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	series := make([]*T, len(keys))
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		series[i] = v.series[k]
+		labels[i] = formatLabels(v.labels, v.values[k])
+	}
+	v.mu.Unlock()
+
+	for i := range keys {
+		fn(labels[i], series[i])
+	}
+}
+
+// CounterVec — монотонно возрастающий счетчик с метками.
+type CounterVec struct {
+	metricName string
+	help       string
+	vec[counter]
+}
+
+type counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// NewCounterVec создает счетчик и регистрирует его в реестре Default.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		metricName: name,
+		help:       help,
+		vec:        newVec(labels, func() *counter { return &counter{} }),
+	}
+	Default.register(c)
+	return c
+}
+
+// Inc увеличивает счетчик серии с заданными значениями меток на 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add увеличивает счетчик серии на delta. Отрицательные значения игнорируются.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	s := c.get(labelValues)
+	s.mu.Lock()
+	s.value += delta
+	s.mu.Unlock()
+}
+
+// Value возвращает текущее значение серии.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	s := c.get(labelValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) write(w io.Writer) {
+	writeHeader(w, c.metricName, c.help, "counter")
+	c.each(func(labels string, s *counter) {
+		s.mu.Lock()
+		value := s.value
+		s.mu.Unlock()
+		writeSample(w, c.metricName, labels, value)
+	})
+}
+
+// HistogramVec — гистограмма распределения значений с метками.
+type HistogramVec struct {
+	metricName string
+	help       string
+	buckets    []float64
+	vec[histogram]
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec создает гистограмму и регистрирует ее в реестре Default.
+// Если buckets пуст, используются DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		buckets:    buckets,
+	}
+	h.vec = newVec(labels, func() *histogram { return &histogram{counts: make([]uint64, len(buckets))} })
+	Default.register(h)
+	return h
+}
+
+// Observe добавляет значение v в серию с заданными значениями меток.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := h.get(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.mu.Lock()
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+	s.mu.Unlock()
+}
+
+// Count возвращает число наблюдений в серии.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	s := h.get(labelValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+func (h *HistogramVec) name() string { return h.metricName }
+
+func (h *HistogramVec) write(w io.Writer) {
+	writeHeader(w, h.metricName, h.help, "histogram")
+	h.each(func(labels string, s *histogram) {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum, count := s.sum, s.count
+		s.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			writeSample(w, h.metricName+"_bucket", appendLabel(labels, "le", formatFloat(upper)), float64(cumulative))
+		}
+		writeSample(w, h.metricName+"_bucket", appendLabel(labels, "le", "+Inf"), float64(count))
+		writeSample(w, h.metricName+"_sum", labels, sum)
+		writeSample(w, h.metricName+"_count", labels, float64(count))
+	})
+}
+
+// GaugeFunc — метрика, значение которой вычисляется в момент сбора.
+type GaugeFunc struct {
+	metricName string
+	help       string
+	metricType string
+	fn         func() float64
+}
+
+// NewGaugeFunc регистрирует вычисляемую метрику типа gauge.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, metricType: "gauge", fn: fn}
+	Default.register(g)
+	return g
+}
+
+// NewCounterFunc регистрирует вычисляемую метрику типа counter (например, счетчик из sql.DBStats).
+func NewCounterFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, metricType: "counter", fn: fn}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, g.metricType)
+	writeSample(w, g.metricName, "", g.fn())
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	_, _ = fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+func formatLabels(names, values []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func appendLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return pair
+	}
+	return labels + "," + pair
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
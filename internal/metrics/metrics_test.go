@@ -0,0 +1,69 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"shorturl/internal/metrics"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestMiddlewareUsesRoutePattern проверяет, что метки route содержат шаблон маршрута, а не URI.
+func TestMiddlewareUsesRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware)
+	r.Get("/{shortID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Get("/metrics", metrics.Handler())
+
+	before := metrics.HTTPRequests.Value(http.MethodGet, "/{shortID}", "307")
+	for _, id := range []string{"/aaaaaaaa", "/bbbbbbbb"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, id, nil))
+	}
+	if got := metrics.HTTPRequests.Value(http.MethodGet, "/{shortID}", "307") - before; got != 2 {
+		t.Errorf("Expected 2 requests for route pattern, got %v", got)
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	for _, want := range []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{method="GET",route="/{shortID}",status="307"}`,
+		`http_request_duration_seconds_bucket{method="GET",route="/{shortID}",le="+Inf"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics output to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "aaaaaaaa") {
+		t.Errorf("Raw URI leaked into metric labels")
+	}
+}
+
+// TestHistogramExposition проверяет накопительные значения бакетов гистограммы.
+func TestHistogramExposition(t *testing.T) {
+	h := metrics.NewHistogramVec("test_histogram_seconds", "Test histogram.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "x")
+	h.Observe(0.5, "x")
+	h.Observe(5, "x")
+
+	var buf bytes.Buffer
+	metrics.Default.Write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`test_histogram_seconds_bucket{op="x",le="0.1"} 1`,
+		`test_histogram_seconds_bucket{op="x",le="1"} 2`,
+		`test_histogram_seconds_bucket{op="x",le="+Inf"} 3`,
+		`test_histogram_seconds_sum{op="x"} 5.55`,
+		`test_histogram_seconds_count{op="x"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package metrics
+
+// PreviewFetches считает фоновые загрузки страниц ссылок по результату
+// (ok, disallowed, blocked, timeout, error, dropped).
+var PreviewFetches = NewCounterVec("preview_fetches_total",
+	"Total number of link preview fetches by result.",
+	"result")
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shorturl/internal/service"
+	"shorturl/internal/storage"
+	"time"
+)
+
+// StorageOperationDuration измеряет длительность операций хранилища по бэкендам.
+var StorageOperationDuration = NewHistogramVec("storage_operation_duration_seconds",
+	"Storage operation latency in seconds by backend, operation and outcome.",
+	nil, "backend", "operation", "outcome")
+
+// instrumentedStorage — декоратор хранилища, измеряющий длительность операций.
+type instrumentedStorage struct {
+	service.ShortURLCreatorGetter
+	backend string
+}
+
+// InstrumentStorage оборачивает хранилище, записывая длительность операций
+// в StorageOperationDuration с меткой backend.
+func InstrumentStorage(store service.ShortURLCreatorGetter, backend string) service.ShortURLCreatorGetter {
+	return &instrumentedStorage{ShortURLCreatorGetter: store, backend: backend}
+}
+
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	outcome := "ok"
+	var conflictErr *storage.ErrConflict
+	switch {
+	case errors.As(err, &conflictErr):
+		outcome = "conflict"
+	case errors.Is(err, storage.ErrClicksExhausted):
+		outcome = "exhausted"
+	case err != nil:
+		outcome = "error"
+	}
+	StorageOperationDuration.Observe(time.Since(start).Seconds(), s.backend, operation, outcome)
+}
+
+func (s *instrumentedStorage) CreateShortURL(ctx context.Context, userID, originalURL string) (shortID string, err error) {
+	defer func(start time.Time) { s.observe("create_short_url", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.CreateShortURL(ctx, userID, originalURL)
+}
+
+func (s *instrumentedStorage) CreateURL(ctx context.Context, pair storage.URLPair) (shortID string, err error) {
+	defer func(start time.Time) { s.observe("create_url", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.CreateURL(ctx, pair)
+}
+
+func (s *instrumentedStorage) UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (pair storage.URLPair, err error) {
+	defer func(start time.Time) { s.observe("update_url_metadata", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.UpdateURLMetadata(ctx, userID, shortID, patch)
+}
+
+func (s *instrumentedStorage) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (pair storage.URLPair, err error) {
+	defer func(start time.Time) { s.observe("update_original_url", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.UpdateOriginalURL(ctx, userID, shortID, originalURL)
+}
+
+func (s *instrumentedStorage) GetURLHistory(ctx context.Context, userID, shortID string) (entries []storage.HistoryEntry, err error) {
+	defer func(start time.Time) { s.observe("get_url_history", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.GetURLHistory(ctx, userID, shortID)
+}
+
+func (s *instrumentedStorage) SetURLPreview(ctx context.Context, shortID, originalURL string, preview storage.Preview) (err error) {
+	defer func(start time.Time) { s.observe("set_url_preview", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.SetURLPreview(ctx, shortID, originalURL, preview)
+}
+
+func (s *instrumentedStorage) SetURLHealth(ctx context.Context, shortID, originalURL string, health storage.LinkHealth) (err error) {
+	defer func(start time.Time) { s.observe("set_url_health", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.SetURLHealth(ctx, shortID, originalURL, health)
+}
+
+func (s *instrumentedStorage) GetOriginalURL(ctx context.Context, shortID string) (originalURL string, err error) {
+	defer func(start time.Time) { s.observe("get_original_url", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.GetOriginalURL(ctx, shortID)
+}
+
+func (s *instrumentedStorage) GetRedirect(ctx context.Context, shortID string) (redirect storage.Redirect, err error) {
+	defer func(start time.Time) { s.observe("get_redirect", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.GetRedirect(ctx, shortID)
+}
+
+func (s *instrumentedStorage) ConsumeClick(ctx context.Context, shortID string) (err error) {
+	defer func(start time.Time) { s.observe("consume_click", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.ConsumeClick(ctx, shortID)
+}
+
+func (s *instrumentedStorage) GetURLsByUserID(ctx context.Context, userID string) (urls []storage.URLPair, err error) {
+	defer func(start time.Time) { s.observe("get_urls_by_user_id", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.GetURLsByUserID(ctx, userID)
+}
+
+func (s *instrumentedStorage) ForEachUserURL(ctx context.Context, userID string, fn func(storage.URLPair) error) (err error) {
+	defer func(start time.Time) { s.observe("for_each_user_url", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.ForEachUserURL(ctx, userID, fn)
+}
+
+func (s *instrumentedStorage) ListUserURLs(ctx context.Context, userID string, opts storage.ListOptions) (urls []storage.URLPair, err error) {
+	defer func(start time.Time) { s.observe("list_user_urls", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.ListUserURLs(ctx, userID, opts)
+}
+
+func (s *instrumentedStorage) SearchUserURLs(ctx context.Context, userID string, opts storage.SearchOptions) (hits []storage.SearchHit, err error) {
+	defer func(start time.Time) { s.observe("search_user_urls", start, err) }(time.Now())
+	return s.ShortURLCreatorGetter.SearchUserURLs(ctx, userID, opts)
+}
+
+// RegisterDBStats регистрирует метрики пула соединений из sql.DB.Stats().
+func RegisterDBStats(stats func() sql.DBStats) {
+	NewGaugeFunc("db_pool_max_open_connections", "Maximum number of open connections to the database.",
+		func() float64 { return float64(stats().MaxOpenConnections) })
+	NewGaugeFunc("db_pool_open_connections", "The number of established connections both in use and idle.",
+		func() float64 { return float64(stats().OpenConnections) })
+	NewGaugeFunc("db_pool_in_use_connections", "The number of connections currently in use.",
+		func() float64 { return float64(stats().InUse) })
+	NewGaugeFunc("db_pool_idle_connections", "The number of idle connections.",
+		func() float64 { return float64(stats().Idle) })
+	NewCounterFunc("db_pool_wait_count_total", "The total number of connections waited for.",
+		func() float64 { return float64(stats().WaitCount) })
+	NewCounterFunc("db_pool_wait_duration_seconds_total", "The total time blocked waiting for a new connection.",
+		func() float64 { return stats().WaitDuration.Seconds() })
+	NewCounterFunc("db_pool_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.",
+		func() float64 { return float64(stats().MaxIdleClosed) })
+	NewCounterFunc("db_pool_max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime.",
+		func() float64 { return float64(stats().MaxLifetimeClosed) })
+}
@@ -16,12 +16,20 @@ const UserIDKey contextKey = "userID"
 
 var secretKey = []byte("super-secret-key-that-is-not-so-secret")
 
-func sign(data string) string {
+// Sign возвращает HMAC-подпись data ключом сервиса. Ею подписываются cookie
+// пользователя и cookie доступа к защищенным паролем ссылкам.
+func Sign(data string) string {
 	h := hmac.New(sha256.New, secretKey)
 	h.Write([]byte(data))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// ValidSignature сообщает, что signature — подпись data (см. Sign).
+// Сравнение выполняется за постоянное время.
+func ValidSignature(data, signature string) bool {
+	return hmac.Equal([]byte(Sign(data)), []byte(signature))
+}
+
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var userID string
@@ -32,7 +40,7 @@ func Auth(next http.Handler) http.Handler {
 			newCookie = true
 		} else {
 			parts := strings.Split(cookie.Value, "|")
-			if len(parts) == 2 && sign(parts[0]) == parts[1] {
+			if len(parts) == 2 && ValidSignature(parts[0], parts[1]) {
 				userID = parts[0]
 			} else {
 				newCookie = true
@@ -41,7 +49,7 @@ func Auth(next http.Handler) http.Handler {
 
 		if newCookie {
 			userID = uuid.NewString()
-			signedUserID := userID + "|" + sign(userID)
+			signedUserID := userID + "|" + Sign(userID)
 			http.SetCookie(w, &http.Cookie{
 				Name:  "user_id",
 				Value: signedUserID,
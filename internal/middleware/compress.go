@@ -5,6 +5,7 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"shorturl/internal/apierror"
 	"shorturl/internal/logger"
 	"strings"
 )
@@ -54,13 +55,14 @@ func GzipResponse(next http.Handler) http.Handler {
 	})
 }
 
-// GzipRequest middleware распаковывает тело запроса
+// GzipRequest middleware распаковывает тело запроса.
+// Распаковка потоковая; размер результата ограничивается BodyLimit.
 func GzipRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Encoding") == "gzip" {
 			gz, err := gzip.NewReader(r.Body)
 			if err != nil {
-				http.Error(w, "Failed to decompress request body", http.StatusBadRequest)
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Failed to decompress request body")
 				return
 			}
 			r.Body = gz
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"shorturl/internal/apierror"
+	"strings"
+)
+
+// BodyLimit middleware ограничивает размер тела запроса maxBytes байтами.
+// Должен подключаться после GzipRequest, чтобы ограничение применялось
+// к уже распакованным данным и защищало от gzip-бомб.
+func BodyLimit(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("Content-Encoding") == "" && r.ContentLength > maxBytes {
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+					fmt.Sprintf("Request body exceeds %d bytes", maxBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireContentType middleware отклоняет запросы, Content-Type которых
+// не входит в список разрешенных, со статусом 415.
+func RequireContentType(allowed ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err == nil {
+				for _, a := range allowed {
+					if strings.EqualFold(mediaType, a) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			apierror.Write(w, r, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+				fmt.Sprintf("Content-Type must be one of: %s", strings.Join(allowed, ", ")))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Package netguard не дает исходящим запросам сервиса к адресам пользователей
+// попасть во внутреннюю сеть.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+)
+
+// ErrBlockedAddress возвращается при попытке соединиться с адресом
+// внутренней сети (см. Blocked).
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// reservedPrefixes — диапазоны, которые не покрывают методы netip.Addr,
+// но также не должны быть доступны загрузчику.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // «эта» сеть
+	netip.MustParsePrefix("100.64.0.0/10"),   // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // назначения IETF
+	netip.MustParsePrefix("198.18.0.0/15"),   // тестирование производительности
+	netip.MustParsePrefix("240.0.0.0/4"),     // зарезервировано, включая broadcast
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // локальный NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // документация
+	netip.MustParsePrefix("fec0::/10"),       // устаревшие site-local
+	netip.MustParsePrefix("2002::/16"),       // 6to4 может вести в частную IPv4-сеть
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+// Blocked сообщает, что адрес не публичный: loopback, частные сети,
+// link-local (включая метаданные облаков 169.254.169.254), multicast и
+// зарезервированные диапазоны.
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Control — функция net.Dialer.Control, отклоняющая непубличные адреса. Она
+// вызывается перед каждым соединением с уже разрешенным адресом, поэтому
+// DNS-ответ не может подменить проверенный адрес внутренним.
+func Control(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if Blocked(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ap.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package netguard_test
+
+import (
+	"net/netip"
+	"shorturl/internal/netguard"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	for addr, blocked := range map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fd00::1":         true,
+		"fe80::1":         true,
+		"::ffff:10.0.0.1": true,
+		"93.184.216.34":   false,
+		"2606:4700::1111": false,
+	} {
+		if got := netguard.Blocked(netip.MustParseAddr(addr)); got != blocked {
+			t.Errorf("Expected Blocked(%s) = %v, got %v", addr, blocked, got)
+		}
+	}
+}
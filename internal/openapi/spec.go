@@ -0,0 +1,303 @@
+// Package openapi содержит спецификацию HTTP API в формате OpenAPI 3
+// и middleware, проверяющий входящие запросы на соответствие ей.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"shorturl/internal/logger"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// methods перечисляет поддерживаемые методы в порядке ключей объекта Path Item.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// Spec — разобранная спецификация OpenAPI, пригодная для валидации запросов.
+type Spec struct {
+	raw        []byte
+	paths      []*pathItem
+	components components
+}
+
+type components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+}
+
+type pathItem struct {
+	template   string
+	segments   []string
+	params     int
+	operations map[string]*Operation
+}
+
+// Operation описывает операцию (метод + путь) спецификации.
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parameter описывает параметр запроса (path или query).
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody описывает допустимое тело запроса.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// MediaType связывает медиатип тела запроса со схемой.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema — подмножество JSON Schema, используемое в спецификации.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AllOf                []*Schema          `json:"allOf"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Pattern              string             `json:"pattern"`
+	Enum                 []any              `json:"enum"`
+
+	pattern *regexp.Regexp
+}
+
+// Load разбирает встроенную спецификацию.
+func Load() (*Spec, error) {
+	return Parse(document)
+}
+
+// MustLoad разбирает встроенную спецификацию и паникует при ошибке.
+// Спецификация встроена в бинарный файл, поэтому ошибка означает ошибку сборки.
+func MustLoad() *Spec {
+	spec, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// Parse разбирает документ OpenAPI в формате JSON.
+func Parse(data []byte) (*Spec, error) {
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components components                            `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	spec := &Spec{raw: data, components: doc.Components}
+	for template, rawItem := range doc.Paths {
+		item := &pathItem{
+			template:   template,
+			segments:   strings.Split(strings.Trim(template, "/"), "/"),
+			operations: make(map[string]*Operation),
+		}
+		for _, seg := range item.segments {
+			if isParamSegment(seg) {
+				item.params++
+			}
+		}
+		for _, method := range methods {
+			rawOp, ok := rawItem[method]
+			if !ok {
+				continue
+			}
+			var op Operation
+			if err := json.Unmarshal(rawOp, &op); err != nil {
+				return nil, fmt.Errorf("failed to parse operation %s %s: %w", strings.ToUpper(method), template, err)
+			}
+			for i, p := range op.Parameters {
+				resolved, err := spec.resolveParameter(p)
+				if err != nil {
+					return nil, fmt.Errorf("operation %s %s: %w", strings.ToUpper(method), template, err)
+				}
+				op.Parameters[i] = resolved
+			}
+			item.operations[strings.ToUpper(method)] = &op
+		}
+		spec.paths = append(spec.paths, item)
+	}
+
+	// Статические пути проверяются раньше параметризованных: /ping раньше /{shortID}.
+	sort.Slice(spec.paths, func(i, j int) bool {
+		if spec.paths[i].params != spec.paths[j].params {
+			return spec.paths[i].params < spec.paths[j].params
+		}
+		return spec.paths[i].template < spec.paths[j].template
+	})
+
+	if err := spec.compilePatterns(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Operations возвращает все пары "МЕТОД путь", описанные в спецификации.
+func (s *Spec) Operations() []string {
+	var ops []string
+	for _, item := range s.paths {
+		for method := range item.operations {
+			ops = append(ops, method+" "+item.template)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// Handler отдает спецификацию в формате JSON.
+func (s *Spec) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(s.raw); err != nil {
+			logger.Logger.Error("Error writing OpenAPI document", zap.Error(err))
+		}
+	}
+}
+
+// findOperation ищет операцию по методу и пути запроса и возвращает значения параметров пути.
+func (s *Spec) findOperation(method, path string) (*Operation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, item := range s.paths {
+		params, ok := item.match(segments)
+		if !ok {
+			continue
+		}
+		op, ok := item.operations[method]
+		if !ok {
+			return nil, nil
+		}
+		return op, params
+	}
+	return nil, nil
+}
+
+func (p *pathItem) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(p.segments) {
+		return nil, false
+	}
+	params := make(map[string]string, p.params)
+	for i, seg := range p.segments {
+		if isParamSegment(seg) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func isParamSegment(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+func (s *Spec) resolveParameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+	resolved, ok := s.components.Parameters[name]
+	if !ok {
+		return nil, fmt.Errorf("unresolved parameter reference %q", p.Ref)
+	}
+	return resolved, nil
+}
+
+func (s *Spec) resolveSchema(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// compilePatterns компилирует регулярные выражения всех схем заранее,
+// чтобы ошибки в спецификации обнаруживались при запуске.
+func (s *Spec) compilePatterns() error {
+	var compile func(schema *Schema) error
+	compile = func(schema *Schema) error {
+		if schema == nil {
+			return nil
+		}
+		if schema.Pattern != "" && schema.pattern == nil {
+			re, err := regexp.Compile(schema.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", schema.Pattern, err)
+			}
+			schema.pattern = re
+		}
+		for _, prop := range schema.Properties {
+			if err := compile(prop); err != nil {
+				return err
+			}
+		}
+		for _, sub := range schema.AllOf {
+			if err := compile(sub); err != nil {
+				return err
+			}
+		}
+		return compile(schema.Items)
+	}
+
+	for _, schema := range s.components.Schemas {
+		if err := compile(schema); err != nil {
+			return err
+		}
+	}
+	for _, param := range s.components.Parameters {
+		if err := compile(param.Schema); err != nil {
+			return err
+		}
+	}
+	for _, item := range s.paths {
+		for _, op := range item.operations {
+			for _, param := range op.Parameters {
+				if err := compile(param.Schema); err != nil {
+					return err
+				}
+			}
+			if op.RequestBody == nil {
+				continue
+			}
+			for _, mt := range op.RequestBody.Content {
+				if err := compile(mt.Schema); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"shorturl/internal/apierror"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate middleware проверяет параметры и тело запроса на соответствие спецификации.
+// Запросы к путям, не описанным в спецификации, передаются дальше без проверки.
+// Должен подключаться после GzipRequest и BodyLimit, чтобы проверять распакованное тело.
+func (s *Spec) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, pathParams := s.findOperation(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		problem := apierror.New(http.StatusBadRequest, apierror.CodeValidation, "Request does not match the API specification")
+		s.validateParameters(op, pathParams, r.URL.Query(), problem)
+
+		if op.RequestBody != nil {
+			if p := s.validateBody(op.RequestBody, r, problem); p != nil {
+				apierror.WriteProblem(w, r, p)
+				return
+			}
+		}
+
+		if len(problem.Errors) > 0 {
+			apierror.WriteProblem(w, r, problem)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Spec) validateParameters(op *Operation, pathParams map[string]string, query url.Values, problem *apierror.Problem) {
+	for _, param := range op.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = pathParams[param.Name]
+		case "query":
+			present = query.Has(param.Name)
+			value = query.Get(param.Name)
+		default:
+			continue
+		}
+		if !present {
+			if param.Required {
+				problem.WithField(param.Name, apierror.CodeRequired, "parameter is required")
+			}
+			continue
+		}
+		s.validateValue(param.Schema, coerceParameter(s.resolveSchema(param.Schema), value), param.Name, problem)
+	}
+}
+
+// validateBody проверяет Content-Type и содержимое тела запроса. Ошибки полей
+// добавляются в problem; для ошибок, требующих другого статуса (413, 415),
+// возвращается отдельный Problem.
+func (s *Spec) validateBody(rb *RequestBody, r *http.Request, problem *apierror.Problem) *apierror.Problem {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	content, ok := rb.Content[mediaType]
+	if !ok {
+		content, ok = rb.Content["*/*"]
+	}
+	if !ok {
+		allowed := make([]string, 0, len(rb.Content))
+		for mt := range rb.Content {
+			allowed = append(allowed, mt)
+		}
+		return apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+			fmt.Sprintf("Content-Type must be one of: %s", strings.Join(allowed, ", ")))
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+				fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit))
+		}
+		return apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "Failed to read request body")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) == 0 {
+		if rb.Required {
+			problem.WithField("body", apierror.CodeRequired, "request body is required")
+		}
+		return nil
+	}
+
+	if !isJSON(mediaType) {
+		s.validateValue(content.Schema, string(body), "body", problem)
+		return nil
+	}
+
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidJSON, "Request body is not valid JSON")
+	}
+	s.validateValue(content.Schema, value, "", problem)
+	return nil
+}
+
+// validateValue проверяет значение value, полученное из JSON или параметра, по схеме.
+func (s *Spec) validateValue(schema *Schema, value any, field string, problem *apierror.Problem) {
+	schema = s.resolveSchema(schema)
+	if schema == nil {
+		return
+	}
+	for _, sub := range schema.AllOf {
+		s.validateValue(sub, value, field, problem)
+	}
+	name := field
+	if name == "" {
+		name = "body"
+	}
+
+	if !matchesType(schema.Type, value) {
+		problem.WithField(name, apierror.CodeInvalidType, fmt.Sprintf("must be of type %s", schema.Type))
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		problem.WithField(name, apierror.CodeInvalidValue, "must be one of the allowed values")
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		s.validateString(schema, v, name, problem)
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			problem.WithField(name, apierror.CodeInvalidType, "must be a number")
+			return
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must be at least %v", *schema.Minimum))
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must be at most %v", *schema.Maximum))
+		}
+	case map[string]any:
+		for _, req := range schema.Required {
+			if _, ok := v[req]; !ok {
+				problem.WithField(joinField(field, req), apierror.CodeRequired, "field is required")
+			}
+		}
+		for key, propValue := range v {
+			prop, ok := schema.Properties[key]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					problem.WithField(joinField(field, key), apierror.CodeInvalidValue, "unknown field")
+				}
+				continue
+			}
+			s.validateValue(prop, propValue, joinField(field, key), problem)
+		}
+	case []any:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must contain at least %d items", *schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must contain at most %d items", *schema.MaxItems))
+		}
+		for i, item := range v {
+			s.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), problem)
+		}
+	}
+}
+
+func (s *Spec) validateString(schema *Schema, v, name string, problem *apierror.Problem) {
+	length := utf8.RuneCountInString(v)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must be at least %d characters long", *schema.MinLength))
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must be at most %d characters long", *schema.MaxLength))
+	}
+	if schema.pattern != nil && !schema.pattern.MatchString(v) {
+		problem.WithField(name, apierror.CodeInvalidValue, fmt.Sprintf("must match pattern %s", schema.Pattern))
+	}
+	switch schema.Format {
+	case "uri":
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem.WithField(name, apierror.CodeInvalidURL, "must be an absolute http:// or https:// URL")
+		}
+	}
+}
+
+// coerceParameter приводит строковое значение параметра к типу схемы,
+// чтобы параметры и JSON проверялись одним кодом.
+func coerceParameter(schema *Schema, value string) any {
+	if schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		return json.Number(value)
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func matchesType(typ string, value any) bool {
+	switch typ {
+	case "":
+		return true
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Float64()
+		return err == nil
+	}
+	return false
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if n, ok := value.(json.Number); ok {
+			if f, err := n.Float64(); err == nil && reflect.DeepEqual(e, f) {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
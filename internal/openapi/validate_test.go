@@ -0,0 +1,80 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"shorturl/internal/apierror"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/openapi"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitializeLogger(&config.Config{LogLevel: "info", LogFormat: "text"})
+	os.Exit(m.Run())
+}
+
+// TestValidate проверяет middleware валидации запросов по спецификации.
+func TestValidate(t *testing.T) {
+	spec := openapi.MustLoad()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := spec.Validate(ok)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		expectedCode   int
+		expectedFields []string
+	}{
+		{"Valid shorten", http.MethodPost, "/api/shorten", "application/json", `{"url":"https://example.com"}`, http.StatusNoContent, nil},
+		{"Missing url", http.MethodPost, "/api/shorten", "application/json", `{}`, http.StatusBadRequest, []string{"url"}},
+		{"Wrong type", http.MethodPost, "/api/shorten", "application/json", `{"url":42}`, http.StatusBadRequest, []string{"url"}},
+		{"Invalid JSON", http.MethodPost, "/api/shorten", "application/json", `{"url":`, http.StatusBadRequest, nil},
+		{"Wrong content type", http.MethodPost, "/api/shorten", "text/plain", `{"url":"https://example.com"}`, http.StatusUnsupportedMediaType, nil},
+		{"Batch item", http.MethodPost, "/api/shorten/batch", "application/json", `[{"correlation_id":"1","original_url":"nope"}]`, http.StatusBadRequest, []string{"[0].original_url"}},
+		{"Text body", http.MethodPost, "/", "text/plain", `https://example.com`, http.StatusNoContent, nil},
+		{"Empty text body", http.MethodPost, "/", "text/plain", ``, http.StatusBadRequest, []string{"body"}},
+		{"Short ID pattern", http.MethodGet, "/abc-defg", "", "", http.StatusBadRequest, []string{"shortID"}},
+		{"Static path wins", http.MethodGet, "/ping", "", "", http.StatusNoContent, nil},
+		{"Undocumented path", http.MethodGet, "/a/b/c", "", "", http.StatusNoContent, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d (%s)", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if len(tt.expectedFields) == 0 {
+				return
+			}
+			var problem apierror.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("Failed to unmarshal problem: %v", err)
+			}
+			if len(problem.Errors) != len(tt.expectedFields) {
+				t.Fatalf("Expected fields %v, got %+v", tt.expectedFields, problem.Errors)
+			}
+			for i, field := range tt.expectedFields {
+				if problem.Errors[i].Field != field {
+					t.Errorf("Expected field %s, got %s", field, problem.Errors[i].Field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
+	"shorturl/internal/netguard"
+	"shorturl/internal/storage"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Параметры загрузчика по умолчанию.
+const (
+	DefaultWorkers      = 4
+	DefaultQueueSize    = 1024
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxBodyBytes = 512 << 10 // 512 KiB
+	DefaultUserAgent    = "shorturl-preview/1.0"
+
+	maxRedirects = 5
+)
+
+// ErrDisallowed возвращается, если robots.txt запрещает загрузку страницы.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Store — хранилище, в которое загрузчик записывает сведения о страницах.
+type Store interface {
+	SetURLPreview(ctx context.Context, shortID, originalURL string, preview storage.Preview) error
+}
+
+type job struct {
+	shortID     string
+	originalURL string
+}
+
+// Fetcher в фоне загружает страницы назначения ссылок пулом воркеров и
+// сохраняет их заголовок, описание и иконку. Загрузка соблюдает robots.txt,
+// ограничена по времени и размеру ответа и не ходит во внутреннюю сеть:
+// адрес проверяется после разрешения имени, непосредственно перед соединением.
+type Fetcher struct {
+	store        Store
+	client       *http.Client
+	robots       *robotsCache
+	workers      int
+	queueSize    int
+	timeout      time.Duration
+	maxBodyBytes int64
+	userAgent    string
+	// allowPrivate отключает проверку адресов; используется в тестах,
+	// где страницы отдает httptest-сервер на 127.0.0.1.
+	allowPrivate bool
+
+	queue   chan job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// Option настраивает Fetcher.
+type Option func(*Fetcher)
+
+// WithWorkers задает число параллельных загрузок.
+func WithWorkers(n int) Option {
+	return func(f *Fetcher) { f.workers = n }
+}
+
+// WithQueueSize задает емкость очереди; ссылки сверх нее не загружаются.
+func WithQueueSize(n int) Option {
+	return func(f *Fetcher) { f.queueSize = n }
+}
+
+// WithTimeout ограничивает время загрузки одной страницы вместе с robots.txt.
+func WithTimeout(d time.Duration) Option {
+	return func(f *Fetcher) { f.timeout = d }
+}
+
+// WithMaxBodyBytes ограничивает число читаемых байт ответа.
+func WithMaxBodyBytes(n int64) Option {
+	return func(f *Fetcher) { f.maxBodyBytes = n }
+}
+
+// WithUserAgent задает User-Agent запросов; по нему же выбираются правила robots.txt.
+func WithUserAgent(ua string) Option {
+	return func(f *Fetcher) { f.userAgent = ua }
+}
+
+// NewFetcher создает загрузчик, сохраняющий результаты в store, и запускает его воркеры.
+func NewFetcher(store Store, opts ...Option) *Fetcher {
+	f := &Fetcher{
+		store:        store,
+		workers:      DefaultWorkers,
+		queueSize:    DefaultQueueSize,
+		timeout:      DefaultTimeout,
+		maxBodyBytes: DefaultMaxBodyBytes,
+		userAgent:    DefaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	f.workers = max(f.workers, 1)
+	f.queueSize = max(f.queueSize, 1)
+
+	dialer := &net.Dialer{Timeout: f.timeout, Control: f.checkAddress}
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// Прокси из окружения не используется: иначе проверялся бы адрес
+			// прокси, а не страницы.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   f.timeout,
+			ResponseHeaderTimeout: f.timeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: f.checkRedirect,
+	}
+	f.robots = newRobotsCache(f.client, f.userAgent)
+
+	f.queue = make(chan job, f.queueSize)
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+	f.wg.Add(f.workers)
+	for range f.workers {
+		go f.run()
+	}
+	return f
+}
+
+// Enqueue ставит ссылку в очередь загрузки. Не блокируется: при полной
+// очереди или остановленном загрузчике ссылка пропускается и возвращается false.
+func (f *Fetcher) Enqueue(shortID, originalURL string) bool {
+	if f.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case f.queue <- job{shortID: shortID, originalURL: originalURL}:
+		return true
+	default:
+		f.dropped.Add(1)
+		metrics.PreviewFetches.Inc("dropped")
+		return false
+	}
+}
+
+// QueueLen возвращает число ссылок, ожидающих загрузки.
+func (f *Fetcher) QueueLen() int {
+	return len(f.queue)
+}
+
+// QueueCap возвращает емкость очереди загрузки.
+func (f *Fetcher) QueueCap() int {
+	return cap(f.queue)
+}
+
+// Dropped возвращает число ссылок, пропущенных из-за переполнения очереди.
+func (f *Fetcher) Dropped() int64 {
+	return f.dropped.Load()
+}
+
+// Close прерывает текущие загрузки, отбрасывает очередь и ждет остановки воркеров.
+func (f *Fetcher) Close() error {
+	f.once.Do(func() {
+		f.cancel()
+		f.wg.Wait()
+		f.client.CloseIdleConnections()
+	})
+	return nil
+}
+
+func (f *Fetcher) run() {
+	defer f.wg.Done()
+	for {
+		select {
+		case j := <-f.queue:
+			f.process(j)
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
+
+// process загружает страницу ссылки и сохраняет результат. Ссылку могли
+// перенаправить на другой адрес, пока шла загрузка: тогда хранилище
+// возвращает storage.ErrNotFound и результат отбрасывается.
+func (f *Fetcher) process(j job) {
+	ctx, cancel := context.WithTimeout(f.ctx, f.timeout)
+	defer cancel()
+
+	p, err := f.Fetch(ctx, j.originalURL)
+	metrics.PreviewFetches.Inc(result(err))
+	if err != nil {
+		logger.Logger.Debug("Failed to fetch link preview",
+			zap.String("short_url", j.shortID), zap.String("url", j.originalURL), zap.Error(err))
+		return
+	}
+
+	// Сохранение не зависит от остановки загрузчика: страница уже загружена.
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelSave()
+	err = f.store.SetURLPreview(saveCtx, j.shortID, j.originalURL, p)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logger.Logger.Warn("Failed to save link preview", zap.String("short_url", j.shortID), zap.Error(err))
+	}
+}
+
+// Fetch загружает страницу rawURL и извлекает из нее сведения для Preview.
+// Для ответов не в HTML заполняются только иконка по умолчанию и время загрузки.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (storage.Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return storage.Preview{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return storage.Preview{}, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if err := f.robots.check(ctx, u); err != nil {
+		return storage.Preview{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return storage.Preview{}, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return storage.Preview{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return storage.Preview{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// После перенаправлений относительные адреса разрешаются от итоговой страницы.
+	base := resp.Request.URL
+	p := storage.Preview{FetchedAt: time.Now().UTC().Truncate(time.Microsecond)}
+	if isHTML(resp.Header.Get("Content-Type")) {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBodyBytes))
+		if err != nil {
+			return storage.Preview{}, err
+		}
+		p = parseHead(body, base, p)
+	}
+	if p.FaviconURL == "" {
+		p.FaviconURL = base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	}
+	return p, nil
+}
+
+// checkRedirect ограничивает число перенаправлений, разрешает только http(s)
+// и применяет robots.txt к каждому новому адресу страницы.
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+	}
+	if req.Context().Value(robotsFetchKey{}) != nil {
+		return nil
+	}
+	return f.robots.check(req.Context(), req.URL)
+}
+
+// checkAddress отклоняет соединения с внутренней сетью (см. netguard.Control).
+func (f *Fetcher) checkAddress(network, address string, c syscall.RawConn) error {
+	if f.allowPrivate {
+		return nil
+	}
+	return netguard.Control(network, address, c)
+}
+
+// isHTML сообщает, что Content-Type описывает HTML-документ.
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// result возвращает метку метрики PreviewFetches для результата загрузки.
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrDisallowed):
+		return "disallowed"
+	case errors.Is(err, netguard.ErrBlockedAddress):
+		return "blocked"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
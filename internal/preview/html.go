@@ -0,0 +1,193 @@
+package preview
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"shorturl/internal/storage"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Предельная длина сохраняемых заголовка и описания в символах.
+const (
+	maxTitleRunes       = 300
+	maxDescriptionRunes = 1000
+)
+
+// parseHead извлекает из начала HTML-документа заголовок, описание и иконку
+// и дописывает их в p. Разбор упрощенный: он ищет теги title, meta и link до
+// начала body и не строит дерево документа. Мета-теги Open Graph используются,
+// если обычных title и description нет. Относительные адреса разрешаются от base.
+func parseHead(doc []byte, base *url.URL, p storage.Preview) storage.Preview {
+	var title, ogTitle, description, ogDescription, icon string
+
+	for i := 0; i < len(doc); {
+		lt := bytes.IndexByte(doc[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		if bytes.HasPrefix(doc[i:], []byte("<!--")) {
+			end := bytes.Index(doc[i+4:], []byte("-->"))
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+		name, attrs, next := scanTag(doc, i)
+		i = next
+		switch name {
+		case "title":
+			end := indexFold(doc[i:], "</title")
+			if end < 0 {
+				end = len(doc) - i
+			}
+			if title == "" {
+				title = string(doc[i : i+end])
+			}
+			i += end
+		case "meta":
+			key := strings.ToLower(attrs["name"])
+			if key == "" {
+				key = strings.ToLower(attrs["property"])
+			}
+			switch key {
+			case "description":
+				description = attrs["content"]
+			case "og:title":
+				ogTitle = attrs["content"]
+			case "og:description":
+				ogDescription = attrs["content"]
+			}
+		case "link":
+			// rel="shortcut icon" тоже содержит токен icon.
+			if icon == "" && slices.Contains(strings.Fields(strings.ToLower(attrs["rel"])), "icon") {
+				icon = attrs["href"]
+			}
+		case "body", "/head":
+			i = len(doc)
+		}
+	}
+
+	p.Title = cleanText(firstNonEmpty(title, ogTitle), maxTitleRunes)
+	p.Description = cleanText(firstNonEmpty(description, ogDescription), maxDescriptionRunes)
+	p.FaviconURL = resolveIcon(base, icon)
+	return p
+}
+
+// scanTag разбирает тег, начинающийся с '<' в позиции start, и возвращает
+// его имя в нижнем регистре, атрибуты и позицию после '>'.
+func scanTag(doc []byte, start int) (string, map[string]string, int) {
+	i := start + 1
+	nameStart := i
+	for i < len(doc) && !isSpace(doc[i]) && doc[i] != '>' && !(doc[i] == '/' && i > nameStart) {
+		i++
+	}
+	name := strings.ToLower(string(doc[nameStart:i]))
+
+	attrs := make(map[string]string)
+	for i < len(doc) {
+		for i < len(doc) && (isSpace(doc[i]) || doc[i] == '/') {
+			i++
+		}
+		if i >= len(doc) {
+			break
+		}
+		if doc[i] == '>' {
+			return name, attrs, i + 1
+		}
+		keyStart := i
+		for i < len(doc) && !isSpace(doc[i]) && doc[i] != '=' && doc[i] != '>' && doc[i] != '/' {
+			i++
+		}
+		key := strings.ToLower(string(doc[keyStart:i]))
+		for i < len(doc) && isSpace(doc[i]) {
+			i++
+		}
+		if i >= len(doc) || doc[i] != '=' {
+			if _, ok := attrs[key]; !ok {
+				attrs[key] = ""
+			}
+			continue
+		}
+		i++
+		for i < len(doc) && isSpace(doc[i]) {
+			i++
+		}
+		var value string
+		if i < len(doc) && (doc[i] == '"' || doc[i] == '\'') {
+			quote := doc[i]
+			end := bytes.IndexByte(doc[i+1:], quote)
+			if end < 0 {
+				end = len(doc) - i - 1
+			}
+			value = string(doc[i+1 : i+1+end])
+			i += end + 2
+		} else {
+			valueStart := i
+			for i < len(doc) && !isSpace(doc[i]) && doc[i] != '>' {
+				i++
+			}
+			value = string(doc[valueStart:i])
+		}
+		// Повторный атрибут игнорируется, как в браузерах.
+		if _, ok := attrs[key]; !ok {
+			attrs[key] = value
+		}
+	}
+	return name, attrs, len(doc)
+}
+
+// resolveIcon разрешает адрес иконки от base; допускаются только http(s)-адреса.
+func resolveIcon(base *url.URL, href string) string {
+	href = strings.TrimSpace(html.UnescapeString(href))
+	if href == "" {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	u := base.ResolveReference(ref)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+// cleanText декодирует HTML-сущности, схлопывает пробельные символы,
+// заменяет некорректный UTF-8 и обрезает текст до limit символов.
+func cleanText(s string, limit int) string {
+	s = strings.Join(strings.Fields(html.UnescapeString(strings.ToValidUTF8(s, "�"))), " ")
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit])
+}
+
+// indexFold ищет sub в s без учета регистра.
+func indexFold(s []byte, sub string) int {
+	n := len(sub)
+	for i := 0; i+n <= len(s); i++ {
+		if bytes.EqualFold(s[i:i+n], []byte(sub)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,308 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"shorturl/internal/netguard"
+	"shorturl/internal/storage"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore запоминает сохраненные загрузчиком сведения.
+type memoryStore struct {
+	mu       sync.Mutex
+	previews map[string]storage.Preview
+}
+
+func (s *memoryStore) SetURLPreview(_ context.Context, shortID, _ string, p storage.Preview) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.previews[shortID] = p
+	return nil
+}
+
+func (s *memoryStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.previews)
+}
+
+// newTestFetcher создает загрузчик, которому разрешено ходить на httptest-серверы.
+func newTestFetcher(t *testing.T, store Store, opts ...Option) *Fetcher {
+	t.Helper()
+	f := NewFetcher(store, opts...)
+	f.allowPrivate = true
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/blog/post", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/blog/post", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, `<!DOCTYPE html>
+<html><head>
+<!-- <title>Commented out</title> -->
+<META name="Description" content="Tips &amp; tricks">
+<meta property="og:title" content="OG title">
+<link rel="shortcut icon" href="../static/icon.png">
+<title>
+  Go &amp; shortening
+</title>
+</head><body><title>Not a title</title></body></html>`)
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = fmt.Fprint(w, "%PDF-1.4 <title>Nope</title>")
+	})
+	mux.HandleFunc("/og", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<head><meta property="og:title" content='Only OG'><meta property="og:description" content=Short></head>`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := newTestFetcher(t, &memoryStore{})
+	p, err := f.Fetch(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if p.Title != "Go & shortening" {
+		t.Errorf("Expected title %q, got %q", "Go & shortening", p.Title)
+	}
+	if p.Description != "Tips & tricks" {
+		t.Errorf("Expected description %q, got %q", "Tips & tricks", p.Description)
+	}
+	// Относительная иконка разрешается от страницы после перенаправления.
+	if want := srv.URL + "/static/icon.png"; p.FaviconURL != want {
+		t.Errorf("Expected favicon %q, got %q", want, p.FaviconURL)
+	}
+	if p.FetchedAt.IsZero() {
+		t.Errorf("Expected fetch time to be set")
+	}
+
+	p, err = f.Fetch(context.Background(), srv.URL+"/og")
+	if err != nil || p.Title != "Only OG" || p.Description != "Short" {
+		t.Errorf("Expected Open Graph fallback, got %+v, %v", p, err)
+	}
+
+	p, err = f.Fetch(context.Background(), srv.URL+"/file.pdf")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if p.Title != "" || p.FaviconURL != srv.URL+"/favicon.ico" {
+		t.Errorf("Expected only the default favicon for non-HTML, got %+v", p)
+	}
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Errorf("Expected error for 404 page")
+	}
+}
+
+func TestFetchRespectsRobots(t *testing.T) {
+	var pageHits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `User-agent: *
+Disallow: /
+
+User-agent: shorturl-preview
+Disallow: /private
+Allow: /private/open$
+`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jump" {
+			http.Redirect(w, r, "/private/page", http.StatusFound)
+			return
+		}
+		pageHits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, "<title>ok</title>")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := newTestFetcher(t, &memoryStore{})
+	ctx := context.Background()
+	for path, allowed := range map[string]bool{
+		"/public":        true,
+		"/private/page":  false,
+		"/private/open":  true,
+		"/private/open2": false,
+		"/jump":          false, // запрещен адрес перенаправления
+	} {
+		_, err := f.Fetch(ctx, srv.URL+path)
+		if allowed && err != nil {
+			t.Errorf("Expected %s to be fetched, got %v", path, err)
+		}
+		if !allowed && !errors.Is(err, ErrDisallowed) {
+			t.Errorf("Expected %s to be disallowed, got %v", path, err)
+		}
+	}
+	if hits := pageHits.Load(); hits != 2 {
+		t.Errorf("Expected only allowed pages to be requested, got %d requests", hits)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	// Группа нашего агента без правил разрешает все, даже если «*» запрещает.
+	p := parseRobots([]byte("User-agent: *\nDisallow: /\n\nUser-agent: ShortURL-Preview\nDisallow:\n"), DefaultUserAgent)
+	if !p.allowed("/anything") {
+		t.Errorf("Expected specific group to take precedence over *")
+	}
+
+	p = parseRobots([]byte("user-agent: other\ndisallow: /\nuser-agent: *\ndisallow: /*.pdf$\n"), DefaultUserAgent)
+	for path, want := range map[string]bool{"/doc.pdf": false, "/a/b.pdf": false, "/doc.pdf?x=1": true, "/": true} {
+		if got := p.allowed(path); got != want {
+			t.Errorf("Expected allowed(%q) = %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestFetchLimitsBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, "<head><meta name=description content=early>", strings.Repeat(" ", 4096), "<title>Too far</title>")
+	}))
+	defer srv.Close()
+
+	f := newTestFetcher(t, &memoryStore{}, WithMaxBodyBytes(1024))
+	p, err := f.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if p.Description != "early" || p.Title != "" {
+		t.Errorf("Expected only content within the size limit, got %+v", p)
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+
+	f := NewFetcher(&memoryStore{})
+	defer func() { _ = f.Close() }()
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, netguard.ErrBlockedAddress) {
+		t.Errorf("Expected ErrBlockedAddress for loopback server, got %v", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Expected no request to reach the server")
+	}
+	if _, err := f.Fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Errorf("Expected non-HTTP scheme to be rejected")
+	}
+}
+
+func TestFetcherWorkers(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, "<title>Page %s</title>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{previews: make(map[string]storage.Preview)}
+	f := newTestFetcher(t, store, WithWorkers(2))
+	for i := range 6 {
+		if !f.Enqueue(fmt.Sprintf("id%d", i), fmt.Sprintf("%s/%d", srv.URL, i)) {
+			t.Fatalf("Expected link %d to be queued", i)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.len() < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if store.len() != 6 {
+		t.Fatalf("Expected 6 stored previews, got %d", store.len())
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("Expected at most 2 concurrent fetches, got %d", p)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if got := store.previews["id3"].Title; got != "Page /3" {
+		t.Errorf("Expected title of page 3, got %q", got)
+	}
+}
+
+func TestFetcherTimeoutAndBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f := newTestFetcher(t, &memoryStore{}, WithTimeout(50*time.Millisecond))
+	start := time.Now()
+	if _, err := f.Fetch(context.Background(), srv.URL+"/slow"); err == nil {
+		t.Errorf("Expected slow page to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected fetch to stop at the timeout, took %s", elapsed)
+	}
+
+	// Очередь не блокирует создание ссылок: лишние ссылки пропускаются.
+	store := &memoryStore{previews: make(map[string]storage.Preview)}
+	f = newTestFetcher(t, store, WithWorkers(1), WithQueueSize(1), WithTimeout(time.Minute))
+	queued := 0
+	for i := range 10 {
+		if f.Enqueue(fmt.Sprintf("id%d", i), srv.URL+"/slow") {
+			queued++
+		}
+	}
+	if queued > 2 || f.Dropped() != int64(10-queued) {
+		t.Errorf("Expected at most 2 queued links, got %d queued and %d dropped", queued, f.Dropped())
+	}
+
+	// Close прерывает зависшие загрузки.
+	done := make(chan struct{})
+	go func() {
+		_ = f.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not interrupt in-flight fetches")
+	}
+	if f.Enqueue("late", srv.URL) {
+		t.Errorf("Expected Enqueue to fail after Close")
+	}
+	if store.len() != 0 {
+		t.Errorf("Expected nothing to be stored, got %d previews", store.len())
+	}
+}
@@ -0,0 +1,226 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры кэша robots.txt.
+const (
+	robotsTTL      = time.Hour
+	robotsMaxHosts = 4096
+	robotsMaxBytes = 64 << 10 // RFC 9309 требует читать не меньше 500 KiB, но нам хватает начала
+)
+
+// robotsRule — строка Allow или Disallow; pattern может содержать * и $.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsPolicy — правила robots.txt, относящиеся к нашему User-Agent.
+type robotsPolicy struct {
+	rules   []robotsRule
+	expires time.Time
+}
+
+// allowed применяет правило с самым длинным совпавшим шаблоном; при равной
+// длине Allow важнее Disallow (RFC 9309, 2.2.2).
+func (p *robotsPolicy) allowed(path string) bool {
+	best, allow := -1, true
+	for _, r := range p.rules {
+		if !robotsMatch(r.pattern, path) {
+			continue
+		}
+		if n := len(r.pattern); n > best || (n == best && r.allow) {
+			best, allow = n, r.allow
+		}
+	}
+	return allow
+}
+
+// robotsCache загружает robots.txt хостов и хранит разобранные правила robotsTTL.
+type robotsCache struct {
+	client    *http.Client
+	userAgent string
+	now       func() time.Time
+
+	mu       sync.Mutex
+	policies map[string]*robotsPolicy
+}
+
+func newRobotsCache(client *http.Client, userAgent string) *robotsCache {
+	return &robotsCache{
+		client:    client,
+		userAgent: userAgent,
+		now:       time.Now,
+		policies:  make(map[string]*robotsPolicy),
+	}
+}
+
+// check возвращает ErrDisallowed, если robots.txt хоста u запрещает его загрузку.
+func (c *robotsCache) check(ctx context.Context, u *url.URL) error {
+	policy, err := c.policy(ctx, u)
+	if err != nil {
+		return err
+	}
+	if !policy.allowed(u.EscapedPath()) {
+		return ErrDisallowed
+	}
+	return nil
+}
+
+func (c *robotsCache) policy(ctx context.Context, u *url.URL) (*robotsPolicy, error) {
+	key := u.Scheme + "://" + u.Host
+	now := c.now()
+
+	c.mu.Lock()
+	policy, ok := c.policies[key]
+	c.mu.Unlock()
+	if ok && now.Before(policy.expires) {
+		return policy, nil
+	}
+
+	policy, err := c.fetch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	policy.expires = now.Add(robotsTTL)
+
+	c.mu.Lock()
+	if len(c.policies) >= robotsMaxHosts {
+		// Кэш переполнен: удаляем истекшие записи, а если их нет — любую.
+		for k, p := range c.policies {
+			if now.After(p.expires) || len(c.policies) >= robotsMaxHosts {
+				delete(c.policies, k)
+			}
+		}
+	}
+	c.policies[key] = policy
+	c.mu.Unlock()
+	return policy, nil
+}
+
+// robotsFetchKey помечает контекст загрузки самого robots.txt: его
+// перенаправления не проверяются по robots.txt.
+type robotsFetchKey struct{}
+
+// fetch загружает robots.txt. Отсутствующий файл (4xx) разрешает все;
+// ошибка сервера запрещает все до следующей попытки (RFC 9309, 2.3.1).
+func (c *robotsCache) fetch(ctx context.Context, origin string) (*robotsPolicy, error) {
+	ctx = context.WithValue(ctx, robotsFetchKey{}, true)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch robots.txt: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read robots.txt: %w", err)
+		}
+		return parseRobots(body, c.userAgent), nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return &robotsPolicy{}, nil
+	default:
+		return &robotsPolicy{rules: []robotsRule{{pattern: "/"}}}, nil
+	}
+}
+
+// parseRobots выбирает из robots.txt группы, относящиеся к userAgent: группы с
+// его токеном продукта (часть до «/»), а если таких нет — группы «*».
+func parseRobots(body []byte, userAgent string) *robotsPolicy {
+	product := strings.ToLower(userAgent)
+	if i := strings.IndexByte(product, '/'); i >= 0 {
+		product = product[:i]
+	}
+
+	var specific, wildcard []robotsRule
+	var matchSpecific, matchWildcard, inRules, hasSpecific bool
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// User-agent после правил начинает новую группу.
+			if inRules {
+				matchSpecific, matchWildcard, inRules = false, false, false
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				matchWildcard = true
+			case agent == product:
+				matchSpecific, hasSpecific = true, true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// Пустой Disallow ничего не запрещает.
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			if matchSpecific {
+				specific = append(specific, rule)
+			}
+			if matchWildcard {
+				wildcard = append(wildcard, rule)
+			}
+		}
+	}
+	if hasSpecific {
+		return &robotsPolicy{rules: specific}
+	}
+	return &robotsPolicy{rules: wildcard}
+}
+
+// robotsMatch сопоставляет путь с шаблоном robots.txt: * — любая
+// последовательность символов, $ в конце — конец пути.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || path == ""
+	}
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+	if anchored {
+		return strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path, last)
+}
@@ -0,0 +1,47 @@
+package preview
+
+import (
+	"context"
+	"shorturl/internal/service"
+	"shorturl/internal/storage"
+)
+
+// Storage — декоратор хранилища, ставящий в очередь загрузчика новые ссылки
+// и ссылки со смененным адресом. Остальные методы проксируются как есть.
+type Storage struct {
+	service.ShortURLCreatorGetter
+	fetcher *Fetcher
+}
+
+// NewStorage оборачивает store: успешно созданные и перенаправленные ссылки
+// передаются fetcher.
+func NewStorage(store service.ShortURLCreatorGetter, fetcher *Fetcher) *Storage {
+	return &Storage{ShortURLCreatorGetter: store, fetcher: fetcher}
+}
+
+// CreateShortURL создает ссылку и ставит ее страницу в очередь загрузки.
+func (s *Storage) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
+	shortID, err := s.ShortURLCreatorGetter.CreateShortURL(ctx, userID, originalURL)
+	if err == nil {
+		s.fetcher.Enqueue(shortID, originalURL)
+	}
+	return shortID, err
+}
+
+// CreateURL создает ссылку и ставит ее страницу в очередь загрузки.
+func (s *Storage) CreateURL(ctx context.Context, pair storage.URLPair) (string, error) {
+	shortID, err := s.ShortURLCreatorGetter.CreateURL(ctx, pair)
+	if err == nil {
+		s.fetcher.Enqueue(shortID, pair.OriginalURL)
+	}
+	return shortID, err
+}
+
+// UpdateOriginalURL меняет адрес ссылки и ставит новую страницу в очередь загрузки.
+func (s *Storage) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (storage.URLPair, error) {
+	pair, err := s.ShortURLCreatorGetter.UpdateOriginalURL(ctx, userID, shortID, originalURL)
+	if err == nil && pair.Preview.IsZero() {
+		s.fetcher.Enqueue(shortID, pair.OriginalURL)
+	}
+	return pair, err
+}
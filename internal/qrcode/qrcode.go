@@ -0,0 +1,441 @@
+// Package qrcode кодирует данные в QR-код модели 2 (ISO/IEC 18004) в
+// байтовом режиме и рисует его в PNG или SVG. Версия символа выбирается
+// минимальной, в которую помещаются данные при заданном уровне коррекции.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level — уровень коррекции ошибок: доля поврежденных модулей, при которой
+// код еще читается.
+type Level int
+
+const (
+	Low      Level = iota // около 7%
+	Medium                // около 15%
+	Quartile              // около 25%
+	High                  // около 30%
+)
+
+// ErrTooLong возвращается, если данные не помещаются даже в QR-код версии 40.
+var ErrTooLong = errors.New("data too long for a QR code")
+
+// ParseLevel разбирает уровень коррекции по букве L, M, Q или H.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", s)
+}
+
+func (l Level) String() string {
+	return [...]string{"L", "M", "Q", "H"}[l]
+}
+
+// formatBits возвращает двухбитный код уровня в информации о формате.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+// Code — QR-код: квадрат из Size×Size модулей без поля вокруг.
+type Code struct {
+	Size    int
+	Version int
+	Level   Level
+	Mask    int
+
+	modules    []bool
+	isFunction []bool
+}
+
+// Black сообщает, что модуль (x, y) темный. Координаты вне символа — светлые.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// Encode кодирует data с уровнем коррекции level.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("unknown error correction level %d", level)
+	}
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if 4+charCountBits(v)+8*len(data) <= numDataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	// Байтовый режим: индикатор 0100, длина, данные, терминатор и заполнение.
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := numDataCodewords(version, level) * 8
+	bb.append(0, min(4, capacity-bb.len()))
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := 0xEC; bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	c := &Code{Version: version, Level: level, Size: version*4 + 17}
+	c.modules = make([]bool, c.Size*c.Size)
+	c.isFunction = make([]bool, c.Size*c.Size)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addECCAndInterleave(bb.bytes(), version, level))
+
+	// Выбираем маску с наименьшим штрафом, как требует стандарт.
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // маска снимается повторным применением
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	c.isFunction = nil
+	return c, nil
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.isFunction[y*c.Size+x] = true
+}
+
+// drawFunctionPatterns рисует поисковые и выравнивающие узоры, линии
+// синхронизации и резервирует место под информацию о формате и версии.
+func (c *Code) drawFunctionPatterns() {
+	for i := range c.Size {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	positions := alignmentPositions(c.Version)
+	n := len(positions)
+	for i := range n {
+		for j := range n {
+			// Углы с поисковыми узорами пропускаются.
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			c.drawAlignment(positions[i], positions[j])
+		}
+	}
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFinder рисует поисковый узор 7×7 с разделителем вокруг; (x, y) — центр.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment рисует выравнивающий узор 5×5 с центром (x, y).
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits рисует обе копии информации о формате: уровень коррекции и маску.
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatInfo(c.Level, mask)
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	for i := range 8 {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true) // темный модуль есть всегда
+}
+
+// drawVersion рисует обе копии номера версии; у версий меньше 7 их нет.
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionInfo(c.Version)
+	for i := range 18 {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// formatInfo возвращает 15 бит информации о формате с кодом БЧХ и маской 0x5412.
+func formatInfo(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionInfo возвращает 18 бит информации о версии с кодом Голея.
+func versionInfo(version int) int {
+	rem := version
+	for range 12 {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+// drawCodewords раскладывает кодовые слова зигзагом парами столбцов снизу
+// вверх и обратно, обходя служебные модули.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // вертикальная линия синхронизации
+		}
+		for vert := range c.Size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y*c.Size+x] && i < len(data)*8 {
+					c.set(x, y, bit(int(data[i>>3]), 7-i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask инвертирует модули данных по шаблону маски mask.
+func (c *Code) applyMask(mask int) {
+	for y := range c.Size {
+		for x := range c.Size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.isFunction[y*c.Size+x] {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// Веса правил штрафа маски.
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// penalty оценивает, насколько символ трудно читать: длинные одноцветные
+// ряды, одноцветные квадраты 2×2, узоры, похожие на поисковые, и перекос
+// доли темных модулей.
+func (c *Code) penalty() int {
+	result := 0
+	for y := range c.Size {
+		result += linePenalty(func(i int) bool { return c.Black(i, y) }, c.Size)
+	}
+	for x := range c.Size {
+		result += linePenalty(func(i int) bool { return c.Black(x, i) }, c.Size)
+	}
+
+	dark := 0
+	for y := range c.Size {
+		for x := range c.Size {
+			color := c.Black(x, y)
+			if color {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size &&
+				color == c.Black(x+1, y) && color == c.Black(x, y+1) && color == c.Black(x+1, y+1) {
+				result += penaltyBlock
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*penaltyBalance
+}
+
+// finderLike — узор 1:1:3:1:1 с четырьмя светлыми модулями с одной стороны.
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// linePenalty считает штрафы одной строки или столбца длины n.
+func linePenalty(black func(i int) bool, n int) int {
+	result := 0
+	for i := 0; i < n; {
+		j := i
+		for j < n && black(j) == black(i) {
+			j++
+		}
+		if run := j - i; run >= 5 {
+			result += penaltyRun + run - 5
+		}
+		i = j
+	}
+	for i := 0; i+11 <= n; i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for k, want := range pattern {
+				if black(i+k) != want {
+					match = false
+					break
+				}
+			}
+			if match {
+				result += penaltyFinder
+			}
+		}
+	}
+	return result
+}
+
+// alignmentPositions возвращает координаты центров выравнивающих узоров по
+// каждой оси; у версии 1 их нет.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// charCountBits — длина поля длины данных в байтовом режиме.
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules — число модулей версии, доступных для кодовых слов
+// данных и коррекции, включая остаточные биты.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		result -= (25*n-10)*n - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// numDataCodewords — число кодовых слов данных версии при уровне level.
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numECCBlocks[level][version]
+}
+
+func bit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// bitBuffer накапливает биты от старших к младшим.
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, bit(value, i))
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	result := make([]byte, len(b.bits)/8)
+	for i, v := range b.bits {
+		if v {
+			result[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return result
+}
@@ -0,0 +1,267 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Пример «HELLO WORLD» 1-M из описания стандарта.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("Expected ECC %v, got %v", want, got)
+	}
+}
+
+func TestFormatAndVersionInfo(t *testing.T) {
+	for _, tt := range []struct {
+		level Level
+		mask  int
+		want  int
+	}{
+		{Low, 0, 0b111011111000100},
+		{Medium, 0, 0b101010000010010},
+		{Quartile, 0, 0b011010101011111},
+		{High, 0, 0b001011010001001},
+	} {
+		if got := formatInfo(tt.level, tt.mask); got != tt.want {
+			t.Errorf("Expected format info of %s-%d %015b, got %015b", tt.level, tt.mask, tt.want, got)
+		}
+	}
+	if got := versionInfo(7); got != 0b000111110010010100 {
+		t.Errorf("Expected version 7 info 000111110010010100, got %018b", got)
+	}
+}
+
+func TestTables(t *testing.T) {
+	for version, want := range map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		32: {6, 34, 60, 86, 112, 138},
+		36: {6, 24, 50, 76, 102, 128, 154},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	} {
+		if got := alignmentPositions(version); !slices.Equal(got, want) {
+			t.Errorf("Expected alignment positions of version %d %v, got %v", version, want, got)
+		}
+	}
+	for _, tt := range []struct {
+		version int
+		want    [4]int
+	}{
+		{1, [4]int{19, 16, 13, 9}},
+		{10, [4]int{274, 216, 154, 122}},
+		{40, [4]int{2956, 2334, 1666, 1276}},
+	} {
+		for level := Low; level <= High; level++ {
+			if got := numDataCodewords(tt.version, level); got != tt.want[level] {
+				t.Errorf("Expected %d data codewords for %d-%s, got %d", tt.want[level], tt.version, level, got)
+			}
+		}
+	}
+}
+
+func TestEncodeChoosesVersion(t *testing.T) {
+	for _, tt := range []struct {
+		length  int
+		level   Level
+		version int
+	}{
+		{17, Low, 1},
+		{18, Low, 2},
+		{7, High, 1},
+		{2953, Low, 40},
+	} {
+		c, err := Encode(bytes.Repeat([]byte("a"), tt.length), tt.level)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes) failed: %v", tt.length, err)
+		}
+		if c.Version != tt.version || c.Size != tt.version*4+17 {
+			t.Errorf("Expected version %d for %d bytes at %s, got %d", tt.version, tt.length, tt.level, c.Version)
+		}
+	}
+	if _, err := Encode(bytes.Repeat([]byte("a"), 2954), Low); err != ErrTooLong {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+}
+
+// TestEncodeRoundTrip читает закодированный символ обратно: информацию о
+// формате, кодовые слова и блоки коррекции.
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		data  string
+		level Level
+	}{
+		{"http://localhost:8080/EwHXdJfB", Medium},
+		{"https://sho.rt/abc12345", High},
+		{strings.Repeat("https://example.com/", 12), Quartile}, // версия 7+, блоки разной длины
+		{strings.Repeat("x", 1000), Low},
+	} {
+		c, err := Encode([]byte(tt.data), tt.level)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if got := decode(t, c); got != tt.data {
+			t.Errorf("Expected %q to round-trip at %s, got %q", tt.data, tt.level, got)
+		}
+	}
+}
+
+// decode — упрощенный декодер для проверки: он знает версию символа и не
+// исправляет ошибки, а только проверяет коды коррекции.
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+	var format int
+	for i := 0; i <= 5; i++ {
+		format |= b2i(c.Black(8, i)) << i
+	}
+	format |= b2i(c.Black(8, 7))<<6 | b2i(c.Black(8, 8))<<7 | b2i(c.Black(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		format |= b2i(c.Black(14-i, 8)) << i
+	}
+	var second int
+	for i := range 8 {
+		second |= b2i(c.Black(c.Size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= b2i(c.Black(8, c.Size-15+i)) << i
+	}
+	if format != second || format != formatInfo(c.Level, c.Mask) {
+		t.Fatalf("Unexpected format info %015b / %015b", format, second)
+	}
+
+	// Служебные модули берем у чистого символа той же версии, затем снимаем маску.
+	ref := &Code{Version: c.Version, Level: c.Level, Size: c.Size,
+		modules: make([]bool, c.Size*c.Size), isFunction: make([]bool, c.Size*c.Size)}
+	ref.drawFunctionPatterns()
+	for i := 0; c.Version >= 7 && i < 18; i++ {
+		want := bit(versionInfo(c.Version), i)
+		if c.Black(c.Size-11+i%3, i/3) != want || c.Black(i/3, c.Size-11+i%3) != want {
+			t.Fatalf("Unexpected version info bit %d", i)
+		}
+	}
+	copy(ref.modules, c.modules)
+	ref.applyMask(c.Mask)
+
+	raw := numRawDataModules(c.Version) / 8
+	codewords := make([]byte, raw)
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range c.Size {
+			for j := range 2 {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !ref.isFunction[y*c.Size+x] && i < raw*8 {
+					if ref.modules[y*c.Size+x] {
+						codewords[i>>3] |= 1 << (7 - i&7)
+					}
+					i++
+				}
+			}
+		}
+	}
+
+	// Обратное перемежение и проверка кодов коррекции каждого блока.
+	numBlocks := numECCBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range shortLen + 1 {
+		for j := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	divisor := rsDivisor(eccLen)
+	for _, block := range blocks {
+		n := len(block) - eccLen
+		if !bytes.Equal(rsRemainder(block[:n], divisor), block[n:]) {
+			t.Fatalf("Block ECC mismatch")
+		}
+		data = append(data, block[:n]...)
+	}
+
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("Expected byte mode, got %04b", data[0]>>4)
+	}
+	bits := func(start, n int) int {
+		v := 0
+		for i := start; i < start+n; i++ {
+			v = v<<1 | int(data[i>>3]>>(7-i&7)&1)
+		}
+		return v
+	}
+	countBits := charCountBits(c.Version)
+	length := bits(4, countBits)
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(bits(4+countBits+8*i, 8))
+	}
+	return string(out)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode([]byte("http://sho.rt/abcdefgh"), Medium)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.PNG(&buf, 300, 4); err != nil {
+		t.Fatalf("PNG failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+		t.Fatalf("Expected 300x300 image, got %v", b)
+	}
+	// Символ 25×25 с полем 4 — 33 модуля по 9 пикселей, остаток 3 пикселя делится по краям.
+	module, offset := 300/33, (300-25*(300/33))/2
+	for _, p := range [][2]int{{0, 0}, {3, 3}, {1, 1}, {10, 6}, {24, 24}} {
+		r, _, _, _ := img.At(offset+p[0]*module+module/2, offset+p[1]*module+module/2).RGBA()
+		if black := r == 0; black != c.Black(p[0], p[1]) {
+			t.Errorf("Expected module %v to be black=%v", p, c.Black(p[0], p[1]))
+		}
+	}
+	if r, _, _, _ := img.At(offset-1, offset-1).RGBA(); r == 0 {
+		t.Errorf("Expected quiet zone to be white")
+	}
+
+	buf.Reset()
+	if err := c.SVG(&buf, 300, 2); err != nil {
+		t.Fatalf("SVG failed: %v", err)
+	}
+	svg := buf.String()
+	if !strings.Contains(svg, `width="300" height="300" viewBox="0 0 29 29"`) || !strings.Contains(svg, `d="M2 2h7v1h-7z`) {
+		t.Errorf("Unexpected SVG: %.200s", svg)
+	}
+
+	// Слишком маленький размер не уменьшает модуль меньше пикселя.
+	if b := c.Image(10, 4).Bounds(); b.Dx() != 33 {
+		t.Errorf("Expected 33 pixel image, got %v", b)
+	}
+}
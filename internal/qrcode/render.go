@@ -0,0 +1,75 @@
+package qrcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// scale возвращает размер модуля в пикселях и отступ символа от края
+// картинки, чтобы символ с полем margin модулей занял size пикселей. Модули
+// остаются целыми: остаток делится поровну между сторонами поля. Если size
+// меньше числа модулей, картинка получается больше size.
+func (c *Code) scale(size, margin int) (module, offset, total int) {
+	modules := c.Size + 2*margin
+	module = max(size/modules, 1)
+	total = max(size, modules*module)
+	offset = (total - c.Size*module) / 2
+	return module, offset, total
+}
+
+// Image рисует код черным по белому картинкой size×size пикселей с полем
+// margin модулей.
+func (c *Code) Image(size, margin int) image.Image {
+	module, offset, total := c.scale(size, margin)
+	img := image.NewPaletted(image.Rect(0, 0, total, total), color.Palette{color.White, color.Black})
+	for y := range c.Size {
+		for x := range c.Size {
+			if !c.Black(x, y) {
+				continue
+			}
+			for py := range module {
+				row := img.Pix[(offset+y*module+py)*img.Stride:]
+				for px := range module {
+					row[offset+x*module+px] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNG пишет код в w в формате PNG (см. Image).
+func (c *Code) PNG(w io.Writer, size, margin int) error {
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(w, c.Image(size, margin))
+}
+
+// SVG пишет код в w векторной картинкой size×size с полем margin модулей.
+// Темные модули строки объединяются в один прямоугольник пути.
+func (c *Code) SVG(w io.Writer, size, margin int) error {
+	_, _, total := c.scale(size, margin)
+	view := c.Size + 2*margin
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n"+
+		`<rect width="100%%" height="100%%" fill="#fff"/>`+"\n"+`<path fill="#000" d="`, total, total, view, view)
+	for y := range c.Size {
+		for x := 0; x < c.Size; {
+			if !c.Black(x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < c.Size && c.Black(x, y) {
+				x++
+			}
+			_, _ = fmt.Fprintf(bw, "M%d %dh%dv1h-%dz", start+margin, y+margin, x-start, x-start)
+		}
+	}
+	_, _ = bw.WriteString("\"/>\n</svg>\n")
+	return bw.Flush()
+}
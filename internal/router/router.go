@@ -4,17 +4,28 @@ import (
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"net/http"
+	"shorturl/internal/apierror"
 	"shorturl/internal/config"
 	"shorturl/internal/handlers"
+	"shorturl/internal/health"
 	"shorturl/internal/logger"
+	"shorturl/internal/metrics"
 	"shorturl/internal/middleware"
+	"shorturl/internal/openapi"
+	"shorturl/internal/tracing"
 	"time"
 )
 
-func New(h *handlers.Handlers, cfg *config.Config) http.Handler {
+func New(h *handlers.Handlers, cfg *config.Config, hc *health.Checker) http.Handler {
+	spec := openapi.MustLoad()
+
 	r := chi.NewRouter()
+	r.NotFound(apierror.NotFound)
+	r.MethodNotAllowed(apierror.MethodNotAllowed)
 
+	r.Use(tracing.Middleware)
 	r.Use(logger.Middleware(logger.Logger))
+	r.Use(metrics.Middleware)
 	r.Use(chiMiddleware.RequestID)
 	r.Use(chiMiddleware.RealIP)
 	r.Use(chiMiddleware.Recoverer)
@@ -24,13 +35,29 @@ func New(h *handlers.Handlers, cfg *config.Config) http.Handler {
 
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.GzipRequest)
+		r.Use(middleware.BodyLimit(cfg.MaxBodyBytes))
+		r.Use(spec.Validate)
 		r.Post("/", h.HandlePost(cfg))
-		r.Post("/api/shorten", h.HandleAPIShorten(cfg))
-		r.Post("/api/shorten/batch", h.HandleAPIShortenBatch(cfg))
+		r.With(middleware.RequireContentType("application/json")).Post("/api/shorten", h.HandleAPIShorten(cfg))
+		r.With(middleware.RequireContentType("application/json")).Post("/api/shorten/batch", h.HandleAPIShortenBatch(cfg))
+		r.With(middleware.RequireContentType("application/json")).Patch("/api/user/urls/{id}", h.HandleUpdateUserURL(cfg))
+		r.With(middleware.RequireContentType("application/json")).Put("/api/user/urls/{id}", h.HandleUpdateOriginalURL(cfg))
+		r.With(middleware.RequireContentType("application/x-www-form-urlencoded")).Post("/{shortID}", h.HandleUnlock(cfg))
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(spec.Validate)
+		r.Get("/api/user/urls", h.HandleGetUserURLs(cfg))
+		r.Get("/api/user/urls/export", h.HandleExportUserURLs(cfg))
+		r.Get("/api/user/urls/search", h.HandleSearchUserURLs(cfg))
+		r.Get("/api/user/urls/{id}/history", h.HandleGetURLHistory())
+		r.Get("/{shortID}", h.HandleGet(cfg))
+		r.Get("/{shortID}/qr", h.HandleQRCode(cfg))
+		r.Get("/ping", h.HandlePing())
 	})
-	r.Get("/api/user/urls", h.HandleGetUserURLs(cfg))
-	r.Get("/{shortID}", h.HandleGet())
-	r.Get("/ping", h.HandlePing())
+	r.Get("/api/openapi.json", spec.Handler())
+	r.Get("/metrics", metrics.Handler())
+	r.Get("/healthz", hc.LivenessHandler())
+	r.Get("/readyz", hc.ReadinessHandler())
 
 	return r
 }
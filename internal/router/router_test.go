@@ -0,0 +1,63 @@
+package router_test
+
+import (
+	"net/http"
+	"shorturl/internal/config"
+	"shorturl/internal/handlers"
+	"shorturl/internal/health"
+	"shorturl/internal/openapi"
+	"shorturl/internal/router"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestRoutesMatchOpenAPI падает, если маршруты роутера и операции спецификации расходятся.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI spec: %v", err)
+	}
+
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	r := router.New(handlers.NewHandlers(nil), cfg, health.NewChecker())
+
+	routes, ok := r.(chi.Routes)
+	if !ok {
+		t.Fatalf("Router does not implement chi.Routes")
+	}
+
+	var routed []string
+	err = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed = append(routed, method+" "+strings.TrimSuffix(route, "/*"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk routes: %v", err)
+	}
+	sort.Strings(routed)
+
+	documented := spec.Operations()
+
+	for _, op := range routed {
+		if !contains(documented, op) {
+			t.Errorf("Route %q is not described in the OpenAPI spec", op)
+		}
+	}
+	for _, op := range documented {
+		if !contains(routed, op) {
+			t.Errorf("OpenAPI operation %q has no matching route", op)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"shorturl/internal/apierror"
+	"shorturl/internal/storage"
+	"shorturl/internal/tracing"
+)
+
+// ErrClicksExhausted возвращается, если переходы по ссылке с ограничением
+// числа переходов закончились.
+var ErrClicksExhausted = errors.New("short URL clicks exhausted")
+
+// validateMaxClicks проверяет ограничение числа переходов новой ссылки;
+// 0 — без ограничения.
+func validateMaxClicks(maxClicks int) error {
+	if maxClicks < 0 {
+		return &ValidationError{Field: "max_clicks", Code: apierror.CodeInvalidValue, Reason: "must not be negative"}
+	}
+	return nil
+}
+
+// ConsumeClick списывает переход по ссылке shortID. Для ссылки без ограничения
+// ничего не делает; если переходы закончились, возвращает ErrClicksExhausted.
+func (s *URLService) ConsumeClick(ctx context.Context, shortID string) (err error) {
+	ctx, span := tracing.Start(ctx, "URLService.ConsumeClick", tracing.WithAttributes(tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	err = s.storage.ConsumeClick(ctx, shortID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, storage.ErrClicksExhausted):
+		return ErrClicksExhausted
+	}
+	return err
+}
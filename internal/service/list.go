@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"shorturl/internal/apierror"
+	"shorturl/internal/storage"
+	"strings"
+	"time"
+)
+
+// Размер страницы списка ссылок пользователя.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// Порядок сортировки списка ссылок: по времени создания, сначала новые или старые.
+const (
+	SortNewest = "-created_at"
+	SortOldest = "created_at"
+)
+
+// ListRequest — параметры запроса страницы ссылок пользователя.
+type ListRequest struct {
+	Limit  int    // 0 — DefaultPageSize
+	Cursor string // NextCursor предыдущей страницы
+	Sort   string // SortNewest (по умолчанию) или SortOldest
+	Domain string
+	Query  string
+	Tag    string
+	Folder string
+}
+
+// ListPage — страница ссылок. NextCursor пуст на последней странице.
+type ListPage struct {
+	URLs       []storage.URLPair
+	NextCursor string
+}
+
+// options проверяет запрос и переводит его в параметры хранилища.
+func (r ListRequest) options() (storage.ListOptions, error) {
+	opts := storage.ListOptions{Limit: r.Limit, Domain: strings.TrimSpace(r.Domain), Query: r.Query,
+		Tag: strings.TrimSpace(r.Tag), Folder: strings.TrimSpace(r.Folder)}
+	switch {
+	case opts.Limit == 0:
+		opts.Limit = DefaultPageSize
+	case opts.Limit < 0 || opts.Limit > MaxPageSize:
+		return opts, &ValidationError{Field: "limit", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be between 1 and %d", MaxPageSize)}
+	}
+	switch r.Sort {
+	case "", SortNewest:
+		opts.Desc = true
+	case SortOldest:
+	default:
+		return opts, &ValidationError{Field: "sort", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be %s or %s", SortNewest, SortOldest)}
+	}
+	if r.Cursor != "" {
+		cursor, err := DecodeCursor(r.Cursor)
+		if err != nil {
+			return opts, err
+		}
+		opts.After = &cursor
+	}
+	return opts, nil
+}
+
+// EncodeCursor кодирует позицию в списке в непрозрачную для клиента строку.
+func EncodeCursor(c storage.Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ShortURL))
+}
+
+// DecodeCursor разбирает строку, полученную от EncodeCursor.
+func DecodeCursor(s string) (storage.Cursor, error) {
+	invalid := &ValidationError{Field: "cursor", Code: apierror.CodeInvalidValue, Reason: "is malformed"}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return storage.Cursor{}, invalid
+	}
+	createdAt, shortURL, ok := strings.Cut(string(data), "|")
+	if !ok || shortURL == "" {
+		return storage.Cursor{}, invalid
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return storage.Cursor{}, invalid
+	}
+	return storage.Cursor{CreatedAt: t, ShortURL: shortURL}, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"shorturl/internal/apierror"
+	"shorturl/internal/storage"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ограничения метаданных ссылки (длины — в символах).
+const (
+	MaxTitleLength  = 300
+	MaxNotesLength  = 4000
+	MaxFolderLength = 200
+	MaxTags         = 20
+	MaxTagLength    = 50
+)
+
+// CreateRequest — параметры создания ссылки. Password — необязательный
+// пароль, без которого ссылка не откроется; хранится только его хэш.
+type CreateRequest struct {
+	OriginalURL string
+	Metadata    storage.Metadata
+	Password    string
+	MaxClicks   int // 0 — без ограничения числа переходов
+}
+
+// normalizeMetadata проверяет метаданные новой ссылки и приводит их к виду,
+// в котором они хранятся: заголовок и папка без пробелов по краям, теги —
+// storage.NormalizeTags.
+func normalizeMetadata(m storage.Metadata) (storage.Metadata, error) {
+	patch, err := normalizePatch(storage.MetadataPatch{Title: &m.Title, Tags: &m.Tags, Notes: &m.Notes, Folder: &m.Folder,
+		FallbackURL: &m.FallbackURL, NotBefore: &m.NotBefore, NotAfter: &m.NotAfter})
+	if err != nil {
+		return storage.Metadata{}, err
+	}
+	return patch.Apply(storage.Metadata{}), nil
+}
+
+// normalizePatch проверяет и нормализует заданные поля изменения метаданных.
+func normalizePatch(p storage.MetadataPatch) (storage.MetadataPatch, error) {
+	if p.Title != nil {
+		title := strings.TrimSpace(*p.Title)
+		if err := validateText("title", title, MaxTitleLength); err != nil {
+			return p, err
+		}
+		p.Title = &title
+	}
+	if p.Notes != nil {
+		if err := validateText("notes", *p.Notes, MaxNotesLength); err != nil {
+			return p, err
+		}
+	}
+	if p.Folder != nil {
+		folder := strings.TrimSpace(*p.Folder)
+		if err := validateText("folder", folder, MaxFolderLength); err != nil {
+			return p, err
+		}
+		p.Folder = &folder
+	}
+	if p.Tags != nil {
+		tags := storage.NormalizeTags(*p.Tags)
+		if err := validateTags(tags); err != nil {
+			return p, err
+		}
+		p.Tags = &tags
+	}
+	if p.FallbackURL != nil {
+		// Пустая строка убирает запасной адрес.
+		fallback := strings.TrimSpace(*p.FallbackURL)
+		if fallback != "" {
+			if err := ValidateURL("fallback_url", fallback); err != nil {
+				return p, err
+			}
+		}
+		p.FallbackURL = &fallback
+	}
+	return normalizeWindow(p)
+}
+
+func validateText(field, value string, maxLength int) error {
+	if !utf8.ValidString(value) {
+		return &ValidationError{Field: field, Code: apierror.CodeInvalidValue, Reason: "must be valid UTF-8"}
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		return &ValidationError{Field: field, Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be at most %d characters long", maxLength)}
+	}
+	return nil
+}
+
+func validateTags(tags []string) error {
+	if len(tags) > MaxTags {
+		return &ValidationError{Field: "tags", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must contain at most %d tags", MaxTags)}
+	}
+	for i, tag := range tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		if err := validateText(field, tag, MaxTagLength); err != nil {
+			return err
+		}
+		if strings.Contains(tag, ",") {
+			return &ValidationError{Field: field, Code: apierror.CodeInvalidValue, Reason: "must not contain commas"}
+		}
+	}
+	return nil
+}
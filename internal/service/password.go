@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shorturl/internal/apierror"
+	"shorturl/internal/storage"
+	"shorturl/internal/tracing"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Ограничения пароля ссылки. bcrypt учитывает только первые 72 байта, поэтому
+// более длинные пароли отклоняются, а не обрезаются молча.
+const (
+	MinPasswordLength = 4
+	MaxPasswordBytes  = 72
+)
+
+// ErrWrongPassword возвращается, если пароль не подходит к защищенной ссылке.
+var ErrWrongPassword = errors.New("wrong link password")
+
+// passwordCost — стоимость bcrypt для новых паролей.
+var passwordCost = bcrypt.DefaultCost
+
+// hashPassword проверяет пароль новой ссылки и возвращает его bcrypt-хэш.
+func hashPassword(password string) (string, error) {
+	switch {
+	case !utf8.ValidString(password):
+		return "", &ValidationError{Field: "password", Code: apierror.CodeInvalidValue, Reason: "must be valid UTF-8"}
+	case utf8.RuneCountInString(password) < MinPasswordLength:
+		return "", &ValidationError{Field: "password", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be at least %d characters long", MinPasswordLength)}
+	case len(password) > MaxPasswordBytes:
+		return "", &ValidationError{Field: "password", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be at most %d bytes long", MaxPasswordBytes)}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// UnlockURL проверяет пароль ссылки shortID и возвращает сведения для
+// перехода по ней. Неподходящий пароль — ErrWrongPassword; ссылка без пароля
+// открывается с любым.
+func (s *URLService) UnlockURL(ctx context.Context, shortID, password string) (_ storage.Redirect, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.UnlockURL", tracing.WithAttributes(tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	redirect, err := s.ResolveURL(ctx, shortID)
+	if err != nil || !redirect.Protected() {
+		return redirect, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(redirect.PasswordHash), []byte(password)) != nil {
+		return storage.Redirect{}, ErrWrongPassword
+	}
+	return redirect, nil
+}
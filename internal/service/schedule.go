@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"shorturl/internal/apierror"
+	"shorturl/internal/storage"
+	"time"
+)
+
+// ErrExpired возвращается, если окно действия ссылки уже закрылось.
+var ErrExpired = errors.New("short URL has expired")
+
+// ErrNotYetActive возвращается, если окно действия ссылки еще не началось.
+// Включает время, с которого ссылка откроется.
+type ErrNotYetActive struct {
+	ActiveFrom time.Time
+}
+
+func (e *ErrNotYetActive) Error() string {
+	return fmt.Sprintf("short URL is not active until %s", e.ActiveFrom.Format(time.RFC3339))
+}
+
+// checkWindow проверяет, что в момент now ссылка redirect действует.
+func checkWindow(redirect storage.Redirect, now time.Time) error {
+	switch {
+	case redirect.Expired(now):
+		return ErrExpired
+	case redirect.Pending(now):
+		return &ErrNotYetActive{ActiveFrom: redirect.NotBefore}
+	}
+	return nil
+}
+
+// normalizeWindow приводит заданные границы окна действия к UTC с точностью
+// до микросекунд, как их хранит PostgreSQL, и проверяет, что окно не пустое.
+// Нулевое время снимает ограничение и не меняется.
+func normalizeWindow(p storage.MetadataPatch) (storage.MetadataPatch, error) {
+	for _, bound := range []**time.Time{&p.NotBefore, &p.NotAfter} {
+		if *bound != nil && !(**bound).IsZero() {
+			t := (**bound).UTC().Truncate(time.Microsecond)
+			*bound = &t
+		}
+	}
+	if p.NotBefore != nil && p.NotAfter != nil && !p.NotBefore.IsZero() && !p.NotAfter.IsZero() &&
+		!p.NotAfter.After(*p.NotBefore) {
+		return p, emptyWindowError()
+	}
+	return p, nil
+}
+
+// emptyWindowError — ошибка проверки для окна, в котором ссылка ни разу не
+// действует. Окно из одной границы проверяет хранилище вместе с сохраненной
+// второй (см. storage.ErrEmptyWindow).
+func emptyWindowError() error {
+	return &ValidationError{Field: "not_after", Code: apierror.CodeInvalidValue, Reason: "must be after not_before"}
+}
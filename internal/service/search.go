@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"shorturl/internal/apierror"
+	"shorturl/internal/storage"
+	"strconv"
+	"strings"
+)
+
+// Ограничения поискового запроса. MaxSearchOffset не дает листать выдачу
+// бесконечно: ранжированный поиск пересчитывает все пропущенные результаты.
+const (
+	MaxSearchQueryLength = 256
+	MaxSearchOffset      = 10000
+)
+
+// SearchRequest — параметры запроса страницы результатов поиска.
+type SearchRequest struct {
+	Query  string
+	Limit  int    // 0 — DefaultPageSize
+	Cursor string // NextCursor предыдущей страницы
+}
+
+// SearchPage — страница результатов поиска. NextCursor пуст на последней странице.
+type SearchPage struct {
+	Hits       []storage.SearchHit
+	NextCursor string
+}
+
+// options проверяет запрос и переводит его в параметры хранилища.
+func (r SearchRequest) options() (storage.SearchOptions, error) {
+	opts := storage.SearchOptions{Query: strings.TrimSpace(r.Query), Limit: r.Limit}
+	switch {
+	case opts.Query == "":
+		return opts, &ValidationError{Field: "q", Code: apierror.CodeRequired, Reason: "is required"}
+	case len(opts.Query) > MaxSearchQueryLength:
+		return opts, &ValidationError{Field: "q", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be at most %d bytes", MaxSearchQueryLength)}
+	case len(storage.SearchTerms(opts.Query)) == 0:
+		return opts, &ValidationError{Field: "q", Code: apierror.CodeInvalidValue, Reason: "must contain letters or digits"}
+	}
+	switch {
+	case opts.Limit == 0:
+		opts.Limit = DefaultPageSize
+	case opts.Limit < 0 || opts.Limit > MaxPageSize:
+		return opts, &ValidationError{Field: "limit", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("must be between 1 and %d", MaxPageSize)}
+	}
+	if r.Cursor != "" {
+		offset, err := decodeSearchCursor(r.Cursor)
+		if err != nil {
+			return opts, err
+		}
+		opts.Offset = offset
+	}
+	return opts, nil
+}
+
+// encodeSearchCursor кодирует смещение в выдаче. Курсор поиска отличается от
+// курсора списка префиксом, чтобы их нельзя было перепутать.
+func encodeSearchCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("search|" + strconv.Itoa(offset)))
+}
+
+func decodeSearchCursor(s string) (int, error) {
+	invalid := &ValidationError{Field: "cursor", Code: apierror.CodeInvalidValue, Reason: "is malformed"}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, invalid
+	}
+	offset, ok := strings.CutPrefix(string(data), "search|")
+	if !ok {
+		return 0, invalid
+	}
+	n, err := strconv.Atoi(offset)
+	if err != nil || n < 0 {
+		return 0, invalid
+	}
+	if n > MaxSearchOffset {
+		return 0, &ValidationError{Field: "cursor", Code: apierror.CodeInvalidValue,
+			Reason: fmt.Sprintf("search results are limited to the first %d matches", MaxSearchOffset)}
+	}
+	return n, nil
+}
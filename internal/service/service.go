@@ -5,8 +5,34 @@ import (
 	"errors"
 	"fmt"
 	"shorturl/internal/storage"
+	"shorturl/internal/tracing"
+	"strings"
+	"time"
 )
 
+// ErrNotFound возвращается, если короткий URL не найден.
+var ErrNotFound = errors.New("short URL not found")
+
+// ValidationError описывает некорректное значение входного поля.
+type ValidationError struct {
+	Field  string
+	Reason string
+	Code   string // код ошибки поля; пустой — некорректный URL
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// ValidateURL проверяет, что rawURL является абсолютным HTTP(S) URL.
+// field используется в тексте ошибки для указания поля запроса.
+func ValidateURL(field, rawURL string) error {
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		return &ValidationError{Field: field, Reason: "must start with http:// or https://"}
+	}
+	return nil
+}
+
 // ErrConflict is a service-level error for URL conflicts.
 type ErrConflict struct {
 	ExistingShortID string
@@ -24,10 +50,62 @@ func (e *ErrConflict) Error() string {
 // ShortURLCreatorGetter определяет интерфейс для создания и получения коротких URL.
 type ShortURLCreatorGetter interface {
 	CreateShortURL(ctx context.Context, userID, originalURL string) (string, error)
+	// CreateURL создает ссылку по образцу pair: короткий ID, UUID и время
+	// создания назначает хранилище.
+	CreateURL(ctx context.Context, pair storage.URLPair) (string, error)
+	// UpdateURLMetadata изменяет метаданные ссылки владельца; чужая или
+	// отсутствующая ссылка — storage.ErrNotFound.
+	UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (storage.URLPair, error)
+	// UpdateOriginalURL меняет адрес назначения ссылки владельца и дописывает
+	// смену в историю; адрес, уже сокращенный другой ссылкой, — storage.ErrConflict.
+	UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (storage.URLPair, error)
+	// GetURLHistory возвращает историю смены адресов ссылки владельца от старых к новым.
+	GetURLHistory(ctx context.Context, userID, shortID string) ([]storage.HistoryEntry, error)
+	// SetURLPreview сохраняет сведения о странице ссылки, если она все еще ведет
+	// на originalURL; иначе — storage.ErrNotFound.
+	SetURLPreview(ctx context.Context, shortID, originalURL string, preview storage.Preview) error
+	// SetURLHealth сохраняет результат проверки ссылки, если она все еще ведет
+	// на originalURL; иначе — storage.ErrNotFound.
+	SetURLHealth(ctx context.Context, shortID, originalURL string, health storage.LinkHealth) error
+	// GetOriginalURL возвращает адрес перехода по ссылке (storage.URLPair.RedirectURL).
 	GetOriginalURL(ctx context.Context, shortID string) (string, error)
+	// GetRedirect возвращает сведения для перехода по ссылке; отсутствующая
+	// ссылка — storage.ErrNotFound.
+	GetRedirect(ctx context.Context, shortID string) (storage.Redirect, error)
+	// ConsumeClick атомарно списывает переход по ссылке с ограничением числа
+	// переходов; storage.ErrClicksExhausted, если они закончились.
+	ConsumeClick(ctx context.Context, shortID string) error
 	GetURLsByUserID(ctx context.Context, userID string) ([]storage.URLPair, error)
+	// ForEachUserURL потоково перечисляет ссылки пользователя, не загружая их все в память.
+	ForEachUserURL(ctx context.Context, userID string, fn func(storage.URLPair) error) error
+	// ListUserURLs возвращает страницу ссылок пользователя по storage.ListOptions.
+	ListUserURLs(ctx context.Context, userID string, opts storage.ListOptions) ([]storage.URLPair, error)
+	// SearchUserURLs ищет ссылки пользователя и возвращает их по убыванию релевантности.
+	SearchUserURLs(ctx context.Context, userID string, opts storage.SearchOptions) ([]storage.SearchHit, error)
 }
 
+// Проверяем на этапе компиляции, что встроенные бэкенды реализуют интерфейс хранилища.
+var (
+	_ ShortURLCreatorGetter = (*storage.DatabaseStorage)(nil)
+	_ ShortURLCreatorGetter = (*storage.FileStorage)(nil)
+	_ ShortURLCreatorGetter = (*storage.InMemoryStorage)(nil)
+	_ ShortURLCreatorGetter = (*storage.BoltStorage)(nil)
+	_ ShortURLCreatorGetter = (*storage.SQLiteStorage)(nil)
+	_ URLStorage            = (*storage.InMemoryStorage)(nil)
+)
+
+// Проверяем, что все бэкенды поддерживают экспорт и импорт.
+var (
+	_ storage.Exporter = (*storage.DatabaseStorage)(nil)
+	_ storage.Exporter = (*storage.FileStorage)(nil)
+	_ storage.Exporter = (*storage.InMemoryStorage)(nil)
+	_ storage.Exporter = (*storage.BoltStorage)(nil)
+	_ storage.Importer = (*storage.DatabaseStorage)(nil)
+	_ storage.Importer = (*storage.FileStorage)(nil)
+	_ storage.Importer = (*storage.InMemoryStorage)(nil)
+	_ storage.Importer = (*storage.BoltStorage)(nil)
+)
+
 // PersistentStorage определяет интерфейс для хранилищ с возможностью сохранения/загрузки в файл.
 type PersistentStorage interface {
 	LoadFromFile(filePath string) error
@@ -43,8 +121,19 @@ type URLStorage interface {
 // URLShortener определяет интерфейс сервиса для сокращения URL.
 type URLShortener interface {
 	CreateShortURL(ctx context.Context, userID, originalURL string) (string, error)
+	CreateURL(ctx context.Context, userID string, req CreateRequest) (string, error)
+	UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (storage.URLPair, error)
+	UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (storage.URLPair, error)
+	GetURLHistory(ctx context.Context, userID, shortID string) ([]storage.HistoryEntry, error)
 	GetOriginalURL(ctx context.Context, shortID string) (string, error)
+	ResolveURL(ctx context.Context, shortID string) (storage.Redirect, error)
+	UnlockURL(ctx context.Context, shortID, password string) (storage.Redirect, error)
+	ConsumeClick(ctx context.Context, shortID string) error
+	Now() time.Time
 	GetURLsByUserID(ctx context.Context, userID string) ([]storage.URLPair, error)
+	ForEachUserURL(ctx context.Context, userID string, fn func(storage.URLPair) error) error
+	ListUserURLs(ctx context.Context, userID string, req ListRequest) (ListPage, error)
+	SearchUserURLs(ctx context.Context, userID string, req SearchRequest) (SearchPage, error)
 	Ping(ctx context.Context) error
 }
 
@@ -56,15 +145,54 @@ type Pinger interface {
 type URLService struct {
 	storage ShortURLCreatorGetter
 	pinger  Pinger
+	now     func() time.Time
 }
 
 // NewURLService создает и возвращает новый экземпляр URLService.
 func NewURLService(storage ShortURLCreatorGetter, pinger Pinger) *URLService {
-	return &URLService{storage: storage, pinger: pinger}
+	return &URLService{storage: storage, pinger: pinger, now: time.Now}
+}
+
+// SetClock задает источник текущего времени, по которому проверяется окно
+// действия ссылок; по умолчанию time.Now.
+func (s *URLService) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Now возвращает текущее время по часам сервиса (см. SetClock).
+func (s *URLService) Now() time.Time {
+	return s.now()
 }
 
 func (s *URLService) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
-	shortID, err := s.storage.CreateShortURL(ctx, userID, originalURL)
+	return s.CreateURL(ctx, userID, CreateRequest{OriginalURL: originalURL})
+}
+
+// CreateURL создает ссылку с необязательными метаданными, паролем и
+// ограничением числа переходов. Если оригинальный URL уже сокращен, возвращает
+// существующий ID и ErrConflict; существующая ссылка при этом не меняется.
+func (s *URLService) CreateURL(ctx context.Context, userID string, req CreateRequest) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.CreateURL", tracing.WithAttributes(tracing.String("user.id", userID)))
+	defer func() { endSpan(span, err) }()
+
+	if err := ValidateURL("url", req.OriginalURL); err != nil {
+		return "", err
+	}
+	meta, err := normalizeMetadata(req.Metadata)
+	if err != nil {
+		return "", err
+	}
+	if err := validateMaxClicks(req.MaxClicks); err != nil {
+		return "", err
+	}
+	pair := storage.URLPair{UserID: userID, OriginalURL: req.OriginalURL, Metadata: meta,
+		MaxClicks: req.MaxClicks, ClicksLeft: req.MaxClicks}
+	if req.Password != "" {
+		if pair.PasswordHash, err = hashPassword(req.Password); err != nil {
+			return "", err
+		}
+	}
+	shortID, err := s.storage.CreateURL(ctx, pair)
 	if err != nil {
 		var storageConflict *storage.ErrConflict
 		if errors.As(err, &storageConflict) {
@@ -75,17 +203,172 @@ func (s *URLService) CreateShortURL(ctx context.Context, userID, originalURL str
 	return shortID, nil
 }
 
-func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (string, error) {
-	return s.storage.GetOriginalURL(ctx, shortID)
+// UpdateURLMetadata изменяет метаданные ссылки shortID, если она принадлежит userID.
+func (s *URLService) UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (_ storage.URLPair, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.UpdateURLMetadata", tracing.WithAttributes(
+		tracing.String("user.id", userID), tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	if patch, err = normalizePatch(patch); err != nil {
+		return storage.URLPair{}, err
+	}
+	pair, err := s.storage.UpdateURLMetadata(ctx, userID, shortID, patch)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return storage.URLPair{}, ErrNotFound
+	case errors.Is(err, storage.ErrEmptyWindow):
+		return storage.URLPair{}, emptyWindowError()
+	}
+	return pair, err
+}
+
+// UpdateOriginalURL меняет адрес назначения ссылки shortID, если она
+// принадлежит userID. Если новый адрес уже сокращен другой ссылкой, возвращает
+// ErrConflict с ее ID.
+func (s *URLService) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (_ storage.URLPair, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.UpdateOriginalURL", tracing.WithAttributes(
+		tracing.String("user.id", userID), tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	if err := ValidateURL("url", originalURL); err != nil {
+		return storage.URLPair{}, err
+	}
+	pair, err := s.storage.UpdateOriginalURL(ctx, userID, shortID, originalURL)
+	var storageConflict *storage.ErrConflict
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return storage.URLPair{}, ErrNotFound
+	case errors.As(err, &storageConflict):
+		return storage.URLPair{}, NewErrConflict(storageConflict.ExistingShortID)
+	}
+	return pair, err
 }
 
-func (s *URLService) GetURLsByUserID(ctx context.Context, userID string) ([]storage.URLPair, error) {
+// GetURLHistory возвращает историю смены адресов ссылки shortID, если она принадлежит userID.
+func (s *URLService) GetURLHistory(ctx context.Context, userID, shortID string) (_ []storage.HistoryEntry, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLHistory", tracing.WithAttributes(
+		tracing.String("user.id", userID), tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	entries, err := s.storage.GetURLHistory(ctx, userID, shortID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return entries, err
+}
+
+func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetOriginalURL", tracing.WithAttributes(tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	originalURL, err := s.storage.GetOriginalURL(ctx, shortID)
+	if err != nil {
+		return "", err
+	}
+	if originalURL == "" {
+		return "", ErrNotFound
+	}
+	return originalURL, nil
+}
+
+// ResolveURL возвращает сведения для перехода по ссылке shortID: адрес
+// перенаправления и хэш пароля, если ссылка защищена. Вне окна действия
+// ссылки возвращает ErrNotYetActive или ErrExpired.
+func (s *URLService) ResolveURL(ctx context.Context, shortID string) (_ storage.Redirect, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.ResolveURL", tracing.WithAttributes(tracing.String("short_url.id", shortID)))
+	defer func() { endSpan(span, err) }()
+
+	redirect, err := s.storage.GetRedirect(ctx, shortID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.Redirect{}, ErrNotFound
+	}
+	if err != nil {
+		return storage.Redirect{}, err
+	}
+	if err := checkWindow(redirect, s.now()); err != nil {
+		return storage.Redirect{}, err
+	}
+	return redirect, nil
+}
+
+func (s *URLService) GetURLsByUserID(ctx context.Context, userID string) (_ []storage.URLPair, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetURLsByUserID", tracing.WithAttributes(tracing.String("user.id", userID)))
+	defer func() { endSpan(span, err) }()
+
 	return s.storage.GetURLsByUserID(ctx, userID)
 }
 
-func (s *URLService) Ping(ctx context.Context) error {
+func (s *URLService) ForEachUserURL(ctx context.Context, userID string, fn func(storage.URLPair) error) (err error) {
+	ctx, span := tracing.Start(ctx, "URLService.ForEachUserURL", tracing.WithAttributes(tracing.String("user.id", userID)))
+	defer func() { endSpan(span, err) }()
+
+	return s.storage.ForEachUserURL(ctx, userID, fn)
+}
+
+func (s *URLService) ListUserURLs(ctx context.Context, userID string, req ListRequest) (_ ListPage, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.ListUserURLs", tracing.WithAttributes(tracing.String("user.id", userID)))
+	defer func() { endSpan(span, err) }()
+
+	opts, err := req.options()
+	if err != nil {
+		return ListPage{}, err
+	}
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница.
+	limit := opts.Limit
+	opts.Limit++
+	urls, err := s.storage.ListUserURLs(ctx, userID, opts)
+	if err != nil {
+		return ListPage{}, err
+	}
+	page := ListPage{URLs: urls}
+	if len(urls) > limit {
+		page.URLs = urls[:limit]
+		page.NextCursor = EncodeCursor(storage.CursorOf(urls[limit-1]))
+	}
+	return page, nil
+}
+
+func (s *URLService) SearchUserURLs(ctx context.Context, userID string, req SearchRequest) (_ SearchPage, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.SearchUserURLs", tracing.WithAttributes(tracing.String("user.id", userID)))
+	defer func() { endSpan(span, err) }()
+
+	opts, err := req.options()
+	if err != nil {
+		return SearchPage{}, err
+	}
+	limit := opts.Limit
+	opts.Limit++
+	hits, err := s.storage.SearchUserURLs(ctx, userID, opts)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	page := SearchPage{Hits: hits}
+	if len(hits) > limit {
+		page.Hits = hits[:limit]
+		page.NextCursor = encodeSearchCursor(opts.Offset + limit)
+	}
+	return page, nil
+}
+
+func (s *URLService) Ping(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "URLService.Ping")
+	defer func() { endSpan(span, err) }()
+
 	if s.pinger != nil {
 		return s.pinger.PingContext(ctx)
 	}
 	return nil
 }
+
+// endSpan завершает спан, помечая его ошибочным, если операция завершилась ошибкой.
+// Конфликт, отсутствие ссылки, неверный пароль, исчерпанные переходы и
+// переход вне окна действия — ожидаемые исходы и ошибками спана не считаются.
+func endSpan(span *tracing.Span, err error) {
+	var conflictErr *ErrConflict
+	var notActiveErr *ErrNotYetActive
+	if err != nil && !errors.As(err, &conflictErr) && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrWrongPassword) &&
+		!errors.Is(err, ErrClicksExhausted) && !errors.Is(err, ErrExpired) && !errors.As(err, &notActiveErr) {
+		span.RecordError(err)
+	}
+	span.End()
+}
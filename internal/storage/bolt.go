@@ -0,0 +1,590 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"shorturl/internal/config"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BackendBolt — встроенное key-value хранилище на диске (B+-дерево bbolt).
+const BackendBolt = "bolt"
+
+func init() {
+	Register(BackendBolt, FactoryFunc(func(cfg *config.Config) (Store, error) {
+		if cfg.FileStoragePath == "" {
+			return nil, errors.New("FILE_STORAGE_PATH is not set")
+		}
+		return NewBoltStorage(cfg.FileStoragePath)
+	}))
+}
+
+// Бакеты BoltStorage. urls — основные записи, history — история изменений,
+// остальные — индексы.
+var (
+	boltURLsBucket     = []byte("urls")        // short_url -> URLPair (JSON)
+	boltOriginalBucket = []byte("by_original") // original_url -> short_url
+	// short_url \x00 номер (8 байт) -> HistoryEntry (JSON); история смены
+	// адресов, номер — последовательность бакета.
+	boltHistoryBucket = []byte("history")
+	// user_id \x00 created_at (8 байт) short_url -> пусто; ссылки пользователя
+	// в порядке создания.
+	boltUserBucket = []byte("by_user_created")
+	// by_user — прежний индекс (user_id \x00 short_url), удаляется при открытии.
+	boltLegacyUserBucket = []byte("by_user")
+	boltKeySeparator     = []byte{0}
+	errBoltIDExhausted   = errors.New("failed to generate unique short ID")
+)
+
+// maxShortIDAttempts ограничивает число попыток сгенерировать свободный короткий ID.
+const maxShortIDAttempts = 10
+
+// BoltStorage хранит ссылки во встроенной базе bbolt. Каждая запись фиксируется
+// транзакцией с fsync, поэтому данные переживают падение процесса, а индексы
+// по оригинальному URL и пользователю обновляются атомарно вместе с записью.
+type BoltStorage struct {
+	db   *bolt.DB
+	path string
+}
+
+// NewBoltStorage открывает (или создает) базу bbolt по пути path.
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltURLsBucket, boltOriginalBucket, boltHistoryBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+		if tx.Bucket(boltUserBucket) == nil {
+			if err := boltBuildUserIndex(tx); err != nil {
+				return fmt.Errorf("failed to build bucket %s: %w", boltUserBucket, err)
+			}
+		}
+		if tx.Bucket(boltLegacyUserBucket) != nil {
+			return tx.DeleteBucket(boltLegacyUserBucket)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+	return &BoltStorage{db: db, path: path}, nil
+}
+
+func (s *BoltStorage) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
+	return s.CreateURL(ctx, URLPair{UserID: userID, OriginalURL: originalURL})
+}
+
+// CreateURL сохраняет ссылку по образцу pair, назначая ей UUID, короткий ID и
+// время создания. Если такой оригинальный URL уже есть, возвращает его ID и ErrConflict.
+func (s *BoltStorage) CreateURL(_ context.Context, pair URLPair) (string, error) {
+	var shortID string
+	var conflict bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		byOriginal := tx.Bucket(boltOriginalBucket)
+		if existing := byOriginal.Get([]byte(pair.OriginalURL)); existing != nil {
+			shortID = string(existing)
+			conflict = true
+			return nil
+		}
+
+		urls := tx.Bucket(boltURLsBucket)
+		for attempt := 0; ; attempt++ {
+			if attempt == maxShortIDAttempts {
+				return errBoltIDExhausted
+			}
+			shortID = generateShortID()
+			if urls.Get([]byte(shortID)) == nil {
+				break
+			}
+		}
+
+		pair.UUID, pair.ShortURL, pair.CreatedAt = uuid.NewString(), shortID, timeNow()
+		return boltPut(tx, pair)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store short URL: %w", err)
+	}
+	if conflict {
+		return shortID, NewErrConflict(shortID)
+	}
+	return shortID, nil
+}
+
+func (s *BoltStorage) GetOriginalURL(_ context.Context, shortID string) (string, error) {
+	pair, found, err := s.getURL(shortID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get original URL: %w", err)
+	}
+	if !found {
+		return "", nil
+	}
+	return pair.RedirectURL(), nil
+}
+
+// GetRedirect возвращает сведения для перехода по ссылке shortID.
+func (s *BoltStorage) GetRedirect(_ context.Context, shortID string) (Redirect, error) {
+	pair, found, err := s.getURL(shortID)
+	if err != nil {
+		return Redirect{}, fmt.Errorf("failed to get redirect: %w", err)
+	}
+	if !found {
+		return Redirect{}, ErrNotFound
+	}
+	return redirectOf(pair), nil
+}
+
+// ConsumeClick списывает переход по ссылке shortID в одной транзакции записи;
+// bbolt выполняет их по одной, поэтому списания не теряются.
+func (s *BoltStorage) ConsumeClick(_ context.Context, shortID string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(boltURLsBucket)
+		data := urls.Get([]byte(shortID))
+		if data == nil {
+			return ErrNotFound
+		}
+		var pair URLPair
+		if err := json.Unmarshal(data, &pair); err != nil {
+			return err
+		}
+		if changed, err := consumeClick(&pair); !changed {
+			return err
+		}
+		data, err := json.Marshal(pair)
+		if err != nil {
+			return err
+		}
+		return urls.Put([]byte(shortID), data)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrClicksExhausted) {
+		return fmt.Errorf("failed to consume click: %w", err)
+	}
+	return err
+}
+
+// getURL читает ссылку shortID; found ложно, если ее нет.
+func (s *BoltStorage) getURL(shortID string) (pair URLPair, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltURLsBucket).Get([]byte(shortID))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &pair)
+	})
+	return pair, found, err
+}
+
+func (s *BoltStorage) GetURLsByUserID(ctx context.Context, userID string) ([]URLPair, error) {
+	return s.ListUserURLs(ctx, userID, ListOptions{})
+}
+
+// ForEachUserURL перечисляет ссылки пользователя страницами ListUserURLs,
+// каждая — в своей транзакции чтения.
+func (s *BoltStorage) ForEachUserURL(ctx context.Context, userID string, fn func(URLPair) error) error {
+	return forEachPage(ctx, func(opts ListOptions) ([]URLPair, error) {
+		return s.ListUserURLs(ctx, userID, opts)
+	}, fn)
+}
+
+// SearchUserURLs реализует Searcher перебором ссылок пользователя: отдельного
+// поискового индекса у BoltStorage нет.
+func (s *BoltStorage) SearchUserURLs(ctx context.Context, userID string, opts SearchOptions) ([]SearchHit, error) {
+	return scanSearch(func(fn func(URLPair) error) error {
+		return s.ForEachUserURL(ctx, userID, fn)
+	}, opts)
+}
+
+// ListUserURLs возвращает страницу ссылок пользователя, обходя индекс
+// by_user_created курсором в нужном направлении.
+func (s *BoltStorage) ListUserURLs(_ context.Context, userID string, opts ListOptions) ([]URLPair, error) {
+	prefix := boltUserPrefix(userID)
+	var urls []URLPair
+	err := s.db.View(func(tx *bolt.Tx) error {
+		records := tx.Bucket(boltURLsBucket)
+		c := tx.Bucket(boltUserBucket).Cursor()
+
+		var k []byte
+		next := c.Next
+		switch {
+		case opts.Desc:
+			// Встаем на первый ключ за верхней границей и шагаем назад.
+			next = c.Prev
+			bound := append(append([]byte(nil), userID...), boltKeySeparator[0]+1)
+			if opts.After != nil {
+				bound = boltUserKey(userID, *opts.After)
+			}
+			if k, _ = c.Seek(bound); k == nil {
+				k, _ = c.Last()
+			} else {
+				k, _ = c.Prev()
+			}
+		case opts.After != nil:
+			after := boltUserKey(userID, *opts.After)
+			if k, _ = c.Seek(after); k != nil && bytes.Equal(k, after) {
+				k, _ = c.Next()
+			}
+		default:
+			k, _ = c.Seek(prefix)
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = next() {
+			data := records.Get(k[len(prefix)+8:])
+			if data == nil {
+				continue
+			}
+			var pair URLPair
+			if err := json.Unmarshal(data, &pair); err != nil {
+				return err
+			}
+			if !opts.matches(pair) {
+				continue
+			}
+			urls = append(urls, pair)
+			if opts.Limit > 0 && len(urls) == opts.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user urls: %w", err)
+	}
+	return urls, nil
+}
+
+// ForEachURL реализует Exporter. Записи читаются пачками в отдельных
+// транзакциях чтения, чтобы долгий экспорт не удерживал старые страницы базы.
+func (s *BoltStorage) ForEachURL(ctx context.Context, after string, fn func(URLPair) error) error {
+	batch := make([]URLPair, 0, exportBatchSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		err := s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(boltURLsBucket).Cursor()
+			k, v := c.Seek([]byte(after))
+			if k != nil && string(k) == after {
+				k, v = c.Next()
+			}
+			for ; k != nil && len(batch) < exportBatchSize; k, v = c.Next() {
+				var pair URLPair
+				if err := json.Unmarshal(v, &pair); err != nil {
+					return err
+				}
+				batch = append(batch, pair)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read urls: %w", err)
+		}
+
+		for _, pair := range batch {
+			if err := fn(pair); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].ShortURL
+	}
+}
+
+// ImportURL реализует Importer. Конфликтом считается совпадение короткого ID
+// или оригинального URL; при overwrite обе такие записи заменяются импортируемой.
+func (s *BoltStorage) ImportURL(_ context.Context, pair URLPair, overwrite bool) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(boltURLsBucket)
+		byOriginal := tx.Bucket(boltOriginalBucket)
+
+		replaced := make([]string, 0, 2)
+		if urls.Get([]byte(pair.ShortURL)) != nil {
+			replaced = append(replaced, pair.ShortURL)
+		}
+		if existing := byOriginal.Get([]byte(pair.OriginalURL)); existing != nil && string(existing) != pair.ShortURL {
+			replaced = append(replaced, string(existing))
+		}
+		if len(replaced) > 0 && !overwrite {
+			return NewErrConflict(replaced[0])
+		}
+		for _, shortID := range replaced {
+			if err := boltDelete(tx, shortID); err != nil {
+				return err
+			}
+		}
+
+		return boltPut(tx, pair)
+	})
+	var conflictErr *ErrConflict
+	if errors.As(err, &conflictErr) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import url: %w", err)
+	}
+	return nil
+}
+
+// UpdateURLMetadata изменяет метаданные ссылки shortID пользователя userID.
+// Индексы от метаданных не зависят, поэтому перезаписывается только запись.
+func (s *BoltStorage) UpdateURLMetadata(_ context.Context, userID, shortID string, patch MetadataPatch) (URLPair, error) {
+	var pair URLPair
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if pair, err = boltGetUserURL(tx, userID, shortID); err != nil {
+			return err
+		}
+		pair.Metadata = patch.Apply(pair.Metadata)
+		if pair.emptyWindow() {
+			return ErrEmptyWindow
+		}
+		data, err := json.Marshal(pair)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltURLsBucket).Put([]byte(shortID), data)
+	})
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrEmptyWindow) {
+		return URLPair{}, err
+	}
+	if err != nil {
+		return URLPair{}, fmt.Errorf("failed to update url metadata: %w", err)
+	}
+	return pair, nil
+}
+
+// UpdateOriginalURL меняет адрес назначения ссылки shortID пользователя userID
+// и дописывает смену в историю в той же транзакции. Если новый адрес уже
+// сокращен другой ссылкой, возвращает ErrConflict с ее ID.
+func (s *BoltStorage) UpdateOriginalURL(_ context.Context, userID, shortID, originalURL string) (URLPair, error) {
+	var pair URLPair
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if pair, err = boltGetUserURL(tx, userID, shortID); err != nil {
+			return err
+		}
+		if pair.OriginalURL == originalURL {
+			return nil
+		}
+		if existing := tx.Bucket(boltOriginalBucket).Get([]byte(originalURL)); existing != nil {
+			return NewErrConflict(string(existing))
+		}
+
+		var entry HistoryEntry
+		pair, entry = retarget(pair, userID, originalURL)
+		if err := boltDelete(tx, shortID); err != nil {
+			return err
+		}
+		if err := boltPut(tx, pair); err != nil {
+			return err
+		}
+		history := tx.Bucket(boltHistoryBucket)
+		seq, err := history.NextSequence()
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return history.Put(binary.BigEndian.AppendUint64(boltHistoryPrefix(shortID), seq), data)
+	})
+	var conflictErr *ErrConflict
+	if errors.Is(err, ErrNotFound) || errors.As(err, &conflictErr) {
+		return URLPair{}, err
+	}
+	if err != nil {
+		return URLPair{}, fmt.Errorf("failed to update original url: %w", err)
+	}
+	return pair, nil
+}
+
+// GetURLHistory возвращает историю смены адресов ссылки shortID пользователя
+// userID от старых изменений к новым.
+func (s *BoltStorage) GetURLHistory(_ context.Context, userID, shortID string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if _, err := boltGetUserURL(tx, userID, shortID); err != nil {
+			return err
+		}
+		prefix := boltHistoryPrefix(shortID)
+		c := tx.Bucket(boltHistoryBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get url history: %w", err)
+	}
+	return entries, nil
+}
+
+// SetURLPreview сохраняет сведения о странице ссылки shortID, если она все еще
+// ведет на originalURL.
+func (s *BoltStorage) SetURLPreview(_ context.Context, shortID, originalURL string, preview Preview) error {
+	err := s.updateDestination(shortID, originalURL, func(pair *URLPair) { pair.Preview = preview })
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to set url preview: %w", err)
+	}
+	return err
+}
+
+// SetURLHealth сохраняет результат проверки ссылки shortID, если она все еще
+// ведет на originalURL.
+func (s *BoltStorage) SetURLHealth(_ context.Context, shortID, originalURL string, health LinkHealth) error {
+	err := s.updateDestination(shortID, originalURL, func(pair *URLPair) { pair.Health = health })
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to set url health: %w", err)
+	}
+	return err
+}
+
+// updateDestination перезаписывает ссылку shortID, измененную fn, если она
+// ведет на originalURL. Индексы от изменяемых полей не зависят.
+func (s *BoltStorage) updateDestination(shortID, originalURL string, fn func(*URLPair)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(boltURLsBucket)
+		data := urls.Get([]byte(shortID))
+		if data == nil {
+			return ErrNotFound
+		}
+		var pair URLPair
+		if err := json.Unmarshal(data, &pair); err != nil {
+			return err
+		}
+		if pair.OriginalURL != originalURL {
+			return ErrNotFound
+		}
+		fn(&pair)
+		data, err := json.Marshal(pair)
+		if err != nil {
+			return err
+		}
+		return urls.Put([]byte(shortID), data)
+	})
+}
+
+// boltGetUserURL читает ссылку shortID пользователя userID; чужая или
+// отсутствующая ссылка — ErrNotFound.
+func boltGetUserURL(tx *bolt.Tx, userID, shortID string) (URLPair, error) {
+	data := tx.Bucket(boltURLsBucket).Get([]byte(shortID))
+	if data == nil {
+		return URLPair{}, ErrNotFound
+	}
+	var pair URLPair
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return URLPair{}, err
+	}
+	if pair.UserID != userID {
+		return URLPair{}, ErrNotFound
+	}
+	return pair, nil
+}
+
+// boltPut сохраняет запись и ее индексы по оригинальному URL и пользователю.
+func boltPut(tx *bolt.Tx, pair URLPair) error {
+	data, err := json.Marshal(pair)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltURLsBucket).Put([]byte(pair.ShortURL), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltOriginalBucket).Put([]byte(pair.OriginalURL), []byte(pair.ShortURL)); err != nil {
+		return err
+	}
+	return tx.Bucket(boltUserBucket).Put(boltUserKey(pair.UserID, CursorOf(pair)), nil)
+}
+
+// boltDelete удаляет запись вместе с ее индексами.
+func boltDelete(tx *bolt.Tx, shortID string) error {
+	urls := tx.Bucket(boltURLsBucket)
+	data := urls.Get([]byte(shortID))
+	if data == nil {
+		return nil
+	}
+	var pair URLPair
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltOriginalBucket).Delete([]byte(pair.OriginalURL)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltUserBucket).Delete(boltUserKey(pair.UserID, CursorOf(pair))); err != nil {
+		return err
+	}
+	return urls.Delete([]byte(shortID))
+}
+
+// FilePath возвращает путь к файлу базы.
+func (s *BoltStorage) FilePath() string {
+	return s.path
+}
+
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+// boltUserPrefix — общий префикс ключей индекса по пользователю. Разделитель
+// \x00 не может встретиться в идентификаторе пользователя, поэтому префиксы
+// разных пользователей не пересекаются.
+func boltUserPrefix(userID string) []byte {
+	key := make([]byte, 0, len(userID)+1)
+	key = append(key, userID...)
+	return append(key, boltKeySeparator...)
+}
+
+// boltHistoryPrefix — общий префикс ключей истории ссылки shortID.
+func boltHistoryPrefix(shortID string) []byte {
+	return append([]byte(shortID), boltKeySeparator...)
+}
+
+// boltUserKey формирует ключ индекса по пользователю: префикс, время создания
+// (наносекунды Unix, big-endian; 0 — неизвестно) и короткий ID, так что
+// порядок ключей совпадает с порядком (CreatedAt, ShortURL).
+func boltUserKey(userID string, c Cursor) []byte {
+	key := boltUserPrefix(userID)
+	var ts uint64
+	if !c.CreatedAt.IsZero() && c.CreatedAt.UnixNano() > 0 {
+		ts = uint64(c.CreatedAt.UnixNano())
+	}
+	key = binary.BigEndian.AppendUint64(key, ts)
+	return append(key, c.ShortURL...)
+}
+
+// boltBuildUserIndex создает индекс by_user_created по всем записям.
+func boltBuildUserIndex(tx *bolt.Tx) error {
+	index, err := tx.CreateBucket(boltUserBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltURLsBucket).ForEach(func(_, data []byte) error {
+		var pair URLPair
+		if err := json.Unmarshal(data, &pair); err != nil {
+			return err
+		}
+		return index.Put(boltUserKey(pair.UserID, CursorOf(pair)), nil)
+	})
+}
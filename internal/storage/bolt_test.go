@@ -0,0 +1,79 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"shorturl/internal/storage"
+	"testing"
+)
+
+func TestBoltStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := storage.NewBoltStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open bolt storage: %v", err)
+	}
+
+	first, err := s.CreateShortURL(ctx, "user-1", "https://example.com/a")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if _, err := s.CreateShortURL(ctx, "user-1", "https://example.com/b"); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if _, err := s.CreateShortURL(ctx, "user-2", "https://example.com/c"); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	existing, err := s.CreateShortURL(ctx, "user-2", "https://example.com/a")
+	var conflictErr *storage.ErrConflict
+	if !errors.As(err, &conflictErr) || existing != first {
+		t.Fatalf("Expected conflict with %s, got %s, %v", first, existing, err)
+	}
+
+	// Данные и индексы должны пережить переоткрытие базы.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	s, err = storage.NewBoltStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen bolt storage: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	original, err := s.GetOriginalURL(ctx, first)
+	if err != nil || original != "https://example.com/a" {
+		t.Errorf("Expected original URL, got %q, %v", original, err)
+	}
+	if missing, err := s.GetOriginalURL(ctx, "missing1"); err != nil || missing != "" {
+		t.Errorf("Expected empty result for missing ID, got %q, %v", missing, err)
+	}
+
+	urls, err := s.GetURLsByUserID(ctx, "user-1")
+	if err != nil || len(urls) != 2 {
+		t.Fatalf("Expected 2 URLs for user-1, got %d, %v", len(urls), err)
+	}
+	for _, u := range urls {
+		if u.UserID != "user-1" || u.UUID == "" {
+			t.Errorf("Unexpected pair %+v", u)
+		}
+	}
+	if urls, _ := s.GetURLsByUserID(ctx, "user"); len(urls) != 0 {
+		t.Errorf("User prefix must not match other users, got %d", len(urls))
+	}
+
+	var streamed []storage.URLPair
+	err = s.ForEachUserURL(ctx, "user-1", func(pair storage.URLPair) error {
+		streamed = append(streamed, pair)
+		return nil
+	})
+	if err != nil || len(streamed) != 2 || streamed[0].ShortURL != first {
+		t.Fatalf("Expected 2 URLs for user-1 in creation order, got %+v, %v", streamed, err)
+	}
+	if streamed[0].CreatedAt.IsZero() {
+		t.Errorf("Expected created_at to be recorded, got %+v", streamed[0])
+	}
+}
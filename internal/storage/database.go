@@ -0,0 +1,863 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"shorturl/internal/tracing"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func init() {
+	Register(BackendPostgres, FactoryFunc(func(cfg *config.Config) (Store, error) {
+		if cfg.DatabaseDSN == "" {
+			return nil, errors.New("DATABASE_DSN is not set")
+		}
+		if IsSQLiteDSN(cfg.DatabaseDSN) {
+			return nil, fmt.Errorf("DATABASE_DSN with %s scheme requires the %s backend", sqliteScheme, BackendSQLite)
+		}
+		return NewDatabaseStorage(cfg.DatabaseDSN)
+	}))
+}
+
+// DatabaseStorage — хранилище поверх database/sql. Используется как для
+// PostgreSQL, так и для SQLite: схема и запросы у них общие.
+type DatabaseStorage struct {
+	db      *sql.DB
+	system  string // значение атрибута db.system в спанах
+	trigram bool   // в PostgreSQL установлено расширение pg_trgm
+}
+
+// NewDatabaseStorage создает и возвращает новый экземпляр DatabaseStorage.
+func NewDatabaseStorage(dsn string) (*DatabaseStorage, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(25)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	// Проверяем соединение с базой данных
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	if err := migrate(ctx, db, dbSystemPostgres); err != nil {
+		return nil, err
+	}
+
+	var trigram bool
+	const extensionQuery = `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')`
+	if err := db.QueryRowContext(ctx, extensionQuery).Scan(&trigram); err != nil {
+		return nil, fmt.Errorf("failed to check pg_trgm extension: %w", err)
+	}
+
+	logger.Logger.Info("Successfully connected to PostgreSQL and ensured table 'urls' exists",
+		zap.Bool("fuzzy_search", trigram))
+	return &DatabaseStorage{db: db, system: dbSystemPostgres, trigram: trigram}, nil
+}
+
+func (s *DatabaseStorage) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
+	return s.CreateURL(ctx, URLPair{UserID: userID, OriginalURL: originalURL})
+}
+
+// CreateURL сохраняет ссылку по образцу pair, назначая ей UUID, короткий ID и
+// время создания. Если такой оригинальный URL уже есть, возвращает его ID и ErrConflict.
+func (s *DatabaseStorage) CreateURL(ctx context.Context, pair URLPair) (string, error) {
+	pair.UUID, pair.ShortURL, pair.CreatedAt = uuid.NewString(), generateShortID(), timeNow()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Logger.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	insertQuery := insertURLQuery + " ON CONFLICT (original_url) DO NOTHING"
+	spanCtx, span := s.startQuerySpan(ctx, "INSERT", insertQuery)
+	result, err := tx.ExecContext(spanCtx, insertQuery, insertURLArgs(pair)...)
+	endQuerySpan(span, err)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to execute insert on conflict: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected > 0 {
+		if err := tx.Commit(); err != nil {
+			return "", fmt.Errorf("failed to commit transaction for new insert: %w", err)
+		}
+		return pair.ShortURL, nil
+	}
+
+	var existingShortID string
+	const selectQuery = "SELECT short_url FROM urls WHERE original_url = $1"
+	spanCtx, span = s.startQuerySpan(ctx, "SELECT", selectQuery)
+	err = tx.QueryRowContext(spanCtx, selectQuery, pair.OriginalURL).Scan(&existingShortID)
+	endQuerySpan(span, err)
+
+	if err != nil {
+		return "", fmt.Errorf("conflict occurred but failed to retrieve existing short_id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction for conflict case: %w", err)
+	}
+	return existingShortID, NewErrConflict(existingShortID)
+}
+
+func (s *DatabaseStorage) GetOriginalURL(ctx context.Context, shortID string) (string, error) {
+	var originalURL string
+	// Мертвая ссылка с запасным адресом ведет на него (см. URLPair.RedirectURL).
+	const query = `SELECT CASE WHEN health_dead_since IS NOT NULL AND COALESCE(fallback_url, '') <> ''
+		THEN fallback_url ELSE original_url END FROM urls WHERE short_url = $1`
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	err := s.db.QueryRowContext(spanCtx, query, shortID).Scan(&originalURL)
+	endQuerySpan(span, err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil // Возвращаем nil, nil, как и InMemoryStorage/FileStorage
+		}
+		return "", fmt.Errorf("failed to get original URL: %w", err)
+	}
+	return originalURL, nil
+}
+
+// GetRedirect возвращает сведения для перехода по ссылке shortID одним
+// запросом, выбирая адрес так же, как GetOriginalURL.
+func (s *DatabaseStorage) GetRedirect(ctx context.Context, shortID string) (Redirect, error) {
+	var redirect Redirect
+	const query = `SELECT CASE WHEN health_dead_since IS NOT NULL AND COALESCE(fallback_url, '') <> ''
+		THEN fallback_url ELSE original_url END, COALESCE(password_hash, ''), COALESCE(max_clicks, 0) > 0,
+		not_before, not_after FROM urls WHERE short_url = $1`
+	var notBefore, notAfter sql.NullTime
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	err := s.db.QueryRowContext(spanCtx, query, shortID).Scan(&redirect.URL, &redirect.PasswordHash, &redirect.Limited,
+		&notBefore, &notAfter)
+	endQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Redirect{}, ErrNotFound
+	}
+	if err != nil {
+		return Redirect{}, fmt.Errorf("failed to get redirect: %w", err)
+	}
+	redirect.NotBefore, redirect.NotAfter = utcTime(notBefore), utcTime(notAfter)
+	return redirect, nil
+}
+
+// ConsumeClick списывает переход одним UPDATE ... RETURNING: условие на
+// остаток проверяется при обновлении строки, поэтому параллельные переходы не
+// спишут больше, чем разрешено. Если строка не изменилась, отдельный запрос
+// отличает отсутствующую ссылку и ссылку без ограничения от исчерпанной.
+func (s *DatabaseStorage) ConsumeClick(ctx context.Context, shortID string) error {
+	var left int
+	const query = `UPDATE urls SET clicks_left = clicks_left - 1
+		WHERE short_url = $1 AND max_clicks > 0 AND clicks_left > 0 RETURNING clicks_left`
+	spanCtx, span := s.startQuerySpan(ctx, "UPDATE", query)
+	err := s.db.QueryRowContext(spanCtx, query, shortID).Scan(&left)
+	endQuerySpan(span, err)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to consume click: %w", err)
+	}
+
+	var maxClicks int
+	const selectQuery = "SELECT COALESCE(max_clicks, 0) FROM urls WHERE short_url = $1"
+	spanCtx, span = s.startQuerySpan(ctx, "SELECT", selectQuery)
+	err = s.db.QueryRowContext(spanCtx, selectQuery, shortID).Scan(&maxClicks)
+	endQuerySpan(span, err)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case err != nil:
+		return fmt.Errorf("failed to consume click: %w", err)
+	case maxClicks > 0:
+		return ErrClicksExhausted
+	}
+	return nil
+}
+
+func (s *DatabaseStorage) GetURLsByUserID(ctx context.Context, userID string) ([]URLPair, error) {
+	const query = "SELECT " + urlColumns + " FROM urls WHERE user_id = $1"
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	defer span.End()
+	rows, err := s.db.QueryContext(spanCtx, query, userID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to query urls by user id: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Logger.Error("failed to close rows", zap.Error(err))
+		}
+	}()
+
+	var urls []URLPair
+	for rows.Next() {
+		pair, err := scanURLPair(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, pair)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return urls, nil
+}
+
+// ForEachUserURL перечисляет ссылки пользователя страницами ListUserURLs,
+// не загружая их все в память.
+func (s *DatabaseStorage) ForEachUserURL(ctx context.Context, userID string, fn func(URLPair) error) error {
+	return forEachPage(ctx, func(opts ListOptions) ([]URLPair, error) {
+		return s.ListUserURLs(ctx, userID, opts)
+	}, fn)
+}
+
+// ListUserURLs возвращает страницу ссылок пользователя. Сортировка и курсор
+// обслуживаются индексом urls_user_created_idx (user_id, created_at, short_url),
+// точный домен — urls_user_domain_idx, папка — urls_user_folder_idx. Фильтры
+// по подстроке (q, tag, поддомены) в PostgreSQL используют триграммные
+// индексы, если доступно pg_trgm; иначе, как и в SQLite, они проверяются
+// построчно среди ссылок пользователя.
+func (s *DatabaseStorage) ListUserURLs(ctx context.Context, userID string, opts ListOptions) ([]URLPair, error) {
+	query := "SELECT " + urlColumns + " FROM urls WHERE user_id = $1"
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	order, cmp := "ASC", ">"
+	if opts.Desc {
+		order, cmp = "DESC", "<"
+	}
+	if opts.After != nil {
+		query += fmt.Sprintf(" AND (created_at, short_url) %s (%s, %s)",
+			cmp, arg(dbCreatedAt(opts.After.CreatedAt)), arg(opts.After.ShortURL))
+	}
+	if opts.Domain != "" {
+		domain := strings.ToLower(opts.Domain)
+		query += fmt.Sprintf(` AND (domain = %s OR domain LIKE %s ESCAPE '\')`, arg(domain), arg("%."+escapeLike(domain)))
+	}
+	if opts.Query != "" {
+		query += fmt.Sprintf(` AND LOWER(original_url) LIKE %s ESCAPE '\'`, arg("%"+escapeLike(strings.ToLower(opts.Query))+"%"))
+	}
+	if opts.Tag != "" {
+		tag := strings.ToLower(strings.TrimSpace(opts.Tag))
+		query += fmt.Sprintf(` AND tags LIKE %s ESCAPE '\'`, arg("%,"+escapeLike(tag)+",%"))
+	}
+	if opts.Folder != "" {
+		query += " AND folder = " + arg(opts.Folder)
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, short_url %s", order, order)
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit)
+	}
+
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	defer span.End()
+	rows, err := s.db.QueryContext(spanCtx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list user urls: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Logger.Error("failed to close rows", zap.Error(err))
+		}
+	}()
+
+	var urls []URLPair
+	for rows.Next() {
+		pair, err := scanURLPair(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, pair)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return urls, nil
+}
+
+// UpdateURLMetadata изменяет метаданные ссылки shortID пользователя userID и
+// пересчитывает слова для поиска. В PostgreSQL строка блокируется до конца
+// транзакции, чтобы параллельные изменения не затирали друг друга; SQLite
+// работает через одно соединение и сериализует транзакции сама.
+func (s *DatabaseStorage) UpdateURLMetadata(ctx context.Context, userID, shortID string, patch MetadataPatch) (URLPair, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return URLPair{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Logger.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	pair, err := s.selectUserURLForUpdate(ctx, tx, userID, shortID)
+	if err != nil {
+		return URLPair{}, err
+	}
+	pair.Metadata = patch.Apply(pair.Metadata)
+	if pair.emptyWindow() {
+		return URLPair{}, ErrEmptyWindow
+	}
+
+	const updateQuery = `UPDATE urls SET title = $1, tags = $2, notes = $3, folder = $4, fallback_url = $5,
+		not_before = $6, not_after = $7, search_text = $8 WHERE short_url = $9`
+	spanCtx, span := s.startQuerySpan(ctx, "UPDATE", updateQuery)
+	_, err = tx.ExecContext(spanCtx, updateQuery, nullString(pair.Title), encodeTags(pair.Tags), nullString(pair.Notes),
+		nullString(pair.Folder), nullString(pair.FallbackURL), nullTime(pair.NotBefore), nullTime(pair.NotAfter),
+		searchText(pair), shortID)
+	endQuerySpan(span, err)
+	if err != nil {
+		return URLPair{}, fmt.Errorf("failed to update url metadata: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return URLPair{}, fmt.Errorf("failed to commit url metadata: %w", err)
+	}
+	return pair, nil
+}
+
+// UpdateOriginalURL меняет адрес назначения ссылки shortID пользователя userID
+// и в той же транзакции дописывает смену в url_history. Оригинальный URL
+// уникален: если новый адрес уже сокращен другой ссылкой, возвращается
+// ErrConflict с ее ID, как и при создании.
+func (s *DatabaseStorage) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (URLPair, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return URLPair{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Logger.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	pair, err := s.selectUserURLForUpdate(ctx, tx, userID, shortID)
+	if err != nil {
+		return URLPair{}, err
+	}
+	if pair.OriginalURL == originalURL {
+		return pair, nil
+	}
+	existing, err := s.shortIDByOriginalURL(ctx, tx, originalURL)
+	if err != nil {
+		return URLPair{}, err
+	}
+	if existing != "" {
+		return URLPair{}, NewErrConflict(existing)
+	}
+
+	pair, entry := retarget(pair, userID, originalURL)
+	const updateQuery = `UPDATE urls SET original_url = $1, domain = $2, search_text = $3,
+		preview_title = NULL, preview_description = NULL, preview_favicon_url = NULL, preview_fetched_at = NULL,
+		health_status = NULL, health_error = NULL, health_latency_ms = NULL, health_checked_at = NULL,
+		health_failures = NULL, health_dead_since = NULL
+		WHERE short_url = $4`
+	spanCtx, span := s.startQuerySpan(ctx, "UPDATE", updateQuery)
+	_, err = tx.ExecContext(spanCtx, updateQuery, originalURL, URLDomain(originalURL), searchText(pair), shortID)
+	endQuerySpan(span, err)
+	if err != nil {
+		// Ссылку с тем же адресом могли создать после проверки: в PostgreSQL
+		// транзакция после нарушения UNIQUE прервана, поэтому ищем ее вне транзакции.
+		_ = tx.Rollback()
+		if existing, _ := s.shortIDByOriginalURL(ctx, s.db, originalURL); existing != "" {
+			return URLPair{}, NewErrConflict(existing)
+		}
+		return URLPair{}, fmt.Errorf("failed to update original url: %w", err)
+	}
+
+	const historyQuery = `INSERT INTO url_history (short_url, seq, changed_at, changed_by, old_url, new_url)
+		SELECT $1, COALESCE(MAX(seq), 0) + 1, $2, $3, $4, $5 FROM url_history WHERE short_url = $1`
+	spanCtx, span = s.startQuerySpan(ctx, "INSERT", historyQuery)
+	_, err = tx.ExecContext(spanCtx, historyQuery, shortID, entry.ChangedAt, entry.ChangedBy, entry.OldURL, entry.NewURL)
+	endQuerySpan(span, err)
+	if err != nil {
+		return URLPair{}, fmt.Errorf("failed to record url history: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return URLPair{}, fmt.Errorf("failed to commit original url: %w", err)
+	}
+	return pair, nil
+}
+
+// GetURLHistory возвращает историю смены адресов ссылки shortID пользователя
+// userID от старых изменений к новым.
+func (s *DatabaseStorage) GetURLHistory(ctx context.Context, userID, shortID string) ([]HistoryEntry, error) {
+	var exists bool
+	const ownerQuery = "SELECT EXISTS (SELECT 1 FROM urls WHERE short_url = $1 AND user_id = $2)"
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", ownerQuery)
+	err := s.db.QueryRowContext(spanCtx, ownerQuery, shortID, userID).Scan(&exists)
+	endQuerySpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check url owner: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	const query = `SELECT changed_at, changed_by, old_url, new_url FROM url_history
+		WHERE short_url = $1 ORDER BY seq`
+	spanCtx, span = s.startQuerySpan(ctx, "SELECT", query)
+	defer span.End()
+	rows, err := s.db.QueryContext(spanCtx, query, shortID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to query url history: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Logger.Error("failed to close rows", zap.Error(err))
+		}
+	}()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		if err := rows.Scan(&entry.ChangedAt, &entry.ChangedBy, &entry.OldURL, &entry.NewURL); err != nil {
+			return nil, fmt.Errorf("failed to scan url history: %w", err)
+		}
+		entry.ChangedAt = entry.ChangedAt.UTC()
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return entries, nil
+}
+
+// rowQuerier — общее у *sql.DB и *sql.Tx для запросов одной строки.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SetURLPreview сохраняет сведения о странице ссылки shortID, если она все еще
+// ведет на originalURL: условие в WHERE не дает устаревшей загрузке затереть
+// ссылку, адрес которой успели сменить.
+func (s *DatabaseStorage) SetURLPreview(ctx context.Context, shortID, originalURL string, preview Preview) error {
+	const query = `UPDATE urls SET preview_title = $1, preview_description = $2, preview_favicon_url = $3,
+		preview_fetched_at = $4 WHERE short_url = $5 AND original_url = $6`
+	spanCtx, span := s.startQuerySpan(ctx, "UPDATE", query)
+	result, err := s.db.ExecContext(spanCtx, query, append(previewArgs(preview), shortID, originalURL)...)
+	endQuerySpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to set url preview: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set url preview: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetURLHealth сохраняет результат проверки ссылки shortID, если она все еще
+// ведет на originalURL.
+func (s *DatabaseStorage) SetURLHealth(ctx context.Context, shortID, originalURL string, health LinkHealth) error {
+	const query = `UPDATE urls SET health_status = $1, health_error = $2, health_latency_ms = $3, health_checked_at = $4,
+		health_failures = $5, health_dead_since = $6 WHERE short_url = $7 AND original_url = $8`
+	spanCtx, span := s.startQuerySpan(ctx, "UPDATE", query)
+	result, err := s.db.ExecContext(spanCtx, query, append(healthArgs(health), shortID, originalURL)...)
+	endQuerySpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to set url health: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set url health: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// shortIDByOriginalURL возвращает короткий ID ссылки на originalURL или
+// пустую строку, если такой ссылки нет.
+func (s *DatabaseStorage) shortIDByOriginalURL(ctx context.Context, q rowQuerier, originalURL string) (string, error) {
+	var shortID string
+	const query = "SELECT short_url FROM urls WHERE original_url = $1"
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	err := q.QueryRowContext(spanCtx, query, originalURL).Scan(&shortID)
+	endQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up original url: %w", err)
+	}
+	return shortID, nil
+}
+
+// selectUserURLForUpdate читает ссылку shortID пользователя userID в
+// транзакции tx, блокируя строку в PostgreSQL. Чужая или отсутствующая
+// ссылка — ErrNotFound.
+func (s *DatabaseStorage) selectUserURLForUpdate(ctx context.Context, tx *sql.Tx, userID, shortID string) (URLPair, error) {
+	query := "SELECT " + urlColumns + " FROM urls WHERE short_url = $1 AND user_id = $2"
+	if s.system == dbSystemPostgres {
+		query += " FOR UPDATE"
+	}
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	rows, err := tx.QueryContext(spanCtx, query, shortID, userID)
+	if err != nil {
+		endQuerySpan(span, err)
+		return URLPair{}, fmt.Errorf("failed to select url: %w", err)
+	}
+	var pair URLPair
+	found := rows.Next()
+	if found {
+		pair, err = scanURLPair(rows)
+	}
+	err = errors.Join(err, rows.Err(), rows.Close())
+	endQuerySpan(span, err)
+	if err != nil {
+		return URLPair{}, err
+	}
+	if !found {
+		return URLPair{}, ErrNotFound
+	}
+	return pair, nil
+}
+
+// searchDocument — выражение tsvector для поиска; совпадает с выражением
+// индекса urls_search_tsv_idx, иначе PostgreSQL не использует индекс.
+const searchDocument = `to_tsvector('simple', COALESCE(search_text, ''))`
+
+// SearchUserURLs реализует Searcher. В PostgreSQL каждый терм ищется как
+// префикс лексемы tsvector, ранг — ts_rank; с расширением pg_trgm ссылка
+// находится и по похожему слову (опечатки), а к рангу добавляется
+// word_similarity. SQLite ищет перебором, как и BoltStorage.
+func (s *DatabaseStorage) SearchUserURLs(ctx context.Context, userID string, opts SearchOptions) ([]SearchHit, error) {
+	if s.system != dbSystemPostgres {
+		return scanSearch(func(fn func(URLPair) error) error {
+			return s.ForEachUserURL(ctx, userID, fn)
+		}, opts)
+	}
+
+	terms := SearchTerms(opts.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+	args := []any{userID, strings.Join(prefixes, " & ")}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	rank := "ts_rank(" + searchDocument + ", to_tsquery('simple', $2))"
+	match := searchDocument + " @@ to_tsquery('simple', $2)"
+	if s.trigram {
+		text := arg(strings.Join(terms, " "))
+		rank += fmt.Sprintf(" + word_similarity(%s, COALESCE(search_text, ''))", text)
+		match = fmt.Sprintf("(%s OR %s <%% search_text)", match, text)
+	}
+	query := "SELECT " + urlColumns + ", " + rank + " AS score FROM urls WHERE user_id = $1 AND " + match +
+		" ORDER BY score DESC, created_at DESC, short_url DESC"
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		query += " OFFSET " + arg(opts.Offset)
+	}
+
+	spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+	defer span.End()
+	rows, err := s.db.QueryContext(spanCtx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to search user urls: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Logger.Error("failed to close rows", zap.Error(err))
+		}
+	}()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var score float64
+		pair, err := scanURLPair(rows, &score)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, SearchHit{URLPair: pair, Score: score})
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return hits, nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE (экранирующий символ — \).
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// dbCreatedAt возвращает значение created_at для записи в базу.
+func dbCreatedAt(t time.Time) time.Time {
+	if t.IsZero() {
+		return legacyCreatedAt
+	}
+	return t.UTC()
+}
+
+// ForEachURL реализует Exporter. Записи читаются пачками по ключу short_url
+// (keyset pagination), поэтому экспорт не держит долгую транзакцию.
+func (s *DatabaseStorage) ForEachURL(ctx context.Context, after string, fn func(URLPair) error) error {
+	const query = "SELECT " + urlColumns + ` FROM urls
+		WHERE short_url > $1 ORDER BY short_url LIMIT $2`
+	return s.forEachBatch(ctx, query, func(after string) []any {
+		return []any{after, exportBatchSize}
+	}, fn)
+}
+
+// forEachBatch выполняет query пачками по exportBatchSize записей, начиная с
+// пустого ключа. args возвращает параметры запроса для очередной пачки по
+// short_url последней прочитанной записи. fn вызывается вне запроса, чтобы
+// медленный потребитель не удерживал соединение.
+func (s *DatabaseStorage) forEachBatch(ctx context.Context, query string, args func(after string) []any, fn func(URLPair) error) error {
+	batch := make([]URLPair, 0, exportBatchSize)
+	after := ""
+	for {
+		batch = batch[:0]
+		spanCtx, span := s.startQuerySpan(ctx, "SELECT", query)
+		rows, err := s.db.QueryContext(spanCtx, query, args(after)...)
+		if err != nil {
+			endQuerySpan(span, err)
+			return fmt.Errorf("failed to query urls: %w", err)
+		}
+		for rows.Next() {
+			pair, err := scanURLPair(rows)
+			if err != nil {
+				_ = rows.Close()
+				endQuerySpan(span, err)
+				return err
+			}
+			batch = append(batch, pair)
+		}
+		err = errors.Join(rows.Err(), rows.Close())
+		endQuerySpan(span, err)
+		if err != nil {
+			return fmt.Errorf("rows iteration error: %w", err)
+		}
+
+		for _, pair := range batch {
+			if err := fn(pair); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].ShortURL
+	}
+}
+
+// urlColumns — колонки записи в порядке, ожидаемом scanURLPair.
+const urlColumns = "short_url, original_url, COALESCE(user_id, ''), COALESCE(uuid, ''), created_at, " +
+	"COALESCE(title, ''), COALESCE(tags, ''), COALESCE(notes, ''), COALESCE(folder, ''), " +
+	"COALESCE(preview_title, ''), COALESCE(preview_description, ''), COALESCE(preview_favicon_url, ''), preview_fetched_at, " +
+	"COALESCE(fallback_url, ''), COALESCE(health_status, 0), COALESCE(health_error, ''), COALESCE(health_latency_ms, 0), " +
+	"health_checked_at, COALESCE(health_failures, 0), health_dead_since, COALESCE(password_hash, ''), " +
+	"COALESCE(max_clicks, 0), COALESCE(clicks_left, 0), not_before, not_after"
+
+// insertURLQuery вставляет запись со всеми колонками; аргументы — insertURLArgs.
+const insertURLQuery = `INSERT INTO urls (short_url, original_url, user_id, uuid, created_at, domain, search_text,
+		title, tags, notes, folder, preview_title, preview_description, preview_favicon_url, preview_fetched_at,
+		fallback_url, health_status, health_error, health_latency_ms, health_checked_at, health_failures, health_dead_since,
+		password_hash, max_clicks, clicks_left, not_before, not_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23,
+		$24, $25, $26, $27)`
+
+func insertURLArgs(pair URLPair) []any {
+	args := append([]any{pair.ShortURL, pair.OriginalURL, pair.UserID, pair.UUID, dbCreatedAt(pair.CreatedAt),
+		URLDomain(pair.OriginalURL), searchText(pair),
+		nullString(pair.Title), encodeTags(pair.Tags), nullString(pair.Notes), nullString(pair.Folder)},
+		previewArgs(pair.Preview)...)
+	args = append(args, nullString(pair.FallbackURL))
+	args = append(args, healthArgs(pair.Health)...)
+	return append(args, nullString(pair.PasswordHash), pair.MaxClicks, pair.ClicksLeft,
+		nullTime(pair.NotBefore), nullTime(pair.NotAfter))
+}
+
+// previewArgs возвращает значения колонок preview_*; пустые поля хранятся как NULL.
+func previewArgs(p Preview) []any {
+	return []any{nullString(p.Title), nullString(p.Description), nullString(p.FaviconURL), nullTime(p.FetchedAt)}
+}
+
+// healthArgs возвращает значения колонок health_*; у непроверенной ссылки все они NULL.
+func healthArgs(h LinkHealth) []any {
+	if h.IsZero() {
+		return []any{nil, nil, nil, nil, nil, nil}
+	}
+	return []any{h.Status, nullString(h.Error), h.LatencyMS, nullTime(h.CheckedAt), h.Failures, nullTime(h.DeadSince)}
+}
+
+// nullTime возвращает t в UTC или NULL для нулевого времени.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// utcTime возвращает время t в UTC или нулевое время для NULL.
+func utcTime(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC()
+}
+
+// scanURLPair читает запись, выбранную колонками urlColumns; значения
+// следующих за ними колонок попадают в extra. У записей с неизвестным временем
+// создания (legacyCreatedAt) оно остается нулевым.
+func scanURLPair(rows *sql.Rows, extra ...any) (URLPair, error) {
+	var pair URLPair
+	var createdAt sql.NullTime
+	var tags string
+	var fetchedAt, checkedAt, deadSince, notBefore, notAfter sql.NullTime
+	dest := append([]any{&pair.ShortURL, &pair.OriginalURL, &pair.UserID, &pair.UUID, &createdAt,
+		&pair.Title, &tags, &pair.Notes, &pair.Folder,
+		&pair.Preview.Title, &pair.Preview.Description, &pair.Preview.FaviconURL, &fetchedAt,
+		&pair.FallbackURL, &pair.Health.Status, &pair.Health.Error, &pair.Health.LatencyMS, &checkedAt,
+		&pair.Health.Failures, &deadSince, &pair.PasswordHash, &pair.MaxClicks, &pair.ClicksLeft,
+		&notBefore, &notAfter}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return URLPair{}, fmt.Errorf("failed to scan url pair: %w", err)
+	}
+	pair.Tags = decodeTags(tags)
+	pair.Preview.FetchedAt = utcTime(fetchedAt)
+	pair.Health.CheckedAt = utcTime(checkedAt)
+	pair.Health.DeadSince = utcTime(deadSince)
+	pair.NotBefore, pair.NotAfter = utcTime(notBefore), utcTime(notAfter)
+	if createdAt.Valid && !createdAt.Time.Equal(legacyCreatedAt) {
+		pair.CreatedAt = createdAt.Time.UTC()
+	}
+	return pair, nil
+}
+
+// ImportURL реализует Importer. Конфликтом считается совпадение короткого ID
+// или оригинального URL; при overwrite обе такие записи заменяются импортируемой.
+func (s *DatabaseStorage) ImportURL(ctx context.Context, pair URLPair, overwrite bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Logger.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	if overwrite {
+		const deleteQuery = "DELETE FROM urls WHERE short_url = $1 OR original_url = $2"
+		spanCtx, span := s.startQuerySpan(ctx, "DELETE", deleteQuery)
+		_, err := tx.ExecContext(spanCtx, deleteQuery, pair.ShortURL, pair.OriginalURL)
+		endQuerySpan(span, err)
+		if err != nil {
+			return fmt.Errorf("failed to delete replaced url: %w", err)
+		}
+	}
+
+	insertQuery := insertURLQuery + " ON CONFLICT DO NOTHING"
+	spanCtx, span := s.startQuerySpan(ctx, "INSERT", insertQuery)
+	result, err := tx.ExecContext(spanCtx, insertQuery, insertURLArgs(pair)...)
+	endQuerySpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to import url: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		var existingShortID string
+		const selectQuery = "SELECT short_url FROM urls WHERE short_url = $1 OR original_url = $2 LIMIT 1"
+		spanCtx, span := s.startQuerySpan(ctx, "SELECT", selectQuery)
+		err := tx.QueryRowContext(spanCtx, selectQuery, pair.ShortURL, pair.OriginalURL).Scan(&existingShortID)
+		endQuerySpan(span, err)
+		if err != nil {
+			return fmt.Errorf("conflict occurred but failed to retrieve existing short_id: %w", err)
+		}
+		return NewErrConflict(existingShortID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
+
+func (s *DatabaseStorage) PingContext(ctx context.Context) error {
+	spanCtx, span := s.startQuerySpan(ctx, "PING", "")
+	err := s.db.PingContext(spanCtx)
+	endQuerySpan(span, err)
+	return err
+}
+
+// Stats возвращает статистику пула соединений с базой данных.
+func (s *DatabaseStorage) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+func (s *DatabaseStorage) Close() error {
+	return s.db.Close()
+}
+
+// startQuerySpan создает клиентский спан для одного SQL-выражения.
+func (s *DatabaseStorage) startQuerySpan(ctx context.Context, operation, query string) (context.Context, *tracing.Span) {
+	attrs := []tracing.Attribute{
+		tracing.String("db.system", s.system),
+		tracing.String("db.operation", operation),
+	}
+	if query != "" {
+		attrs = append(attrs, tracing.String("db.statement", query))
+	}
+	return tracing.Start(ctx, "SQL "+operation, tracing.WithKind(tracing.SpanKindClient), tracing.WithAttributes(attrs...))
+}
+
+// endQuerySpan завершает спан SQL-выражения. sql.ErrNoRows ошибкой не считается.
+func endQuerySpan(span *tracing.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,564 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"slices"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register(BackendFile, FactoryFunc(func(cfg *config.Config) (Store, error) {
+		if cfg.FileStoragePath == "" {
+			return nil, errors.New("FILE_STORAGE_PATH is not set")
+		}
+		durability, err := ParseDurability(cfg.FileDurability)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileStorage(cfg.FileStoragePath,
+			WithDurability(durability, cfg.FileSyncInterval),
+			WithCompaction(CompactionPolicy{
+				MaxLogBytes:     cfg.FileCompactMaxBytes,
+				MaxGarbageRatio: cfg.FileCompactRatio,
+				MinRecords:      DefaultCompactMinRecords,
+				Interval:        cfg.FileCompactInterval,
+			}))
+	}))
+}
+
+// ErrStorageClosed возвращается при записи в закрытое хранилище.
+var ErrStorageClosed = errors.New("storage is closed")
+
+// maxCommitBatch ограничивает число записей в одной групповой фиксации.
+const maxCommitBatch = 256
+
+// FileStorage представляет собой реализацию хранилища в файле.
+// Данные лежат в снимке (filePath + ".snapshot") и журнале дозаписи (filePath);
+// при запуске снимок загружается, а журнал проигрывается поверх него.
+//
+// Журнал открыт все время работы, а пишет в него одна горутина: записи,
+// пришедшие, пока идет предыдущая запись или fsync, фиксируются одной пачкой
+// (group commit). Она же выполняет fsync по расписанию и компактизацию.
+type FileStorage struct {
+	mu       sync.RWMutex
+	urls     map[string]URLPair
+	byUser   userIndex
+	search   searchIndex
+	history  map[string][]HistoryEntry // история смены адресов по короткому ID
+	editMu   sync.Mutex                // сериализует чтение-изменение-запись при изменении ссылок
+	filePath string
+	file     *os.File
+
+	durability   Durability
+	syncInterval time.Duration
+
+	policy          CompactionPolicy
+	logBytes        int64 // размер журнала
+	logRecords      int   // записей в журнале
+	snapshotRecords int   // записей в снимке
+	garbage         int   // записей на диске, перекрытых более поздними
+
+	writes    chan *appendRequest
+	compacts  chan chan error
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// appendRequest — запись, ожидающая фиксации в журнале.
+type appendRequest struct {
+	rec    fileRecord
+	record []byte
+	done   chan error
+}
+
+// FileOption настраивает FileStorage.
+type FileOption func(*FileStorage)
+
+// WithCompaction включает компактизацию журнала по условиям policy.
+func WithCompaction(policy CompactionPolicy) FileOption {
+	return func(s *FileStorage) {
+		s.policy = policy
+	}
+}
+
+// WithDurability задает режим сброса журнала на диск. interval используется
+// только в режиме DurabilityInterval.
+func WithDurability(mode Durability, interval time.Duration) FileOption {
+	return func(s *FileStorage) {
+		s.durability = mode
+		s.syncInterval = interval
+	}
+}
+
+// NewFileStorage создает и возвращает новый экземпляр FileStorage.
+func NewFileStorage(filePath string, opts ...FileOption) (*FileStorage, error) {
+	fs := &FileStorage{
+		urls:         make(map[string]URLPair),
+		byUser:       make(userIndex),
+		search:       make(searchIndex),
+		history:      make(map[string][]HistoryEntry),
+		filePath:     filePath,
+		durability:   DurabilityInterval,
+		syncInterval: DefaultSyncInterval,
+		writes:       make(chan *appendRequest),
+		compacts:     make(chan chan error),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	if fs.durability == DurabilityInterval && fs.syncInterval <= 0 {
+		fs.syncInterval = DefaultSyncInterval
+	}
+	if err := fs.loadFromFile(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fs.file = file
+
+	fs.wg.Add(1)
+	go fs.writeLoop()
+	return fs, nil
+}
+
+// CreateShortURL записывает ссылку в журнал и возвращает управление после
+// фиксации пачки, в которую она попала (в режиме DurabilityAlways — после fsync).
+func (s *FileStorage) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
+	return s.CreateURL(ctx, URLPair{UserID: userID, OriginalURL: originalURL})
+}
+
+// CreateURL сохраняет ссылку по образцу pair, назначая ей UUID, короткий ID и время создания.
+func (s *FileStorage) CreateURL(ctx context.Context, pair URLPair) (string, error) {
+	pair.UUID, pair.ShortURL, pair.CreatedAt = uuid.NewString(), generateShortID(), timeNow()
+	if err := s.append(ctx, fileRecord{URLPair: pair}); err != nil {
+		return "", err
+	}
+	return pair.ShortURL, nil
+}
+
+// UpdateURLMetadata дописывает в журнал версию ссылки shortID пользователя
+// userID с измененными метаданными.
+func (s *FileStorage) UpdateURLMetadata(ctx context.Context, userID, shortID string, patch MetadataPatch) (URLPair, error) {
+	// Изменения одной записи сериализуются, иначе параллельные правки
+	// прочитают одну и ту же версию и одна из них потеряется.
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	s.mu.RLock()
+	pair, ok := s.urls[shortID]
+	s.mu.RUnlock()
+	if !ok || pair.UserID != userID {
+		return URLPair{}, ErrNotFound
+	}
+	pair.Metadata = patch.Apply(pair.Metadata)
+	if pair.emptyWindow() {
+		return URLPair{}, ErrEmptyWindow
+	}
+	if err := s.append(ctx, fileRecord{URLPair: pair}); err != nil {
+		return URLPair{}, err
+	}
+	return pair, nil
+}
+
+// UpdateOriginalURL дописывает в журнал версию ссылки shortID пользователя
+// userID с новым адресом назначения вместе с дополненной историей.
+// Уникальность проверяется только по короткому ID, как и при создании ссылок.
+func (s *FileStorage) UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (URLPair, error) {
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	s.mu.RLock()
+	pair, ok := s.urls[shortID]
+	history := s.history[shortID]
+	s.mu.RUnlock()
+	if !ok || pair.UserID != userID {
+		return URLPair{}, ErrNotFound
+	}
+	if pair.OriginalURL == originalURL {
+		return pair, nil
+	}
+	pair, entry := retarget(pair, userID, originalURL)
+	history = append(slices.Clone(history), entry)
+	if err := s.append(ctx, fileRecord{URLPair: pair, History: history}); err != nil {
+		return URLPair{}, err
+	}
+	return pair, nil
+}
+
+// GetURLHistory возвращает историю смены адресов ссылки shortID пользователя
+// userID от старых изменений к новым.
+func (s *FileStorage) GetURLHistory(_ context.Context, userID, shortID string) ([]HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if pair, ok := s.urls[shortID]; !ok || pair.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(s.history[shortID]), nil
+}
+
+// SetURLPreview дописывает в журнал версию ссылки shortID со сведениями о
+// странице, если ссылка все еще ведет на originalURL.
+func (s *FileStorage) SetURLPreview(ctx context.Context, shortID, originalURL string, preview Preview) error {
+	return s.updateDestination(ctx, shortID, originalURL, func(pair *URLPair) { pair.Preview = preview })
+}
+
+// SetURLHealth дописывает в журнал версию ссылки shortID с результатом
+// проверки, если ссылка все еще ведет на originalURL.
+func (s *FileStorage) SetURLHealth(ctx context.Context, shortID, originalURL string, health LinkHealth) error {
+	return s.updateDestination(ctx, shortID, originalURL, func(pair *URLPair) { pair.Health = health })
+}
+
+// updateDestination дописывает версию ссылки shortID, измененную fn, если
+// ссылка ведет на originalURL.
+func (s *FileStorage) updateDestination(ctx context.Context, shortID, originalURL string, fn func(*URLPair)) error {
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	s.mu.RLock()
+	pair, ok := s.urls[shortID]
+	s.mu.RUnlock()
+	if !ok || pair.OriginalURL != originalURL {
+		return ErrNotFound
+	}
+	fn(&pair)
+	return s.append(ctx, fileRecord{URLPair: pair})
+}
+
+// ForEachURL реализует Exporter.
+func (s *FileStorage) ForEachURL(ctx context.Context, after string, fn func(URLPair) error) error {
+	return forEachSorted(ctx, &s.mu, &s.urls, after, fn)
+}
+
+// ForEachUserURL перечисляет ссылки пользователя по возрастанию времени создания.
+func (s *FileStorage) ForEachUserURL(ctx context.Context, userID string, fn func(URLPair) error) error {
+	return forEachPage(ctx, func(opts ListOptions) ([]URLPair, error) {
+		return s.ListUserURLs(ctx, userID, opts)
+	}, fn)
+}
+
+// ListUserURLs возвращает страницу ссылок пользователя по индексу byUser.
+func (s *FileStorage) ListUserURLs(_ context.Context, userID string, opts ListOptions) ([]URLPair, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byUser.list(s.urls, userID, opts), nil
+}
+
+// SearchUserURLs реализует Searcher по инвертированному индексу search.
+func (s *FileStorage) SearchUserURLs(_ context.Context, userID string, opts SearchOptions) ([]SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.search.search(s.urls, userID, opts), nil
+}
+
+// ImportURL реализует Importer. Уникальность проверяется только по короткому ID,
+// как и при создании ссылок. Перезапись дописывает в журнал новую версию записи.
+func (s *FileStorage) ImportURL(ctx context.Context, pair URLPair, overwrite bool) error {
+	if !overwrite {
+		s.mu.RLock()
+		_, exists := s.urls[pair.ShortURL]
+		s.mu.RUnlock()
+		if exists {
+			return NewErrConflict(pair.ShortURL)
+		}
+	}
+	if pair.UUID == "" {
+		pair.UUID = uuid.NewString()
+	}
+	return s.append(ctx, fileRecord{URLPair: pair})
+}
+
+// append передает запись горутине записи и ждет ее фиксации.
+func (s *FileStorage) append(ctx context.Context, rec fileRecord) error {
+	record, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	req := &appendRequest{rec: rec, record: record, done: make(chan error, 1)}
+	select {
+	case s.writes <- req:
+	case <-s.done:
+		return ErrStorageClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// Принятую запись дожидаемся независимо от ctx: иначе клиент получит
+	// ошибку для ссылки, которая все равно окажется в журнале.
+	return <-req.done
+}
+
+func (s *FileStorage) GetOriginalURL(_ context.Context, shortID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pair, ok := s.urls[shortID]
+	if !ok {
+		return "", nil
+	}
+	return pair.RedirectURL(), nil
+}
+
+// GetRedirect возвращает сведения для перехода по ссылке shortID.
+func (s *FileStorage) GetRedirect(_ context.Context, shortID string) (Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pair, ok := s.urls[shortID]
+	if !ok {
+		return Redirect{}, ErrNotFound
+	}
+	return redirectOf(pair), nil
+}
+
+// ConsumeClick дописывает в журнал версию ссылки shortID с уменьшенным
+// остатком переходов. Списания сериализуются editMu, как и прочие правки.
+func (s *FileStorage) ConsumeClick(ctx context.Context, shortID string) error {
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	s.mu.RLock()
+	pair, ok := s.urls[shortID]
+	s.mu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	changed, err := consumeClick(&pair)
+	if !changed {
+		return err
+	}
+	return s.append(ctx, fileRecord{URLPair: pair})
+}
+
+func (s *FileStorage) GetURLsByUserID(ctx context.Context, userID string) ([]URLPair, error) {
+	return s.ListUserURLs(ctx, userID, ListOptions{})
+}
+
+// FilePath возвращает путь к файлу хранилища.
+func (s *FileStorage) FilePath() string {
+	return s.filePath
+}
+
+// Close останавливает горутину записи, сбрасывает журнал на диск и закрывает его.
+func (s *FileStorage) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		s.closeErr = errors.Join(s.file.Sync(), s.file.Close())
+	})
+	return s.closeErr
+}
+
+// put добавляет запись в память, учитывая перекрытые версии. Вызывается под s.mu.
+func (s *FileStorage) put(rec fileRecord) {
+	pair := rec.URLPair
+	if old, ok := s.urls[pair.ShortURL]; ok {
+		s.garbage++
+		s.byUser.remove(old)
+		s.search.remove(old)
+	}
+	s.urls[pair.ShortURL] = pair
+	s.byUser.add(pair)
+	s.search.add(pair)
+	if len(rec.History) > 0 {
+		s.history[pair.ShortURL] = rec.History
+	}
+}
+
+// writeLoop — единственный писатель журнала: групповая фиксация записей,
+// fsync по расписанию и компактизация.
+func (s *FileStorage) writeLoop() {
+	defer s.wg.Done()
+
+	var syncTick, compactTick <-chan time.Time
+	if s.durability == DurabilityInterval {
+		ticker := time.NewTicker(s.syncInterval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+	if s.policy.Interval > 0 {
+		ticker := time.NewTicker(s.policy.Interval)
+		defer ticker.Stop()
+		compactTick = ticker.C
+	}
+
+	s.maybeCompact(false)
+	dirty := false
+	for {
+		select {
+		case <-s.done:
+			return
+		case req := <-s.writes:
+			batch := []*appendRequest{req}
+		collect:
+			for len(batch) < maxCommitBatch {
+				select {
+				case req := <-s.writes:
+					batch = append(batch, req)
+				default:
+					break collect
+				}
+			}
+			if err := s.commit(batch); err != nil {
+				for _, req := range batch {
+					req.done <- err
+				}
+				continue
+			}
+			dirty = s.durability != DurabilityAlways
+			for _, req := range batch {
+				req.done <- nil
+			}
+			s.maybeCompact(false)
+		case <-syncTick:
+			if dirty {
+				if err := s.file.Sync(); err != nil {
+					logger.Logger.Error("Failed to sync file storage log", zap.String("path", s.filePath), zap.Error(err))
+					continue
+				}
+				dirty = false
+			}
+		case <-compactTick:
+			s.maybeCompact(true)
+		case reply := <-s.compacts:
+			reply <- s.compactLog()
+		}
+	}
+}
+
+// commit пишет пачку одним вызовом write и применяет ее к памяти. При ошибке
+// журнал обрезается до прежнего размера, иначе следующая запись склеится с
+// частично записанной строкой и обе будут потеряны при загрузке.
+func (s *FileStorage) commit(batch []*appendRequest) error {
+	var buf bytes.Buffer
+	for _, req := range batch {
+		buf.Write(req.record)
+	}
+
+	_, err := s.file.Write(buf.Bytes())
+	if err == nil && s.durability == DurabilityAlways {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		if truncErr := s.file.Truncate(s.logBytes); truncErr != nil {
+			logger.Logger.Error("failed to truncate partial record", zap.Error(truncErr))
+		}
+		return fmt.Errorf("failed to append to file storage log: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, req := range batch {
+		s.put(req.rec)
+	}
+	s.logBytes += int64(buf.Len())
+	s.logRecords += len(batch)
+	return nil
+}
+
+// maybeCompact компактизирует журнал при срабатывании порогов; force — по
+// расписанию, если журнал не пуст.
+func (s *FileStorage) maybeCompact(force bool) {
+	s.mu.RLock()
+	due := (force && s.logRecords > 0) || s.needsCompaction()
+	s.mu.RUnlock()
+	if due {
+		if err := s.compactLog(); err != nil {
+			logger.Logger.Error("Failed to compact file storage log", zap.String("path", s.filePath), zap.Error(err))
+		}
+	}
+}
+
+func (s *FileStorage) loadFromFile() error {
+	n, err := s.replay(s.snapshotPath(), false)
+	if err != nil {
+		return err
+	}
+	s.snapshotRecords = n
+
+	s.logRecords, err = s.replay(s.filePath, true)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(s.filePath)
+	if err != nil {
+		return err
+	}
+	s.logBytes = info.Size()
+	return nil
+}
+
+// replay загружает записи файла path и возвращает их число. Поврежденная
+// последняя запись журнала — след прерванной записи: она отрезается, а
+// загрузка продолжается. Повреждение в середине файла или в снимке, который
+// пишется атомарно, — ошибка.
+func (s *FileStorage) replay(path string, isLog bool) (int, error) {
+	flags := os.O_RDONLY
+	if isLog {
+		flags |= os.O_CREATE
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if errors.Is(err, os.ErrNotExist) && !isLog {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Logger.Error("failed to close file in loadFromFile", zap.Error(err))
+		}
+	}()
+
+	var (
+		reader  = bufio.NewReader(file)
+		offset  int64
+		records int
+	)
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return 0, readErr
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			rec, err := decodeRecord(line)
+			if err == nil && readErr != nil {
+				err = errors.New("missing trailing newline")
+			}
+			if err != nil {
+				if isLog && atEOF(reader) {
+					logger.Logger.Warn("Truncating torn record at the end of file storage log",
+						zap.String("path", path), zap.Int("line", lineNo), zap.Int64("offset", offset), zap.Error(err))
+					return records, os.Truncate(path, offset)
+				}
+				return 0, fmt.Errorf("%w: %s line %d: %v", ErrCorruptRecord, path, lineNo, err)
+			}
+			s.put(rec)
+			records++
+		}
+		offset += int64(len(line))
+		if readErr != nil {
+			return records, nil
+		}
+	}
+}
+
+// atEOF сообщает, что в reader не осталось данных.
+func atEOF(reader *bufio.Reader) bool {
+	_, err := reader.Peek(1)
+	return errors.Is(err, io.EOF)
+}
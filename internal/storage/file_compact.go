@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bufio"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"shorturl/internal/logger"
+	"time"
+)
+
+// DefaultCompactMinRecords — минимальное число записей на диске, начиная
+// с которого журнал компактизируется по доле устаревших записей.
+const DefaultCompactMinRecords = 1000
+
+// CompactionPolicy задает условия компактизации журнала FileStorage.
+// Нулевое значение поля отключает соответствующий триггер.
+type CompactionPolicy struct {
+	// MaxLogBytes — размер журнала, после которого он сворачивается в снимок.
+	MaxLogBytes int64
+	// MaxGarbageRatio — допустимая доля перекрытых записей среди всех записей на диске.
+	MaxGarbageRatio float64
+	// MinRecords — порог числа записей для MaxGarbageRatio, чтобы не
+	// компактизировать маленькие файлы.
+	MinRecords int
+	// Interval — компактизация по расписанию, если журнал не пуст.
+	Interval time.Duration
+}
+
+// Compact записывает текущее состояние в снимок и очищает журнал. Снимок
+// пишется во временный файл, синхронизируется и атомарно переименовывается,
+// поэтому при сбое на любом шаге на диске остается либо старый снимок с
+// полным журналом, либо новый снимок (возможно, с уже учтенным журналом —
+// повторное проигрывание записей идемпотентно). Выполняется горутиной записи,
+// поэтому не пересекается с фиксацией пачек.
+func (s *FileStorage) Compact() error {
+	reply := make(chan error, 1)
+	select {
+	case s.compacts <- reply:
+		return <-reply
+	case <-s.done:
+		return ErrStorageClosed
+	}
+}
+
+// compactLog выполняет компактизацию. Вызывается только горутиной записи:
+// кроме нее s.urls никто не меняет, поэтому снимок строится и пишется под
+// s.mu.RLock и не задерживает чтения, а журнал обрезается без блокировки —
+// его пишет та же горутина. Монопольная блокировка нужна лишь для сброса
+// счетчиков.
+func (s *FileStorage) compactLog() error {
+	start := time.Now()
+	records, err := s.writeSnapshot()
+	if err != nil {
+		return err
+	}
+
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logger.Logger.Info("Compacted file storage log",
+		zap.String("path", s.filePath),
+		zap.Int("records", records),
+		zap.Int("log_records", s.logRecords),
+		zap.Int64("log_bytes", s.logBytes),
+		zap.Int("garbage", s.garbage),
+		zap.Duration("duration", time.Since(start)))
+
+	s.snapshotRecords = records
+	s.logRecords = 0
+	s.logBytes = 0
+	s.garbage = 0
+	return nil
+}
+
+// writeSnapshot атомарно заменяет снимок текущим состоянием и возвращает
+// число записанных ссылок.
+func (s *FileStorage) writeSnapshot() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshotPath := s.snapshotPath()
+
+	tmp, err := os.CreateTemp(filepath.Dir(snapshotPath), filepath.Base(snapshotPath)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	for _, pair := range s.urls {
+		record, err := encodeRecord(fileRecord{URLPair: pair, History: s.history[pair.ShortURL]})
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, snapshotPath); err != nil {
+		return 0, err
+	}
+	committed = true
+	if err := syncDir(filepath.Dir(snapshotPath)); err != nil {
+		return 0, err
+	}
+	return len(s.urls), nil
+}
+
+// needsCompaction проверяет пороги размера и доли мусора. Вызывается под s.mu.
+func (s *FileStorage) needsCompaction() bool {
+	p := s.policy
+	if p.MaxLogBytes > 0 && s.logBytes >= p.MaxLogBytes {
+		return true
+	}
+	total := s.snapshotRecords + s.logRecords
+	return p.MaxGarbageRatio > 0 && total > 0 && total >= p.MinRecords &&
+		float64(s.garbage)/float64(total) >= p.MaxGarbageRatio
+}
+
+func (s *FileStorage) snapshotPath() string {
+	return s.filePath + ".snapshot"
+}
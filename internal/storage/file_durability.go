@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultSyncInterval — период fsync журнала в режиме DurabilityInterval.
+const DefaultSyncInterval = time.Second
+
+// Durability — режим сброса журнала FileStorage на диск.
+type Durability int
+
+const (
+	// DurabilityNone полагается на page cache ОС: запись подтверждается после
+	// write, fsync выполняется только при компактизации и закрытии.
+	DurabilityNone Durability = iota
+	// DurabilityInterval подтверждает запись после write и выполняет fsync
+	// раз в интервал: при сбое питания теряется не больше интервала записей.
+	DurabilityInterval
+	// DurabilityAlways подтверждает запись только после fsync ее пачки.
+	DurabilityAlways
+)
+
+// ParseDurability разбирает значение FILE_DURABILITY.
+func ParseDurability(s string) (Durability, error) {
+	switch strings.ToLower(s) {
+	case "none":
+		return DurabilityNone, nil
+	case "", "interval":
+		return DurabilityInterval, nil
+	case "always":
+		return DurabilityAlways, nil
+	default:
+		return 0, fmt.Errorf("unknown file durability mode %q (expected none, interval or always)", s)
+	}
+}
+
+func (d Durability) String() string {
+	switch d {
+	case DurabilityNone:
+		return "none"
+	case DurabilityInterval:
+		return "interval"
+	case DurabilityAlways:
+		return "always"
+	default:
+		return fmt.Sprintf("Durability(%d)", int(d))
+	}
+}
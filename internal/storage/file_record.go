@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// ErrCorruptRecord возвращается, если запись файлового хранилища повреждена
+// не в конце журнала, где ее можно списать на прерванную запись.
+var ErrCorruptRecord = errors.New("corrupt file storage record")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// fileRecord — строка журнала или снимка файлового хранилища. History —
+// полная история смены адреса ссылки: пишется в записи, которые ее дополняют,
+// и в снимок; запись без истории оставляет прежнюю. CRC — контрольная сумма
+// CRC-32C JSON-представления записи без поля crc. Строки без CRC (формат до
+// появления контрольных сумм) принимаются как есть.
+type fileRecord struct {
+	URLPair
+	History []HistoryEntry `json:"history,omitempty"`
+	CRC     string         `json:"crc,omitempty"`
+}
+
+// encodeRecord кодирует запись в строку с контрольной суммой и переводом строки.
+func encodeRecord(rec fileRecord) ([]byte, error) {
+	sum, err := recordChecksum(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.CRC = sum
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// decodeRecord разбирает строку и сверяет контрольную сумму.
+func decodeRecord(line []byte) (fileRecord, error) {
+	var rec fileRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return fileRecord{}, err
+	}
+	if rec.CRC == "" {
+		return rec, nil
+	}
+	sum, err := recordChecksum(rec)
+	if err != nil {
+		return fileRecord{}, err
+	}
+	if sum != rec.CRC {
+		return fileRecord{}, fmt.Errorf("checksum mismatch: got %s, want %s", sum, rec.CRC)
+	}
+	return rec, nil
+}
+
+func recordChecksum(rec fileRecord) (string, error) {
+	rec.CRC = ""
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%08x", crc32.Checksum(data, crcTable)), nil
+}
@@ -0,0 +1,216 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"shorturl/internal/storage"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStorageCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	s, err := storage.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	first, _ := s.CreateShortURL(ctx, "user-1", "https://example.com/a")
+	_, _ = s.CreateShortURL(ctx, "user-1", "https://example.com/b")
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Fatalf("Expected empty log after compaction, got %v, %v", info, err)
+	}
+	if _, err := os.Stat(path + ".snapshot"); err != nil {
+		t.Fatalf("Expected snapshot file: %v", err)
+	}
+	_, _ = s.CreateShortURL(ctx, "user-1", "https://example.com/c")
+	_ = s.Close()
+
+	s, err = storage.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if original, _ := s.GetOriginalURL(ctx, first); original != "https://example.com/a" {
+		t.Errorf("Expected record from snapshot, got %q", original)
+	}
+	if urls, _ := s.GetURLsByUserID(ctx, "user-1"); len(urls) != 3 {
+		t.Errorf("Expected 3 URLs from snapshot and log, got %d", len(urls))
+	}
+	matches, _ := filepath.Glob(path + ".snapshot.*.tmp")
+	if len(matches) != 0 {
+		t.Errorf("Expected no leftover temp files, got %v", matches)
+	}
+}
+
+func TestFileStorageCompactsOnSize(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	s, err := storage.NewFileStorage(path, storage.WithCompaction(storage.CompactionPolicy{MaxLogBytes: 512}))
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	for i := 0; i < 10; i++ {
+		if _, err := s.CreateShortURL(ctx, "user-1", "https://example.com/"+strings.Repeat("x", i)); err != nil {
+			t.Fatalf("CreateShortURL failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		info, err := os.Stat(path)
+		if err == nil && info.Size() < 512 {
+			if _, err := os.Stat(path + ".snapshot"); err == nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected log to be compacted, size %d", info.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFileStorageTornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	// Строка в старом формате без контрольной суммы тоже должна загружаться.
+	legacy := `{"id":"1","short_url":"legacy01","original_url":"https://example.com/legacy"}` + "\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	id, _ := s.CreateShortURL(ctx, "user-1", "https://example.com/a")
+	_ = s.Close()
+
+	data, _ := os.ReadFile(path)
+	good := len(data)
+	torn := append(data, []byte(`{"id":"x","short_url":"torn0001","orig`)...)
+	if err := os.WriteFile(path, torn, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = storage.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Expected torn tail to be tolerated, got %v", err)
+	}
+	if original, _ := s.GetOriginalURL(ctx, id); original != "https://example.com/a" {
+		t.Errorf("Expected record before torn tail, got %q", original)
+	}
+	if original, _ := s.GetOriginalURL(ctx, "legacy01"); original != "https://example.com/legacy" {
+		t.Errorf("Expected legacy record, got %q", original)
+	}
+	_ = s.Close()
+	if info, _ := os.Stat(path); info.Size() != int64(good) {
+		t.Errorf("Expected torn tail to be truncated to %d bytes, got %d", good, info.Size())
+	}
+
+	// Запись с неверной контрольной суммой в середине журнала — повреждение.
+	data, _ = os.ReadFile(path)
+	corrupted := strings.Replace(string(data), "https://example.com/a", "https://example.com/b", 1)
+	corrupted += `{"id":"2","short_url":"after001","original_url":"https://example.com/after"}` + "\n"
+	if err := os.WriteFile(path, []byte(corrupted), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.NewFileStorage(path); !errors.Is(err, storage.ErrCorruptRecord) {
+		t.Errorf("Expected ErrCorruptRecord, got %v", err)
+	}
+}
+
+var durabilityModes = []storage.Durability{storage.DurabilityNone, storage.DurabilityInterval, storage.DurabilityAlways}
+
+func TestFileStorageDurabilityModes(t *testing.T) {
+	ctx := context.Background()
+	for _, mode := range durabilityModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "urls.json")
+			s, err := storage.NewFileStorage(path, storage.WithDurability(mode, 10*time.Millisecond))
+			if err != nil {
+				t.Fatalf("Failed to open file storage: %v", err)
+			}
+
+			const writers = 50
+			ids := make([]string, writers)
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id, err := s.CreateShortURL(ctx, "user-1", "https://example.com/"+strings.Repeat("x", i))
+					if err != nil {
+						t.Errorf("CreateShortURL failed: %v", err)
+					}
+					ids[i] = id
+				}(i)
+			}
+			wg.Wait()
+
+			// Подтвержденная запись сразу видна на чтение.
+			for _, id := range ids {
+				if original, _ := s.GetOriginalURL(ctx, id); original == "" {
+					t.Errorf("Expected %s to be readable after acknowledgement", id)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if _, err := s.CreateShortURL(ctx, "user-1", "https://example.com/late"); !errors.Is(err, storage.ErrStorageClosed) {
+				t.Errorf("Expected ErrStorageClosed, got %v", err)
+			}
+
+			s, err = storage.NewFileStorage(path)
+			if err != nil {
+				t.Fatalf("Failed to reopen file storage: %v", err)
+			}
+			defer func() { _ = s.Close() }()
+			if urls, _ := s.GetURLsByUserID(ctx, "user-1"); len(urls) != writers {
+				t.Errorf("Expected %d URLs after reopen, got %d", writers, len(urls))
+			}
+		})
+	}
+
+	if _, err := storage.ParseDurability("sometimes"); err == nil {
+		t.Errorf("Expected error for unknown durability mode")
+	}
+}
+
+// BenchmarkFileStorageCreate сравнивает пропускную способность записи в
+// разных режимах durability при конкурентной нагрузке.
+func BenchmarkFileStorageCreate(b *testing.B) {
+	ctx := context.Background()
+	for _, mode := range durabilityModes {
+		b.Run(mode.String(), func(b *testing.B) {
+			s, err := storage.NewFileStorage(filepath.Join(b.TempDir(), "urls.json"), storage.WithDurability(mode, storage.DefaultSyncInterval))
+			if err != nil {
+				b.Fatalf("Failed to open file storage: %v", err)
+			}
+			defer func() { _ = s.Close() }()
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := s.CreateShortURL(ctx, "user-1", "https://example.com/benchmark"); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
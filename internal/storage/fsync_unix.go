@@ -0,0 +1,19 @@
+//go:build !windows
+
+package storage
+
+import "os"
+
+// syncDir сбрасывает на диск запись каталога, чтобы переименование файла
+// пережило сбой питания.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,23 @@
+package storage
+
+import "time"
+
+// HistoryEntry — запись истории ссылки: смена адреса назначения. История
+// только дополняется, записи не изменяются и не удаляются.
+type HistoryEntry struct {
+	ChangedAt time.Time `json:"changed_at"`
+	ChangedBy string    `json:"changed_by"` // пользователь, сменивший адрес
+	OldURL    string    `json:"old_url"`
+	NewURL    string    `json:"new_url"`
+}
+
+// retarget возвращает версию pair с адресом назначения originalURL и запись
+// истории о смене адреса пользователем userID. Сведения о прежней странице
+// и результаты ее проверок сбрасываются.
+func retarget(pair URLPair, userID, originalURL string) (URLPair, HistoryEntry) {
+	entry := HistoryEntry{ChangedAt: timeNow(), ChangedBy: userID, OldURL: pair.OriginalURL, NewURL: originalURL}
+	pair.OriginalURL = originalURL
+	pair.Preview = Preview{}
+	pair.Health = LinkHealth{}
+	return pair, entry
+}
@@ -0,0 +1,183 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"shorturl/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestUpdateOriginalURL(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pair := storage.URLPair{UUID: "uuid-1", ShortURL: "printed", OriginalURL: "https://old.example.com/promo", UserID: "user", CreatedAt: base}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.ImportURL(ctx, pair, false); err != nil {
+				t.Fatalf("ImportURL failed: %v", err)
+			}
+			if history, err := s.GetURLHistory(ctx, "user", "printed"); err != nil || len(history) != 0 {
+				t.Errorf("Expected empty history, got %+v, %v", history, err)
+			}
+
+			// Тот же адрес — не изменение.
+			if _, err := s.UpdateOriginalURL(ctx, "user", "printed", pair.OriginalURL); err != nil {
+				t.Fatalf("UpdateOriginalURL failed: %v", err)
+			}
+			for _, target := range []string{"https://new.example.org/sale", "https://go.dev/blog"} {
+				updated, err := s.UpdateOriginalURL(ctx, "user", "printed", target)
+				if err != nil {
+					t.Fatalf("UpdateOriginalURL failed: %v", err)
+				}
+				if updated.OriginalURL != target || updated.ShortURL != "printed" || !updated.CreatedAt.Equal(base) {
+					t.Errorf("Unexpected updated record: %+v", updated)
+				}
+			}
+
+			if got, _ := s.GetOriginalURL(ctx, "printed"); got != "https://go.dev/blog" {
+				t.Errorf("Expected redirect to the new destination, got %q", got)
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{Domain: "go.dev"}); len(urls) != 1 {
+				t.Errorf("Expected domain filter to follow the new destination, got %+v", urls)
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{Domain: "example.com"}); len(urls) != 0 {
+				t.Errorf("Expected old domain to be forgotten, got %+v", urls)
+			}
+			if hits, _ := s.SearchUserURLs(ctx, "user", storage.SearchOptions{Query: "blog"}); len(hits) != 1 {
+				t.Errorf("Expected search to follow the new destination, got %+v", hits)
+			}
+
+			history, err := s.GetURLHistory(ctx, "user", "printed")
+			if err != nil {
+				t.Fatalf("GetURLHistory failed: %v", err)
+			}
+			if len(history) != 2 {
+				t.Fatalf("Expected 2 history entries, got %+v", history)
+			}
+			first, second := history[0], history[1]
+			if first.OldURL != "https://old.example.com/promo" || first.NewURL != "https://new.example.org/sale" ||
+				second.OldURL != first.NewURL || second.NewURL != "https://go.dev/blog" {
+				t.Errorf("Unexpected history: %+v", history)
+			}
+			if first.ChangedBy != "user" || first.ChangedAt.IsZero() || second.ChangedAt.Before(first.ChangedAt) {
+				t.Errorf("Expected author and ordered timestamps, got %+v", history)
+			}
+
+			if _, err := s.UpdateOriginalURL(ctx, "user-2", "printed", "https://evil.example.com"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for another user, got %v", err)
+			}
+			if _, err := s.GetURLHistory(ctx, "user-2", "printed"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for another user's history, got %v", err)
+			}
+			if _, err := s.UpdateOriginalURL(ctx, "user", "missing", "https://example.com"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+		})
+	}
+}
+
+// TestUpdateOriginalURLConflict проверяет бэкенды с уникальным оригинальным
+// URL: занятый другой ссылкой адрес не назначается.
+func TestUpdateOriginalURLConflict(t *testing.T) {
+	ctx := context.Background()
+	stores := openListableStores(t)
+	for _, name := range []string{"bolt", "sqlite"} {
+		s := stores[name]
+		t.Run(name, func(t *testing.T) {
+			for _, pair := range []storage.URLPair{
+				{ShortURL: "first", OriginalURL: "https://example.com/a", UserID: "user"},
+				{ShortURL: "second", OriginalURL: "https://example.com/b", UserID: "user-2"},
+			} {
+				if err := s.ImportURL(ctx, pair, false); err != nil {
+					t.Fatalf("ImportURL failed: %v", err)
+				}
+			}
+
+			_, err := s.UpdateOriginalURL(ctx, "user", "first", "https://example.com/b")
+			var conflict *storage.ErrConflict
+			if !errors.As(err, &conflict) || conflict.ExistingShortID != "second" {
+				t.Fatalf("Expected conflict with second, got %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "first"); got != "https://example.com/a" {
+				t.Errorf("Expected destination to stay unchanged, got %q", got)
+			}
+			if history, _ := s.GetURLHistory(ctx, "user", "first"); len(history) != 0 {
+				t.Errorf("Expected no history for a rejected change, got %+v", history)
+			}
+
+			// Освободившийся адрес можно занять.
+			if _, err := s.UpdateOriginalURL(ctx, "user", "first", "https://example.com/c"); err != nil {
+				t.Fatalf("UpdateOriginalURL failed: %v", err)
+			}
+			if _, err := s.UpdateOriginalURL(ctx, "user-2", "second", "https://example.com/a"); err != nil {
+				t.Errorf("Expected released URL to be assignable, got %v", err)
+			}
+		})
+	}
+}
+
+// TestURLHistoryPersists проверяет, что история переживает перезапуск, а у
+// файлового хранилища — и компактизацию журнала.
+func TestURLHistoryPersists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	type store interface {
+		storage.Store
+		Close() error
+	}
+	backends := []struct {
+		name string
+		open func() (store, error)
+		save func(store) error
+	}{
+		{"file", func() (store, error) { return storage.NewFileStorage(filepath.Join(dir, "urls.json")) },
+			func(s store) error { return s.(*storage.FileStorage).Compact() }},
+		{"memory", func() (store, error) {
+			return storage.NewInMemoryStorageWithSnapshot(filepath.Join(dir, "urls.snapshot"), 0)
+		}, nil},
+		{"bolt", func() (store, error) { return storage.NewBoltStorage(filepath.Join(dir, "urls.db")) }, nil},
+		{"sqlite", func() (store, error) { return storage.NewSQLiteStorage(filepath.Join(dir, "urls.sqlite")) }, nil},
+	}
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s, err := b.open()
+			if err != nil {
+				t.Fatalf("Failed to open storage: %v", err)
+			}
+			shortID, err := s.CreateShortURL(ctx, "user", "https://example.com/"+b.name)
+			if err != nil {
+				t.Fatalf("CreateShortURL failed: %v", err)
+			}
+			if _, err := s.UpdateOriginalURL(ctx, "user", shortID, "https://example.org/"+b.name); err != nil {
+				t.Fatalf("UpdateOriginalURL failed: %v", err)
+			}
+			if b.save != nil {
+				if err := b.save(s); err != nil {
+					t.Fatalf("Failed to save storage: %v", err)
+				}
+			}
+			// Последующее изменение метаданных не затирает историю.
+			folder := "moved"
+			if _, err := s.UpdateURLMetadata(ctx, "user", shortID, storage.MetadataPatch{Folder: &folder}); err != nil {
+				t.Fatalf("UpdateURLMetadata failed: %v", err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			s, err = b.open()
+			if err != nil {
+				t.Fatalf("Failed to reopen storage: %v", err)
+			}
+			defer func() { _ = s.Close() }()
+			history, err := s.GetURLHistory(ctx, "user", shortID)
+			if err != nil || len(history) != 1 || history[0].NewURL != "https://example.org/"+b.name {
+				t.Errorf("Expected history after reopen, got %+v, %v", history, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import "time"
+
+// LinkHealth — результат проверок доступности адреса назначения ссылки,
+// которые выполняет пакет linkcheck. Нулевое значение означает, что ссылка
+// еще не проверялась.
+type LinkHealth struct {
+	Status    int       `json:"status,omitempty"` // HTTP-статус ответа; 0 — ответа не было
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+	Failures  int       `json:"failures,omitempty"`  // неудачных проверок подряд
+	DeadSince time.Time `json:"dead_since,omitzero"` // с какой проверки адрес считается недоступным
+}
+
+// IsZero сообщает, что ссылка не проверялась.
+func (h LinkHealth) IsZero() bool {
+	return h == LinkHealth{}
+}
+
+// Dead сообщает, что адрес назначения признан недоступным.
+func (h LinkHealth) Dead() bool {
+	return !h.DeadSince.IsZero()
+}
+
+// RedirectURL возвращает адрес для перенаправления: резервный адрес владельца,
+// если адрес назначения признан недоступным, иначе оригинальный.
+func (p URLPair) RedirectURL() string {
+	if p.Health.Dead() && p.FallbackURL != "" {
+		return p.FallbackURL
+	}
+	return p.OriginalURL
+}
@@ -0,0 +1,77 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"shorturl/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestSetURLHealth(t *testing.T) {
+	ctx := context.Background()
+	const fallback = "https://example.com/archive"
+	pair := storage.URLPair{UUID: "uuid-1", ShortURL: "abc", OriginalURL: "https://example.com/page", UserID: "user",
+		Metadata: storage.Metadata{FallbackURL: fallback}}
+	checkedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	alive := storage.LinkHealth{Status: 200, LatencyMS: 42, CheckedAt: checkedAt}
+	dead := storage.LinkHealth{Error: "connection refused", CheckedAt: checkedAt, Failures: 3, DeadSince: checkedAt}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.ImportURL(ctx, pair, false); err != nil {
+				t.Fatalf("ImportURL failed: %v", err)
+			}
+			if err := s.SetURLHealth(ctx, "abc", "https://example.com/other", dead); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a stale destination, got %v", err)
+			}
+			if err := s.SetURLHealth(ctx, "missing", pair.OriginalURL, dead); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+
+			if err := s.SetURLHealth(ctx, "abc", pair.OriginalURL, alive); err != nil {
+				t.Fatalf("SetURLHealth failed: %v", err)
+			}
+			urls, err := s.ListUserURLs(ctx, "user", storage.ListOptions{})
+			if err != nil || len(urls) != 1 {
+				t.Fatalf("Expected 1 link, got %+v, %v", urls, err)
+			}
+			if got := urls[0].Health; got.Status != 200 || got.LatencyMS != 42 || !got.CheckedAt.Equal(checkedAt) || got.Dead() {
+				t.Errorf("Expected health %+v, got %+v", alive, got)
+			}
+			if urls[0].FallbackURL != fallback {
+				t.Errorf("Expected fallback %q, got %q", fallback, urls[0].FallbackURL)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "abc"); got != pair.OriginalURL {
+				t.Errorf("Expected healthy link to redirect to %q, got %q", pair.OriginalURL, got)
+			}
+
+			// Мертвая ссылка ведет на запасной адрес, пока он задан.
+			if err := s.SetURLHealth(ctx, "abc", pair.OriginalURL, dead); err != nil {
+				t.Fatalf("SetURLHealth failed: %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "abc"); got != fallback {
+				t.Errorf("Expected dead link to redirect to %q, got %q", fallback, got)
+			}
+			empty := ""
+			if _, err := s.UpdateURLMetadata(ctx, "user", "abc", storage.MetadataPatch{FallbackURL: &empty}); err != nil {
+				t.Fatalf("UpdateURLMetadata failed: %v", err)
+			}
+			if got, _ := s.GetOriginalURL(ctx, "abc"); got != pair.OriginalURL {
+				t.Errorf("Expected dead link without fallback to redirect to %q, got %q", pair.OriginalURL, got)
+			}
+
+			// Смена адреса сбрасывает результат проверки прежнего.
+			updated, err := s.UpdateOriginalURL(ctx, "user", "abc", "https://example.org/new")
+			if err != nil {
+				t.Fatalf("UpdateOriginalURL failed: %v", err)
+			}
+			if !updated.Health.IsZero() {
+				t.Errorf("Expected health to be reset, got %+v", updated.Health)
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{}); len(urls) != 1 || !urls[0].Health.IsZero() {
+				t.Errorf("Expected stored health to be reset, got %+v", urls)
+			}
+		})
+	}
+}
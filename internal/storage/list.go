@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListOptions — параметры постраничного списка ссылок пользователя. Записи
+// упорядочены по (CreatedAt, ShortURL); ссылки с неизвестным временем
+// создания считаются самыми старыми.
+type ListOptions struct {
+	Limit  int     // максимум записей; 0 — без ограничения
+	After  *Cursor // продолжить после этой записи
+	Desc   bool    // сначала новые
+	Domain string  // только ссылки на этот домен и его поддомены
+	Query  string  // только ссылки, оригинальный URL которых содержит подстроку (без учета регистра)
+	Tag    string  // только ссылки с этим тегом
+	Folder string  // только ссылки из этой папки
+}
+
+// legacyCreatedAt хранится в SQL-бэкендах вместо неизвестного времени
+// создания, чтобы колонка created_at участвовала в keyset-пагинации без NULL.
+var legacyCreatedAt = time.Unix(0, 0).UTC()
+
+// Cursor — позиция в списке: ключ сортировки последней выданной записи.
+type Cursor struct {
+	CreatedAt time.Time
+	ShortURL  string
+}
+
+// CursorOf возвращает курсор, указывающий на запись pair.
+func CursorOf(pair URLPair) Cursor {
+	return Cursor{CreatedAt: pair.CreatedAt, ShortURL: pair.ShortURL}
+}
+
+// less сообщает, предшествует ли c курсору o в порядке возрастания.
+func (c Cursor) less(o Cursor) bool {
+	if !c.CreatedAt.Equal(o.CreatedAt) {
+		return c.CreatedAt.Before(o.CreatedAt)
+	}
+	return c.ShortURL < o.ShortURL
+}
+
+func (c Cursor) equal(o Cursor) bool {
+	return c.CreatedAt.Equal(o.CreatedAt) && c.ShortURL == o.ShortURL
+}
+
+// URLDomain возвращает хост оригинального URL в нижнем регистре без порта;
+// для некорректного URL — пустую строку.
+func URLDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// matches проверяет запись по фильтрам Domain, Query, Tag и Folder.
+func (o ListOptions) matches(pair URLPair) bool {
+	if o.Domain != "" {
+		domain := URLDomain(pair.OriginalURL)
+		want := strings.ToLower(o.Domain)
+		if domain != want && !strings.HasSuffix(domain, "."+want) {
+			return false
+		}
+	}
+	if o.Query != "" && !strings.Contains(strings.ToLower(pair.OriginalURL), strings.ToLower(o.Query)) {
+		return false
+	}
+	if o.Tag != "" && !pair.hasTag(o.Tag) {
+		return false
+	}
+	if o.Folder != "" && pair.Folder != o.Folder {
+		return false
+	}
+	return true
+}
+
+// forEachPage перечисляет все записи, которые возвращает list, страницами по
+// exportBatchSize в порядке возрастания. Между страницами блокировки и
+// соединения хранилища не удерживаются.
+func forEachPage(ctx context.Context, list func(ListOptions) ([]URLPair, error), fn func(URLPair) error) error {
+	opts := ListOptions{Limit: exportBatchSize}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := list(opts)
+		if err != nil {
+			return err
+		}
+		for _, pair := range page {
+			if err := fn(pair); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportBatchSize {
+			return nil
+		}
+		last := CursorOf(page[len(page)-1])
+		opts.After = &last
+	}
+}
+
+// userIndex — вторичный индекс in-process хранилищ: ключи ссылок каждого
+// пользователя по возрастанию (CreatedAt, ShortURL). Новые ссылки, как правило,
+// добавляются в конец, поэтому вставка обходится без сдвига элементов.
+// Индекс не потокобезопасен и защищается мьютексом хранилища.
+type userIndex map[string][]Cursor
+
+// add добавляет запись в индекс.
+func (idx userIndex) add(pair URLPair) {
+	keys := idx[pair.UserID]
+	key := CursorOf(pair)
+	i := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+	if i < len(keys) && keys[i].equal(key) {
+		return
+	}
+	keys = append(keys, Cursor{})
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	idx[pair.UserID] = keys
+}
+
+// remove удаляет запись из индекса.
+func (idx userIndex) remove(pair URLPair) {
+	keys := idx[pair.UserID]
+	key := CursorOf(pair)
+	i := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+	if i == len(keys) || !keys[i].equal(key) {
+		return
+	}
+	keys = append(keys[:i], keys[i+1:]...)
+	if len(keys) == 0 {
+		delete(idx, pair.UserID)
+		return
+	}
+	idx[pair.UserID] = keys
+}
+
+// list возвращает страницу ссылок пользователя из urls по индексу.
+func (idx userIndex) list(urls map[string]URLPair, userID string, opts ListOptions) []URLPair {
+	keys := idx[userID]
+	var result []URLPair
+	visit := func(key Cursor) bool {
+		pair, ok := urls[key.ShortURL]
+		if ok && opts.matches(pair) {
+			result = append(result, pair)
+		}
+		return opts.Limit <= 0 || len(result) < opts.Limit
+	}
+
+	if !opts.Desc {
+		start := 0
+		if opts.After != nil {
+			start = sort.Search(len(keys), func(i int) bool { return opts.After.less(keys[i]) })
+		}
+		for i := start; i < len(keys); i++ {
+			if !visit(keys[i]) {
+				break
+			}
+		}
+		return result
+	}
+
+	end := len(keys)
+	if opts.After != nil {
+		end = sort.Search(len(keys), func(i int) bool { return !keys[i].less(*opts.After) })
+	}
+	for i := end - 1; i >= 0; i-- {
+		if !visit(keys[i]) {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,163 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"shorturl/internal/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listableStore — бэкенд, поддерживающий импорт, постраничный список, поиск
+// и изменение ссылок.
+type listableStore interface {
+	storage.Importer
+	storage.Searcher
+	UpdateURLMetadata(ctx context.Context, userID, shortID string, patch storage.MetadataPatch) (storage.URLPair, error)
+	UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (storage.URLPair, error)
+	GetURLHistory(ctx context.Context, userID, shortID string) ([]storage.HistoryEntry, error)
+	SetURLPreview(ctx context.Context, shortID, originalURL string, preview storage.Preview) error
+	SetURLHealth(ctx context.Context, shortID, originalURL string, health storage.LinkHealth) error
+	GetOriginalURL(ctx context.Context, shortID string) (string, error)
+	GetRedirect(ctx context.Context, shortID string) (storage.Redirect, error)
+	ConsumeClick(ctx context.Context, shortID string) error
+	ListUserURLs(ctx context.Context, userID string, opts storage.ListOptions) ([]storage.URLPair, error)
+	ForEachUserURL(ctx context.Context, userID string, fn func(storage.URLPair) error) error
+}
+
+func openListableStores(t *testing.T) map[string]listableStore {
+	t.Helper()
+	dir := t.TempDir()
+	file, err := storage.NewFileStorage(filepath.Join(dir, "urls.json"))
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	bolt, err := storage.NewBoltStorage(filepath.Join(dir, "urls.db"))
+	if err != nil {
+		t.Fatalf("Failed to open bolt storage: %v", err)
+	}
+	sqlite, err := storage.NewSQLiteStorage(filepath.Join(dir, "urls.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = file.Close()
+		_ = bolt.Close()
+		_ = sqlite.Close()
+	})
+	return map[string]listableStore{
+		"memory": storage.NewInMemoryStorage(),
+		"file":   file,
+		"bolt":   bolt,
+		"sqlite": sqlite,
+	}
+}
+
+func TestListUserURLs(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Ссылки 0..9 пользователя user: 0 и 1 без времени создания, у 4 и 5
+	// время совпадает, четные ведут на example.com, нечетные — на docs.go.dev.
+	var pairs []storage.URLPair
+	for i := 0; i < 10; i++ {
+		pair := storage.URLPair{
+			UUID:        fmt.Sprintf("uuid-%d", i),
+			ShortURL:    fmt.Sprintf("id%d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/page/%d", i),
+			UserID:      "user",
+		}
+		if i%2 == 1 {
+			pair.OriginalURL = fmt.Sprintf("https://docs.go.dev/Item_%d", i)
+		}
+		switch {
+		case i >= 5:
+			pair.CreatedAt = base.Add(time.Duration(i-1) * time.Hour)
+		case i >= 2:
+			pair.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		}
+		pairs = append(pairs, pair)
+	}
+	other := storage.URLPair{UUID: "uuid-x", ShortURL: "idx", OriginalURL: "https://example.com/other", UserID: "user-2", CreatedAt: base}
+
+	ids := func(urls []storage.URLPair) string {
+		out := make([]string, len(urls))
+		for i, u := range urls {
+			out[i] = strings.TrimPrefix(u.ShortURL, "id")
+		}
+		return strings.Join(out, ",")
+	}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Импортируем в обратном порядке, чтобы порядок вставки не совпадал с порядком списка.
+			for i := len(pairs) - 1; i >= 0; i-- {
+				if err := s.ImportURL(ctx, pairs[i], false); err != nil {
+					t.Fatalf("ImportURL failed: %v", err)
+				}
+			}
+			if err := s.ImportURL(ctx, other, false); err != nil {
+				t.Fatalf("ImportURL failed: %v", err)
+			}
+
+			tests := []struct {
+				name string
+				opts storage.ListOptions
+				want string
+			}{
+				{"all ascending", storage.ListOptions{}, "0,1,2,3,4,5,6,7,8,9"},
+				{"first page", storage.ListOptions{Limit: 3}, "0,1,2"},
+				{"after legacy", storage.ListOptions{Limit: 3, After: &storage.Cursor{ShortURL: "id1"}}, "2,3,4"},
+				{"after tie", storage.ListOptions{Limit: 2, After: &storage.Cursor{CreatedAt: base.Add(4 * time.Hour), ShortURL: "id4"}}, "5,6"},
+				{"descending", storage.ListOptions{Desc: true, Limit: 4}, "9,8,7,6"},
+				{"descending after tie", storage.ListOptions{Desc: true, Limit: 2, After: &storage.Cursor{CreatedAt: base.Add(4 * time.Hour), ShortURL: "id5"}}, "4,3"},
+				{"descending into legacy", storage.ListOptions{Desc: true, After: &storage.Cursor{CreatedAt: base.Add(2 * time.Hour), ShortURL: "id2"}}, "1,0"},
+				{"domain", storage.ListOptions{Domain: "go.dev"}, "1,3,5,7,9"},
+				{"domain exact only", storage.ListOptions{Domain: "o.dev"}, ""},
+				{"domain case", storage.ListOptions{Domain: "EXAMPLE.com", Limit: 2, Desc: true}, "8,6"},
+				{"substring", storage.ListOptions{Query: "ITEM_"}, "1,3,5,7,9"},
+				{"substring literal wildcard", storage.ListOptions{Query: "item%"}, ""},
+				{"combined", storage.ListOptions{Domain: "go.dev", Query: "_7"}, "7"},
+			}
+			for _, tt := range tests {
+				urls, err := s.ListUserURLs(ctx, "user", tt.opts)
+				if err != nil {
+					t.Fatalf("%s: ListUserURLs failed: %v", tt.name, err)
+				}
+				if got := ids(urls); got != tt.want {
+					t.Errorf("%s: expected [%s], got [%s]", tt.name, tt.want, got)
+				}
+			}
+
+			urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{Limit: 1})
+			if len(urls) != 1 || !reflect.DeepEqual(urls[0], pairs[0]) {
+				t.Errorf("Expected record to round-trip, got %+v", urls)
+			}
+
+			var streamed []storage.URLPair
+			_ = s.ForEachUserURL(ctx, "user", func(pair storage.URLPair) error {
+				streamed = append(streamed, pair)
+				return nil
+			})
+			if got := ids(streamed); got != "0,1,2,3,4,5,6,7,8,9" {
+				t.Errorf("ForEachUserURL: expected creation order, got [%s]", got)
+			}
+
+			// Перезапись меняет владельца и время, индекс должен это отразить.
+			moved := pairs[9]
+			moved.UserID, moved.CreatedAt = "user-2", base.Add(-time.Hour)
+			if err := s.ImportURL(ctx, moved, true); err != nil {
+				t.Fatalf("ImportURL with overwrite failed: %v", err)
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user-2", storage.ListOptions{}); ids(urls) != "9,x" {
+				t.Errorf("Expected moved record in user-2 list, got [%s]", ids(urls))
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{Desc: true, Limit: 1}); ids(urls) != "8" {
+				t.Errorf("Expected moved record to leave user list, got [%s]", ids(urls))
+			}
+		})
+	}
+}
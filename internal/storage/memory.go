@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"slices"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register(BackendMemory, FactoryFunc(func(cfg *config.Config) (Store, error) {
+		if cfg.MemorySnapshotPath == "" {
+			return NewInMemoryStorage(), nil
+		}
+		return NewInMemoryStorageWithSnapshot(cfg.MemorySnapshotPath, cfg.MemorySnapshotInterval)
+	}))
+}
+
+// InMemoryStorage представляет собой реализацию хранилища в памяти.
+type InMemoryStorage struct {
+	mu     sync.RWMutex
+	urls   map[string]URLPair
+	byUser userIndex
+	search searchIndex
+	// history — история смены адресов по короткому ID.
+	history map[string][]HistoryEntry
+
+	// version растет при каждом изменении, savedVersion — версия последнего
+	// снимка: по ним периодическое сохранение пропускает неизмененные данные.
+	version      uint64
+	savedVersion uint64
+
+	snapshotPath     string
+	snapshotInterval time.Duration
+	done             chan struct{}
+	wg               sync.WaitGroup
+	closeOnce        sync.Once
+	closeErr         error
+}
+
+// NewInMemoryStorage создает и возвращает новый экземпляр InMemoryStorage.
+func NewInMemoryStorage() *InMemoryStorage {
+	return &InMemoryStorage{
+		urls:    make(map[string]URLPair),
+		byUser:  make(userIndex),
+		search:  make(searchIndex),
+		history: make(map[string][]HistoryEntry),
+		done:    make(chan struct{}),
+	}
+}
+
+// NewInMemoryStorageWithSnapshot создает хранилище, восстановленное из снимка
+// path. Снимок сохраняется раз в interval (0 — только при закрытии) и при Close.
+func NewInMemoryStorageWithSnapshot(path string, interval time.Duration) (*InMemoryStorage, error) {
+	s := NewInMemoryStorage()
+	s.snapshotPath = path
+	s.snapshotInterval = interval
+
+	if err := s.LoadFromFile(path); err != nil {
+		return nil, err
+	}
+	logger.Logger.Info("Restored in-memory storage from snapshot",
+		zap.String("path", path), zap.Int("records", len(s.urls)))
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.snapshotLoop()
+	}
+	return s, nil
+}
+
+func (s *InMemoryStorage) CreateShortURL(ctx context.Context, userID, originalURL string) (string, error) {
+	return s.CreateURL(ctx, URLPair{UserID: userID, OriginalURL: originalURL})
+}
+
+// CreateURL сохраняет ссылку по образцу pair, назначая ей короткий ID и время создания.
+func (s *InMemoryStorage) CreateURL(_ context.Context, pair URLPair) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pair.ShortURL = generateShortID()
+	pair.CreatedAt = timeNow()
+	s.put(pair)
+	return pair.ShortURL, nil
+}
+
+// UpdateURLMetadata изменяет метаданные ссылки shortID пользователя userID.
+func (s *InMemoryStorage) UpdateURLMetadata(_ context.Context, userID, shortID string, patch MetadataPatch) (URLPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pair, ok := s.urls[shortID]
+	if !ok || pair.UserID != userID {
+		return URLPair{}, ErrNotFound
+	}
+	pair.Metadata = patch.Apply(pair.Metadata)
+	if pair.emptyWindow() {
+		return URLPair{}, ErrEmptyWindow
+	}
+	s.put(pair)
+	return pair, nil
+}
+
+// UpdateOriginalURL меняет адрес назначения ссылки shortID пользователя userID
+// и дописывает смену в историю. Уникальность проверяется только по короткому
+// ID, как и при создании ссылок.
+func (s *InMemoryStorage) UpdateOriginalURL(_ context.Context, userID, shortID, originalURL string) (URLPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pair, ok := s.urls[shortID]
+	if !ok || pair.UserID != userID {
+		return URLPair{}, ErrNotFound
+	}
+	if pair.OriginalURL == originalURL {
+		return pair, nil
+	}
+	pair, entry := retarget(pair, userID, originalURL)
+	s.put(pair)
+	s.history[shortID] = append(s.history[shortID], entry)
+	return pair, nil
+}
+
+// GetURLHistory возвращает историю смены адресов ссылки shortID пользователя
+// userID от старых изменений к новым.
+func (s *InMemoryStorage) GetURLHistory(_ context.Context, userID, shortID string) ([]HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if pair, ok := s.urls[shortID]; !ok || pair.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(s.history[shortID]), nil
+}
+
+// SetURLPreview сохраняет сведения о странице ссылки shortID, если она все еще
+// ведет на originalURL.
+func (s *InMemoryStorage) SetURLPreview(_ context.Context, shortID, originalURL string, preview Preview) error {
+	return s.updateDestination(shortID, originalURL, func(pair *URLPair) { pair.Preview = preview })
+}
+
+// SetURLHealth сохраняет результат проверки ссылки shortID, если она все еще
+// ведет на originalURL.
+func (s *InMemoryStorage) SetURLHealth(_ context.Context, shortID, originalURL string, health LinkHealth) error {
+	return s.updateDestination(shortID, originalURL, func(pair *URLPair) { pair.Health = health })
+}
+
+// updateDestination изменяет ссылку shortID функцией fn, если ссылка ведет на
+// originalURL: результаты фоновых загрузок не должны попасть в ссылку, адрес
+// которой успели сменить.
+func (s *InMemoryStorage) updateDestination(shortID, originalURL string, fn func(*URLPair)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pair, ok := s.urls[shortID]
+	if !ok || pair.OriginalURL != originalURL {
+		return ErrNotFound
+	}
+	fn(&pair)
+	s.put(pair)
+	return nil
+}
+
+// put сохраняет запись и обновляет индексы, заменяя прежнюю версию записи.
+// Вызывается под s.mu.
+func (s *InMemoryStorage) put(pair URLPair) {
+	if old, ok := s.urls[pair.ShortURL]; ok {
+		s.byUser.remove(old)
+		s.search.remove(old)
+	}
+	s.urls[pair.ShortURL] = pair
+	s.byUser.add(pair)
+	s.search.add(pair)
+	s.version++
+}
+
+func (s *InMemoryStorage) GetOriginalURL(_ context.Context, shortID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pair, ok := s.urls[shortID]
+	if !ok {
+		return "", nil
+	}
+	return pair.RedirectURL(), nil
+}
+
+// GetRedirect возвращает сведения для перехода по ссылке shortID.
+func (s *InMemoryStorage) GetRedirect(_ context.Context, shortID string) (Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pair, ok := s.urls[shortID]
+	if !ok {
+		return Redirect{}, ErrNotFound
+	}
+	return redirectOf(pair), nil
+}
+
+// ConsumeClick списывает переход по ссылке shortID под s.mu. Индексы от числа
+// переходов не зависят, поэтому запись заменяется без put.
+func (s *InMemoryStorage) ConsumeClick(_ context.Context, shortID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pair, ok := s.urls[shortID]
+	if !ok {
+		return ErrNotFound
+	}
+	changed, err := consumeClick(&pair)
+	if changed {
+		s.urls[shortID] = pair
+		s.version++
+	}
+	return err
+}
+
+func (s *InMemoryStorage) GetURLsByUserID(ctx context.Context, userID string) ([]URLPair, error) {
+	return s.ListUserURLs(ctx, userID, ListOptions{})
+}
+
+// Close останавливает периодические снимки и сохраняет финальный.
+func (s *InMemoryStorage) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		if s.snapshotPath != "" {
+			s.closeErr = s.saveIfChanged()
+		}
+	})
+	return s.closeErr
+}
+
+// snapshotLoop сохраняет снимок раз в snapshotInterval, если данные менялись.
+func (s *InMemoryStorage) snapshotLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.saveIfChanged(); err != nil {
+				logger.Logger.Error("Failed to save in-memory storage snapshot",
+					zap.String("path", s.snapshotPath), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *InMemoryStorage) saveIfChanged() error {
+	s.mu.RLock()
+	changed := s.version != s.savedVersion
+	s.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return s.SaveToFile(s.snapshotPath)
+}
+
+// ForEachURL реализует Exporter.
+func (s *InMemoryStorage) ForEachURL(ctx context.Context, after string, fn func(URLPair) error) error {
+	return forEachSorted(ctx, &s.mu, &s.urls, after, fn)
+}
+
+// ForEachUserURL перечисляет ссылки пользователя по возрастанию времени создания.
+func (s *InMemoryStorage) ForEachUserURL(ctx context.Context, userID string, fn func(URLPair) error) error {
+	return forEachPage(ctx, func(opts ListOptions) ([]URLPair, error) {
+		return s.ListUserURLs(ctx, userID, opts)
+	}, fn)
+}
+
+// ListUserURLs возвращает страницу ссылок пользователя по индексу byUser.
+func (s *InMemoryStorage) ListUserURLs(_ context.Context, userID string, opts ListOptions) ([]URLPair, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byUser.list(s.urls, userID, opts), nil
+}
+
+// SearchUserURLs реализует Searcher по инвертированному индексу search.
+func (s *InMemoryStorage) SearchUserURLs(_ context.Context, userID string, opts SearchOptions) ([]SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.search.search(s.urls, userID, opts), nil
+}
+
+// ImportURL реализует Importer. Уникальность проверяется только по короткому ID,
+// как и при создании ссылок.
+func (s *InMemoryStorage) ImportURL(_ context.Context, pair URLPair, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.urls[pair.ShortURL]; ok && !overwrite {
+		return NewErrConflict(pair.ShortURL)
+	}
+	s.put(pair)
+	return nil
+}
@@ -0,0 +1,426 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Формат снимка InMemoryStorage: заголовок snapshotMagic, байт версии и
+// gzip-поток с числом записей (uvarint) и самими записями. Запись — поля в
+// фиксированном порядке: строки с префиксом длины (uvarint), целые как varint,
+// время в формате time.MarshalBinary с префиксом длины (пустое — нулевое
+// время). В версии 1 запись состоит из UUID, ShortURL, OriginalURL и UserID;
+// версия 3 дописывает после них остальные поля ссылки и историю адресов (см.
+// writeSnapshotRecord). Новое поле записи требует новой версии формата.
+// Версия 2 хранила записи в JSON; она, как и версия 1, читается для
+// совместимости. Целостность данных проверяет CRC-32 в трейлере gzip.
+const (
+	snapshotMagic   = "SURL"
+	snapshotVersion = 3
+)
+
+// snapshotVersionJSON — версия формата с записями в JSON, только для чтения.
+const snapshotVersionJSON = 2
+
+// ErrSnapshotFormat возвращается для файла, который не является снимком
+// или записан неподдерживаемой версией формата.
+var ErrSnapshotFormat = errors.New("invalid snapshot format")
+
+// snapshotRecord — запись снимка: ссылка и история смены ее адреса.
+type snapshotRecord struct {
+	URLPair
+	History []HistoryEntry `json:"history,omitempty"`
+}
+
+// maxSnapshotString ограничивает длину строки в снимке, чтобы поврежденный
+// префикс длины не приводил к выделению гигабайт памяти.
+const maxSnapshotString = 1 << 20
+
+// SaveToFile атомарно записывает содержимое хранилища в снимок filePath:
+// временный файл, fsync, переименование и fsync каталога.
+func (s *InMemoryStorage) SaveToFile(filePath string) error {
+	s.mu.RLock()
+	records := make([]snapshotRecord, 0, len(s.urls))
+	for _, pair := range s.urls {
+		records = append(records, snapshotRecord{URLPair: pair, History: s.history[pair.ShortURL]})
+	}
+	version := s.version
+	s.mu.RUnlock()
+
+	if err := writeSnapshot(filePath, records); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	s.mu.Lock()
+	if version > s.savedVersion {
+		s.savedVersion = version
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadFromFile заменяет содержимое хранилища данными снимка filePath.
+// Отсутствующий файл не является ошибкой: хранилище остается пустым.
+func (s *InMemoryStorage) LoadFromFile(filePath string) error {
+	records, err := readSnapshot(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot %s: %w", filePath, err)
+	}
+
+	urls := make(map[string]URLPair, len(records))
+	byUser := make(userIndex)
+	search := make(searchIndex)
+	history := make(map[string][]HistoryEntry)
+	for _, rec := range records {
+		urls[rec.ShortURL] = rec.URLPair
+		byUser.add(rec.URLPair)
+		search.add(rec.URLPair)
+		if len(rec.History) > 0 {
+			history[rec.ShortURL] = rec.History
+		}
+	}
+	s.mu.Lock()
+	s.urls = urls
+	s.byUser = byUser
+	s.search = search
+	s.history = history
+	s.savedVersion = s.version
+	s.mu.Unlock()
+	return nil
+}
+
+func writeSnapshot(filePath string, records []snapshotRecord) error {
+	dir := filepath.Dir(filePath)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	if _, err := w.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	if err := w.WriteByte(snapshotVersion); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	zw.Name = filepath.Base(filePath)
+	bw := bufio.NewWriter(zw)
+	sw := &snapshotWriter{w: bw}
+	sw.uvarint(uint64(len(records)))
+	for _, rec := range records {
+		writeSnapshotRecord(sw, rec)
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+	// Ошибки записи в bufio.Writer «залипают» и возвращаются из Flush.
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
+	committed = true
+	return syncDir(dir)
+}
+
+func readSnapshot(filePath string) ([]snapshotRecord, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: short header", ErrSnapshotFormat)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrSnapshotFormat)
+	}
+	version := header[len(snapshotMagic)]
+	if version < 1 || version > snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrSnapshotFormat, version)
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(zr)
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]snapshotRecord, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		rec, err := readSnapshotRecord(br, version)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		records = append(records, rec)
+	}
+	// Дочитываем поток до конца, чтобы gzip сверил CRC-32 и длину.
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, err
+	}
+	if err := zr.Close(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func writeSnapshotRecord(w *snapshotWriter, rec snapshotRecord) {
+	w.string(rec.UUID)
+	w.string(rec.ShortURL)
+	w.string(rec.OriginalURL)
+	w.string(rec.UserID)
+
+	w.time(rec.CreatedAt)
+	w.string(rec.PasswordHash)
+	w.varint(int64(rec.MaxClicks))
+	w.varint(int64(rec.ClicksLeft))
+
+	w.string(rec.Title)
+	w.uvarint(uint64(len(rec.Tags)))
+	for _, tag := range rec.Tags {
+		w.string(tag)
+	}
+	w.string(rec.Notes)
+	w.string(rec.Folder)
+	w.string(rec.FallbackURL)
+	w.time(rec.NotBefore)
+	w.time(rec.NotAfter)
+
+	w.string(rec.Preview.Title)
+	w.string(rec.Preview.Description)
+	w.string(rec.Preview.FaviconURL)
+	w.time(rec.Preview.FetchedAt)
+
+	w.varint(int64(rec.Health.Status))
+	w.string(rec.Health.Error)
+	w.varint(rec.Health.LatencyMS)
+	w.time(rec.Health.CheckedAt)
+	w.varint(int64(rec.Health.Failures))
+	w.time(rec.Health.DeadSince)
+
+	w.uvarint(uint64(len(rec.History)))
+	for _, h := range rec.History {
+		w.time(h.ChangedAt)
+		w.string(h.ChangedBy)
+		w.string(h.OldURL)
+		w.string(h.NewURL)
+	}
+}
+
+func readSnapshotRecord(br *bufio.Reader, version byte) (snapshotRecord, error) {
+	var rec snapshotRecord
+	if version == snapshotVersionJSON {
+		data, err := readSnapshotString(br)
+		if err != nil {
+			return snapshotRecord{}, err
+		}
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return snapshotRecord{}, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+		}
+		return rec, nil
+	}
+
+	r := &snapshotReader{r: br}
+	rec.UUID = r.string()
+	rec.ShortURL = r.string()
+	rec.OriginalURL = r.string()
+	rec.UserID = r.string()
+	if version == 1 {
+		return rec, r.err
+	}
+
+	rec.CreatedAt = r.time()
+	rec.PasswordHash = r.string()
+	rec.MaxClicks = int(r.varint())
+	rec.ClicksLeft = int(r.varint())
+
+	rec.Title = r.string()
+	if n := r.uvarint(); n > 0 {
+		rec.Tags = make([]string, 0, min(n, 64))
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			rec.Tags = append(rec.Tags, r.string())
+		}
+	}
+	rec.Notes = r.string()
+	rec.Folder = r.string()
+	rec.FallbackURL = r.string()
+	rec.NotBefore = r.time()
+	rec.NotAfter = r.time()
+
+	rec.Preview.Title = r.string()
+	rec.Preview.Description = r.string()
+	rec.Preview.FaviconURL = r.string()
+	rec.Preview.FetchedAt = r.time()
+
+	rec.Health.Status = int(r.varint())
+	rec.Health.Error = r.string()
+	rec.Health.LatencyMS = r.varint()
+	rec.Health.CheckedAt = r.time()
+	rec.Health.Failures = int(r.varint())
+	rec.Health.DeadSince = r.time()
+
+	if n := r.uvarint(); n > 0 {
+		rec.History = make([]HistoryEntry, 0, min(n, 64))
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			rec.History = append(rec.History, HistoryEntry{
+				ChangedAt: r.time(),
+				ChangedBy: r.string(),
+				OldURL:    r.string(),
+				NewURL:    r.string(),
+			})
+		}
+	}
+	if r.err != nil {
+		return snapshotRecord{}, r.err
+	}
+	return rec, nil
+}
+
+// snapshotWriter пишет поля записи снимка. Первая ошибка запоминается, и
+// последующие поля уже не пишутся.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	w.write(buf[:n])
+}
+
+func (w *snapshotWriter) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	w.write(buf[:n])
+}
+
+func (w *snapshotWriter) string(v string) {
+	w.uvarint(uint64(len(v)))
+	if w.err == nil {
+		_, w.err = w.w.WriteString(v)
+	}
+}
+
+func (w *snapshotWriter) time(t time.Time) {
+	if w.err != nil {
+		return
+	}
+	if t.IsZero() {
+		w.uvarint(0)
+		return
+	}
+	data, err := t.MarshalBinary()
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.uvarint(uint64(len(data)))
+	w.write(data)
+}
+
+func (w *snapshotWriter) write(p []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(p)
+	}
+}
+
+// snapshotReader читает поля записи снимка. После первой ошибки остальные
+// поля возвращают нулевые значения, а ошибка остается в err.
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var v uint64
+	v, r.err = binary.ReadUvarint(r.r)
+	return v
+}
+
+func (r *snapshotReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	var v int64
+	v, r.err = binary.ReadVarint(r.r)
+	return v
+}
+
+func (r *snapshotReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	var v string
+	v, r.err = readSnapshotString(r.r)
+	return v
+}
+
+func (r *snapshotReader) time() time.Time {
+	data := r.string()
+	if r.err != nil || data == "" {
+		return time.Time{}
+	}
+	var t time.Time
+	if err := t.UnmarshalBinary([]byte(data)); err != nil {
+		r.err = fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+	return t
+}
+
+func readSnapshotString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > maxSnapshotString {
+		return "", fmt.Errorf("%w: string of %d bytes", ErrSnapshotFormat, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
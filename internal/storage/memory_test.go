@@ -0,0 +1,213 @@
+package storage_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"shorturl/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestInMemoryStorageSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.snap")
+
+	s, err := storage.NewInMemoryStorageWithSnapshot(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open storage without snapshot: %v", err)
+	}
+	first, _ := s.CreateShortURL(ctx, "user-1", "https://example.com/a")
+	_, _ = s.CreateShortURL(ctx, "user-1", "https://example.com/b")
+	_, _ = s.CreateShortURL(ctx, "", "https://example.com/anonymous")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err = storage.NewInMemoryStorageWithSnapshot(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to restore storage: %v", err)
+	}
+	if original, _ := s.GetOriginalURL(ctx, first); original != "https://example.com/a" {
+		t.Errorf("Expected restored URL, got %q", original)
+	}
+	urls, _ := s.GetURLsByUserID(ctx, "user-1")
+	if len(urls) != 2 {
+		t.Errorf("Expected 2 restored URLs for user-1, got %d", len(urls))
+	}
+	for _, u := range urls {
+		if u.CreatedAt.IsZero() {
+			t.Errorf("Expected created_at to survive the snapshot, got %+v", u)
+		}
+	}
+
+	// Без изменений снимок не перезаписывается.
+	info, _ := os.Stat(path)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if after, _ := os.Stat(path); !after.ModTime().Equal(info.ModTime()) {
+		t.Errorf("Expected unchanged snapshot to be left as is")
+	}
+}
+
+func TestInMemoryStorageSnapshotFields(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.snap")
+	at := time.Date(2026, 5, 1, 12, 30, 0, 123000, time.UTC)
+
+	s := storage.NewInMemoryStorage()
+	pair := storage.URLPair{
+		UUID:         "uuid-1",
+		ShortURL:     "full",
+		OriginalURL:  "https://example.com/old",
+		UserID:       "user-1",
+		CreatedAt:    at,
+		PasswordHash: "$2a$10$hash",
+		MaxClicks:    5,
+		ClicksLeft:   3,
+		Metadata: storage.Metadata{
+			Title:       "Title",
+			Tags:        []string{"go", "news"},
+			Notes:       "notes",
+			Folder:      "work",
+			FallbackURL: "https://example.com/fallback",
+			NotBefore:   at,
+			NotAfter:    at.Add(time.Hour),
+		},
+		Preview: storage.Preview{Title: "Page", Description: "About", FaviconURL: "https://example.com/favicon.ico", FetchedAt: at},
+		Health:  storage.LinkHealth{Status: 503, Error: "unavailable", LatencyMS: 42, CheckedAt: at, Failures: 2, DeadSince: at},
+	}
+	if err := s.ImportURL(ctx, pair, false); err != nil {
+		t.Fatalf("ImportURL failed: %v", err)
+	}
+	if _, err := s.UpdateOriginalURL(ctx, "user-1", "full", "https://example.com/new"); err != nil {
+		t.Fatalf("UpdateOriginalURL failed: %v", err)
+	}
+	want, _ := s.GetURLsByUserID(ctx, "user-1")
+	wantHistory, _ := s.GetURLHistory(ctx, "user-1", "full")
+	if err := s.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	restored := storage.NewInMemoryStorage()
+	if err := restored.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	got, _ := restored.GetURLsByUserID(ctx, "user-1")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected restored links %+v, got %+v", want, got)
+	}
+	gotHistory, _ := restored.GetURLHistory(ctx, "user-1", "full")
+	if len(wantHistory) != 1 || !reflect.DeepEqual(gotHistory, wantHistory) {
+		t.Errorf("Expected restored history %+v, got %+v", wantHistory, gotHistory)
+	}
+}
+
+func TestInMemoryStorageSnapshotJSONVersion(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.snap")
+
+	// Снимок версии 2: записи в JSON с префиксом длины.
+	record, _ := json.Marshal(map[string]any{
+		"short_url":    "legacy",
+		"original_url": "https://example.com/legacy",
+		"user_id":      "user-1",
+		"max_clicks":   2,
+		"clicks_left":  1,
+		"history":      []map[string]any{{"old_url": "https://example.com/before", "new_url": "https://example.com/legacy"}},
+	})
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	_, _ = zw.Write(binary.AppendUvarint(nil, 1))
+	_, _ = zw.Write(binary.AppendUvarint(nil, uint64(len(record))))
+	_, _ = zw.Write(record)
+	_ = zw.Close()
+	if err := os.WriteFile(path, append([]byte("SURL\x02"), body.Bytes()...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := storage.NewInMemoryStorage()
+	if err := s.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	urls, _ := s.GetURLsByUserID(ctx, "user-1")
+	if len(urls) != 1 || urls[0].OriginalURL != "https://example.com/legacy" || urls[0].ClicksLeft != 1 {
+		t.Errorf("Expected legacy link to be restored, got %+v", urls)
+	}
+	if history, _ := s.GetURLHistory(ctx, "user-1", "legacy"); len(history) != 1 {
+		t.Errorf("Expected legacy history to be restored, got %+v", history)
+	}
+}
+
+func TestInMemoryStorageSnapshotErrors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urls.snap")
+
+	s := storage.NewInMemoryStorage()
+	_, _ = s.CreateShortURL(ctx, "user-1", "https://example.com/a")
+	if err := s.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Not a snapshot", []byte(`{"short_url":"abc"}`)},
+		{"Unknown version", append([]byte("SURL\x09"), data[5:]...)},
+		{"Truncated", data[:len(data)-6]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := filepath.Join(dir, "bad.snap")
+			if err := os.WriteFile(bad, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := storage.NewInMemoryStorage().LoadFromFile(bad); err == nil {
+				t.Errorf("Expected error loading corrupt snapshot")
+			}
+		})
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-10] ^= 0xff
+	_ = os.WriteFile(path, corrupt, 0644)
+	if err := storage.NewInMemoryStorage().LoadFromFile(path); err == nil {
+		t.Errorf("Expected checksum error for flipped byte")
+	}
+
+	_ = os.WriteFile(path, []byte("XXXX\x01"), 0644)
+	if err := storage.NewInMemoryStorage().LoadFromFile(path); !errors.Is(err, storage.ErrSnapshotFormat) {
+		t.Errorf("Expected ErrSnapshotFormat, got %v", err)
+	}
+}
+
+func TestInMemoryStorageSnapshotInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.snap")
+	s, err := storage.NewInMemoryStorageWithSnapshot(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	_, _ = s.CreateShortURL(context.Background(), "user-1", "https://example.com/a")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected periodic snapshot to be written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotFound возвращается при изменении ссылки, которой нет или которая
+// принадлежит другому пользователю.
+var ErrNotFound = errors.New("url not found")
+
+// ErrEmptyWindow возвращается при изменении метаданных, после которого окно
+// действия ссылки пусто: NotAfter не позже NotBefore. Проверяется итоговое
+// окно, поэтому ловится и изменение только одной из границ.
+var ErrEmptyWindow = errors.New("activation window is empty")
+
+// Metadata — необязательные поля, которыми владелец описывает ссылку. Теги
+// хранятся в нижнем регистре без повторов и не содержат запятых: в SQL-бэкендах
+// они записываются одной строкой через запятую (см. encodeTags).
+type Metadata struct {
+	Title  string   `json:"title,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Notes  string   `json:"notes,omitempty"`
+	Folder string   `json:"folder,omitempty"`
+	// FallbackURL — куда перенаправлять, пока адрес назначения недоступен.
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// NotBefore и NotAfter — окно, в которое ссылка открывается: до NotBefore
+	// она еще не действует, с NotAfter — уже нет. Нулевое время снимает
+	// ограничение с этой стороны.
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+}
+
+// MetadataPatch — частичное изменение метаданных: поля со значением nil
+// остаются прежними, пустое значение очищает поле.
+type MetadataPatch struct {
+	Title       *string
+	Tags        *[]string
+	Notes       *string
+	Folder      *string
+	FallbackURL *string
+	NotBefore   *time.Time
+	NotAfter    *time.Time
+}
+
+// Apply возвращает метаданные m с примененными изменениями.
+func (p MetadataPatch) Apply(m Metadata) Metadata {
+	if p.Title != nil {
+		m.Title = *p.Title
+	}
+	if p.Tags != nil {
+		m.Tags = NormalizeTags(*p.Tags)
+	}
+	if p.Notes != nil {
+		m.Notes = *p.Notes
+	}
+	if p.Folder != nil {
+		m.Folder = *p.Folder
+	}
+	if p.FallbackURL != nil {
+		m.FallbackURL = *p.FallbackURL
+	}
+	if p.NotBefore != nil {
+		m.NotBefore = *p.NotBefore
+	}
+	if p.NotAfter != nil {
+		m.NotAfter = *p.NotAfter
+	}
+	return m
+}
+
+// emptyWindow сообщает, что обе границы окна заданы и ссылка не действует ни в
+// один момент.
+func (m Metadata) emptyWindow() bool {
+	return !m.NotBefore.IsZero() && !m.NotAfter.IsZero() && !m.NotAfter.After(m.NotBefore)
+}
+
+// NormalizeTags приводит теги к нижнему регистру, убирает пробелы по краям,
+// пустые значения и повторы. Порядок первых вхождений сохраняется.
+func NormalizeTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// hasTag сообщает, есть ли у ссылки тег tag (без учета регистра).
+func (m Metadata) hasTag(tag string) bool {
+	return slices.Contains(m.Tags, strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// encodeTags записывает теги для SQL-колонки tags в виде ",a,b,": поиск тега
+// сводится к LIKE '%,tag,%'. Пустой список хранится как NULL.
+func encodeTags(tags []string) any {
+	if len(tags) == 0 {
+		return nil
+	}
+	return "," + strings.Join(tags, ",") + ","
+}
+
+// decodeTags разбирает значение колонки tags, записанное encodeTags.
+func decodeTags(s string) []string {
+	s = strings.Trim(s, ",")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// nullString возвращает NULL для пустой строки, чтобы необязательные
+// текстовые колонки не хранили пустые значения.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"shorturl/internal/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := storage.NormalizeTags([]string{" Go ", "news", "", "GO", "Новости"})
+	want := []string{"go", "news", "новости"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestUpdateURLMetadata(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pairs := []storage.URLPair{
+		{ShortURL: "id0", OriginalURL: "https://example.com/0", Metadata: storage.Metadata{Title: "Zero", Tags: []string{"go", "news"}, Folder: "work"}},
+		{ShortURL: "id1", OriginalURL: "https://example.com/1", Metadata: storage.Metadata{Tags: []string{"news"}, Notes: "read later", Folder: "home"}},
+		{ShortURL: "id2", OriginalURL: "https://example.com/2"},
+	}
+	for i := range pairs {
+		pairs[i].UUID = "uuid-" + pairs[i].ShortURL
+		pairs[i].UserID = "user"
+		pairs[i].CreatedAt = base.Add(time.Duration(i) * time.Hour)
+	}
+
+	ids := func(urls []storage.URLPair) string {
+		out := make([]string, len(urls))
+		for i, u := range urls {
+			out[i] = strings.TrimPrefix(u.ShortURL, "id")
+		}
+		return strings.Join(out, ",")
+	}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, pair := range pairs {
+				if err := s.ImportURL(ctx, pair, false); err != nil {
+					t.Fatalf("ImportURL failed: %v", err)
+				}
+			}
+
+			filters := []struct {
+				name string
+				opts storage.ListOptions
+				want string
+			}{
+				{"tag", storage.ListOptions{Tag: "news"}, "0,1"},
+				{"tag case", storage.ListOptions{Tag: "GO"}, "0"},
+				{"tag exact only", storage.ListOptions{Tag: "new"}, ""},
+				{"tag literal wildcard", storage.ListOptions{Tag: "n%"}, ""},
+				{"folder", storage.ListOptions{Folder: "home"}, "1"},
+				{"tag and folder", storage.ListOptions{Tag: "news", Folder: "work"}, "0"},
+			}
+			for _, tt := range filters {
+				urls, err := s.ListUserURLs(ctx, "user", tt.opts)
+				if err != nil {
+					t.Fatalf("%s: ListUserURLs failed: %v", tt.name, err)
+				}
+				if got := ids(urls); got != tt.want {
+					t.Errorf("%s: expected [%s], got [%s]", tt.name, tt.want, got)
+				}
+			}
+
+			title, tags := "Gopher Gallery", []string{"Go", "pics"}
+			updated, err := s.UpdateURLMetadata(ctx, "user", "id2", storage.MetadataPatch{Title: &title, Tags: &tags})
+			if err != nil {
+				t.Fatalf("UpdateURLMetadata failed: %v", err)
+			}
+			want := pairs[2]
+			want.Metadata = storage.Metadata{Title: title, Tags: []string{"go", "pics"}}
+			if !reflect.DeepEqual(updated, want) {
+				t.Errorf("Expected %+v, got %+v", want, updated)
+			}
+
+			// Пустой список тегов очищает их, остальные поля не меняются.
+			var none []string
+			if _, err := s.UpdateURLMetadata(ctx, "user", "id0", storage.MetadataPatch{Tags: &none}); err != nil {
+				t.Fatalf("UpdateURLMetadata failed: %v", err)
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{Tag: "go"}); ids(urls) != "2" {
+				t.Errorf("Expected tag index to follow update, got [%s]", ids(urls))
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{Limit: 1}); len(urls) != 1 || urls[0].Title != "Zero" || urls[0].Tags != nil {
+				t.Errorf("Expected only tags to be cleared, got %+v", urls)
+			}
+			if hits, _ := s.SearchUserURLs(ctx, "user", storage.SearchOptions{Query: "gallery pics"}); len(hits) != 1 || hits[0].ShortURL != "id2" {
+				t.Errorf("Expected search by title and tags to find id2, got %+v", hits)
+			}
+
+			if _, err := s.UpdateURLMetadata(ctx, "user-2", "id1", storage.MetadataPatch{Title: &title}); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for another user, got %v", err)
+			}
+			if _, err := s.UpdateURLMetadata(ctx, "user", "missing", storage.MetadataPatch{Title: &title}); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStorageUpdateURLMetadataPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	s, err := storage.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	shortID, err := s.CreateURL(ctx, storage.URLPair{OriginalURL: "https://example.com", UserID: "user"})
+	if err != nil {
+		t.Fatalf("CreateURL failed: %v", err)
+	}
+	folder := "work"
+	if _, err := s.UpdateURLMetadata(ctx, "user", shortID, storage.MetadataPatch{Folder: &folder}); err != nil {
+		t.Fatalf("UpdateURLMetadata failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err = storage.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	urls, err := s.ListUserURLs(ctx, "user", storage.ListOptions{Folder: "work"})
+	if err != nil || len(urls) != 1 || urls[0].ShortURL != shortID {
+		t.Errorf("Expected updated record after reopen, got %+v, %v", urls, err)
+	}
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"shorturl/internal/logger"
+)
+
+// Значения атрибута db.system, по ним же различаются диалекты SQL.
+const (
+	dbSystemPostgres = "postgresql"
+	dbSystemSQLite   = "sqlite"
+)
+
+// migrationLockID — ключ advisory-блокировки PostgreSQL, под которой
+// экземпляры сервиса по очереди применяют миграции.
+const migrationLockID = 7262534
+
+// migration — одна версия схемы: SQL-выражения и, при необходимости, шаг на
+// Go для преобразований, которые не выразить общим для диалектов SQL;
+// apply получает диалект базы (dbSystemPostgres или dbSystemSQLite).
+type migration struct {
+	statements []string
+	apply      func(ctx context.Context, tx *sql.Tx, system string) error
+}
+
+// migrations — версии схемы. Версия N — это migrations[N-1]; номер последней
+// примененной версии хранится в schema_migrations. Выражения должны работать
+// и в PostgreSQL, и в SQLite. Первая версия идемпотентна, чтобы базы, созданные
+// до появления миграций, обновлялись без ручных действий.
+var migrations = []migration{
+	{statements: []string{
+		`CREATE TABLE IF NOT EXISTS urls (
+			short_url    TEXT PRIMARY KEY,
+			original_url TEXT NOT NULL UNIQUE,
+			user_id      TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS user_id_idx ON urls (user_id)`,
+	}},
+	{statements: []string{
+		// UUID записи, чтобы экспорт и импорт сохраняли идентификаторы.
+		`ALTER TABLE urls ADD COLUMN uuid TEXT`,
+	}},
+	{statements: []string{
+		// Время создания; у существовавших ранее записей остается NULL.
+		`ALTER TABLE urls ADD COLUMN created_at TIMESTAMP`,
+	}},
+	{
+		// Постраничный список ссылок пользователя: keyset-пагинация по
+		// (created_at, short_url) требует NOT NULL значений, поэтому неизвестное
+		// время создания заменяется на legacyCreatedAt. Домен оригинального URL
+		// хранится отдельно для фильтрации.
+		statements: []string{
+			`UPDATE urls SET created_at = '1970-01-01 00:00:00+00:00' WHERE created_at IS NULL`,
+			`ALTER TABLE urls ADD COLUMN domain TEXT`,
+			`CREATE INDEX IF NOT EXISTS urls_user_created_idx ON urls (user_id, created_at, short_url)`,
+			`DROP INDEX IF EXISTS user_id_idx`,
+		},
+		apply: func(ctx context.Context, tx *sql.Tx, _ string) error {
+			return backfillColumn(ctx, tx, "domain", URLDomain)
+		},
+	},
+	{
+		// Полнотекстовый поиск: слова ссылки (searchText) хранятся в search_text.
+		// В PostgreSQL по ним строятся GIN-индексы tsvector и триграмм.
+		statements: []string{
+			`ALTER TABLE urls ADD COLUMN search_text TEXT`,
+		},
+		apply: func(ctx context.Context, tx *sql.Tx, system string) error {
+			err := backfillColumn(ctx, tx, "search_text", func(originalURL string) string {
+				return searchText(URLPair{OriginalURL: originalURL})
+			})
+			if err != nil || system != dbSystemPostgres {
+				return err
+			}
+			return createPostgresSearchIndexes(ctx, tx)
+		},
+	},
+	{statements: []string{
+		// Метаданные ссылки; tags — строка вида ",a,b," (см. encodeTags).
+		`ALTER TABLE urls ADD COLUMN title TEXT`,
+		`ALTER TABLE urls ADD COLUMN tags TEXT`,
+		`ALTER TABLE urls ADD COLUMN notes TEXT`,
+		`ALTER TABLE urls ADD COLUMN folder TEXT`,
+		`CREATE INDEX IF NOT EXISTS urls_user_folder_idx ON urls (user_id, folder)`,
+	}},
+	{statements: []string{
+		// История смены адресов ссылок, только дополняется. seq — номер
+		// изменения в пределах ссылки: переносимая замена автоинкремента.
+		`CREATE TABLE IF NOT EXISTS url_history (
+			short_url  TEXT NOT NULL,
+			seq        INTEGER NOT NULL,
+			changed_at TIMESTAMP NOT NULL,
+			changed_by TEXT NOT NULL,
+			old_url    TEXT NOT NULL,
+			new_url    TEXT NOT NULL,
+			PRIMARY KEY (short_url, seq)
+		)`,
+	}},
+	{statements: []string{
+		// Сведения о странице назначения (см. Preview).
+		`ALTER TABLE urls ADD COLUMN preview_title TEXT`,
+		`ALTER TABLE urls ADD COLUMN preview_description TEXT`,
+		`ALTER TABLE urls ADD COLUMN preview_favicon_url TEXT`,
+		`ALTER TABLE urls ADD COLUMN preview_fetched_at TIMESTAMP`,
+	}},
+	{statements: []string{
+		// Запасной адрес и результат проверки назначения (см. LinkHealth).
+		`ALTER TABLE urls ADD COLUMN fallback_url TEXT`,
+		`ALTER TABLE urls ADD COLUMN health_status INTEGER`,
+		`ALTER TABLE urls ADD COLUMN health_error TEXT`,
+		`ALTER TABLE urls ADD COLUMN health_latency_ms BIGINT`,
+		`ALTER TABLE urls ADD COLUMN health_checked_at TIMESTAMP`,
+		`ALTER TABLE urls ADD COLUMN health_failures INTEGER`,
+		`ALTER TABLE urls ADD COLUMN health_dead_since TIMESTAMP`,
+	}},
+	{statements: []string{
+		// bcrypt-хэш пароля защищенной ссылки.
+		`ALTER TABLE urls ADD COLUMN password_hash TEXT`,
+	}},
+	{statements: []string{
+		// Ограничение числа переходов и их остаток.
+		`ALTER TABLE urls ADD COLUMN max_clicks INTEGER`,
+		`ALTER TABLE urls ADD COLUMN clicks_left INTEGER`,
+	}},
+	{statements: []string{
+		// Окно действия ссылки (см. Metadata.NotBefore).
+		`ALTER TABLE urls ADD COLUMN not_before TIMESTAMP`,
+		`ALTER TABLE urls ADD COLUMN not_after TIMESTAMP`,
+	}},
+	{
+		// Фильтры списка ссылок (см. ListUserURLs): точное совпадение домена
+		// обслуживает индекс (user_id, domain), а поиск подстрок по адресу, тегам
+		// и поддоменам в PostgreSQL — триграммные GIN-индексы.
+		statements: []string{
+			`CREATE INDEX IF NOT EXISTS urls_user_domain_idx ON urls (user_id, domain)`,
+		},
+		apply: func(ctx context.Context, tx *sql.Tx, system string) error {
+			if system != dbSystemPostgres {
+				return nil
+			}
+			return createPostgresFilterIndexes(ctx, tx)
+		},
+	},
+}
+
+// backfillColumn заполняет пустую колонку column существующих записей
+// значением, вычисленным по оригинальному URL.
+func backfillColumn(ctx context.Context, tx *sql.Tx, column string, value func(originalURL string) string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT short_url, original_url FROM urls WHERE `+column+` IS NULL`)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	for rows.Next() {
+		var shortURL, originalURL string
+		if err := rows.Scan(&shortURL, &originalURL); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		values[shortURL] = value(originalURL)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return err
+	}
+	for shortURL, v := range values {
+		if _, err := tx.ExecContext(ctx, `UPDATE urls SET `+column+` = $1 WHERE short_url = $2`, v, shortURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createPostgresSearchIndexes создает индексы полнотекстового поиска. Для
+// нечеткого поиска нужно расширение pg_trgm: если у пользователя базы нет прав
+// его установить, миграция продолжается без триграммного индекса, а поиск
+// работает только по tsvector.
+func createPostgresSearchIndexes(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS urls_search_tsv_idx ON urls USING GIN (`+searchDocument+`)`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT pg_trgm`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS pg_trgm`); err != nil {
+		logger.Logger.Warn("pg_trgm is unavailable, fuzzy search is disabled", zap.Error(err))
+		_, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT pg_trgm`)
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS urls_search_trgm_idx ON urls USING GIN (search_text gin_trgm_ops)`)
+	return err
+}
+
+// createPostgresFilterIndexes создает триграммные индексы для фильтров списка
+// по подстроке: LIKE с ведущим шаблоном не использует B-дерево. Как и в
+// createPostgresSearchIndexes, без расширения pg_trgm индексы пропускаются,
+// а фильтры просматривают ссылки пользователя по urls_user_created_idx.
+func createPostgresFilterIndexes(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT pg_trgm`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS pg_trgm`); err != nil {
+		logger.Logger.Warn("pg_trgm is unavailable, link list filters are not indexed", zap.Error(err))
+		_, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT pg_trgm`)
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE INDEX IF NOT EXISTS urls_original_url_trgm_idx ON urls USING GIN (LOWER(original_url) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS urls_tags_trgm_idx ON urls USING GIN (tags gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS urls_domain_trgm_idx ON urls USING GIN (domain gin_trgm_ops)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate применяет недостающие миграции в одной транзакции.
+func migrate(ctx context.Context, db *sql.DB, system string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Logger.Error("failed to rollback migration", zap.Error(err))
+		}
+	}()
+
+	if system == dbSystemPostgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+
+	for v := version; v < len(migrations); v++ {
+		for _, stmt := range migrations[v].statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to apply migration %d: %w", v+1, err)
+			}
+		}
+		if apply := migrations[v].apply; apply != nil {
+			if err := apply(ctx, tx, system); err != nil {
+				return fmt.Errorf("failed to apply migration %d: %w", v+1, err)
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, len(migrations)); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+	logger.Logger.Info("Migrated database schema", zap.Int("from", version), zap.Int("to", len(migrations)))
+	return nil
+}
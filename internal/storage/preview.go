@@ -0,0 +1,17 @@
+package storage
+
+import "time"
+
+// Preview — сведения о странице назначения ссылки, которые в фоне собирает
+// пакет preview. Нулевое значение означает, что страница еще не загружалась.
+type Preview struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	FaviconURL  string    `json:"favicon_url,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at,omitzero"`
+}
+
+// IsZero сообщает, что сведений о странице нет.
+func (p Preview) IsZero() bool {
+	return p == Preview{}
+}
@@ -0,0 +1,60 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"shorturl/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestSetURLPreview(t *testing.T) {
+	ctx := context.Background()
+	pair := storage.URLPair{UUID: "uuid-1", ShortURL: "abc", OriginalURL: "https://example.com/article", UserID: "user"}
+	preview := storage.Preview{
+		Title:       "Article",
+		Description: "About things",
+		FaviconURL:  "https://example.com/favicon.ico",
+		FetchedAt:   time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.ImportURL(ctx, pair, false); err != nil {
+				t.Fatalf("ImportURL failed: %v", err)
+			}
+			// Загрузка для прежнего адреса ссылки не сохраняется.
+			if err := s.SetURLPreview(ctx, "abc", "https://example.com/other", preview); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a stale destination, got %v", err)
+			}
+			if err := s.SetURLPreview(ctx, "missing", pair.OriginalURL, preview); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+			if err := s.SetURLPreview(ctx, "abc", pair.OriginalURL, preview); err != nil {
+				t.Fatalf("SetURLPreview failed: %v", err)
+			}
+
+			urls, err := s.ListUserURLs(ctx, "user", storage.ListOptions{})
+			if err != nil || len(urls) != 1 {
+				t.Fatalf("Expected 1 link, got %+v, %v", urls, err)
+			}
+			got := urls[0].Preview
+			if got.Title != preview.Title || got.Description != preview.Description ||
+				got.FaviconURL != preview.FaviconURL || !got.FetchedAt.Equal(preview.FetchedAt) {
+				t.Errorf("Expected preview %+v, got %+v", preview, got)
+			}
+
+			// Смена адреса сбрасывает сведения о прежней странице.
+			updated, err := s.UpdateOriginalURL(ctx, "user", "abc", "https://example.org/new")
+			if err != nil {
+				t.Fatalf("UpdateOriginalURL failed: %v", err)
+			}
+			if !updated.Preview.IsZero() {
+				t.Errorf("Expected preview to be reset, got %+v", updated.Preview)
+			}
+			if urls, _ := s.ListUserURLs(ctx, "user", storage.ListOptions{}); len(urls) != 1 || !urls[0].Preview.IsZero() {
+				t.Errorf("Expected stored preview to be reset, got %+v", urls)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrClicksExhausted возвращает ConsumeClick, когда у ссылки с ограничением
+// числа переходов они закончились.
+var ErrClicksExhausted = errors.New("url clicks exhausted")
+
+// Redirect — сведения, нужные для перехода по ссылке: их отдает GetRedirect
+// и кэширует cache.Storage.
+type Redirect struct {
+	URL string `json:"url"` // адрес перенаправления (URLPair.RedirectURL)
+	// PasswordHash — bcrypt-хэш пароля ссылки; пустой, если ссылка не защищена.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// Limited — число переходов ограничено, и каждый переход нужно списывать
+	// через ConsumeClick.
+	Limited bool `json:"limited,omitempty"`
+	// NotBefore и NotAfter — окно действия ссылки (см. Metadata).
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+}
+
+// Pending сообщает, что в момент now окно действия ссылки еще не началось.
+func (r Redirect) Pending(now time.Time) bool {
+	return !r.NotBefore.IsZero() && now.Before(r.NotBefore)
+}
+
+// Expired сообщает, что в момент now окно действия ссылки уже закрылось.
+func (r Redirect) Expired(now time.Time) bool {
+	return !r.NotAfter.IsZero() && !now.Before(r.NotAfter)
+}
+
+// Protected сообщает, что переход по ссылке требует пароля.
+func (r Redirect) Protected() bool {
+	return r.PasswordHash != ""
+}
+
+// redirectOf возвращает сведения для перехода по ссылке pair.
+func redirectOf(pair URLPair) Redirect {
+	return Redirect{URL: pair.RedirectURL(), PasswordHash: pair.PasswordHash, Limited: pair.MaxClicks > 0,
+		NotBefore: pair.NotBefore, NotAfter: pair.NotAfter}
+}
+
+// consumeClick списывает переход у ссылки pair в бэкендах, которые изменяют
+// запись под своей блокировкой. changed ложно, если ссылка без ограничения.
+func consumeClick(pair *URLPair) (changed bool, err error) {
+	if pair.MaxClicks <= 0 {
+		return false, nil
+	}
+	if pair.ClicksLeft <= 0 {
+		return false, ErrClicksExhausted
+	}
+	pair.ClicksLeft--
+	return true, nil
+}
@@ -0,0 +1,179 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"shorturl/internal/storage"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetRedirect(t *testing.T) {
+	ctx := context.Background()
+	const hash = "$2a$10$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyz01234"
+	protected := storage.URLPair{UUID: "uuid-1", ShortURL: "locked", OriginalURL: "https://example.com/internal",
+		UserID: "user", PasswordHash: hash, Metadata: storage.Metadata{FallbackURL: "https://example.com/archive"}}
+	public := storage.URLPair{UUID: "uuid-2", ShortURL: "open", OriginalURL: "https://example.com/public", UserID: "user"}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, pair := range []storage.URLPair{protected, public} {
+				if err := s.ImportURL(ctx, pair, false); err != nil {
+					t.Fatalf("ImportURL failed: %v", err)
+				}
+			}
+
+			got, err := s.GetRedirect(ctx, "locked")
+			if err != nil || got.URL != protected.OriginalURL || got.PasswordHash != hash || !got.Protected() {
+				t.Errorf("Expected protected redirect to %q, got %+v, %v", protected.OriginalURL, got, err)
+			}
+			if got, err := s.GetRedirect(ctx, "open"); err != nil || got.URL != public.OriginalURL || got.Protected() {
+				t.Errorf("Expected open redirect to %q, got %+v, %v", public.OriginalURL, got, err)
+			}
+			if _, err := s.GetRedirect(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+
+			// Адрес выбирается так же, как в GetOriginalURL: мертвая ссылка ведет на запасной.
+			dead := storage.LinkHealth{Failures: 3, CheckedAt: time.Now().UTC(), DeadSince: time.Now().UTC()}
+			if err := s.SetURLHealth(ctx, "locked", protected.OriginalURL, dead); err != nil {
+				t.Fatalf("SetURLHealth failed: %v", err)
+			}
+			if got, _ := s.GetRedirect(ctx, "locked"); got.URL != protected.FallbackURL || !got.Protected() {
+				t.Errorf("Expected dead protected link to redirect to %q, got %+v", protected.FallbackURL, got)
+			}
+
+			// Хэш пароля хранится вместе со ссылкой и не теряется при изменениях.
+			title := "Internal docs"
+			if _, err := s.UpdateURLMetadata(ctx, "user", "locked", storage.MetadataPatch{Title: &title}); err != nil {
+				t.Fatalf("UpdateURLMetadata failed: %v", err)
+			}
+			urls, err := s.ListUserURLs(ctx, "user", storage.ListOptions{})
+			if err != nil || len(urls) != 2 {
+				t.Fatalf("Expected 2 links, got %+v, %v", urls, err)
+			}
+			for _, pair := range urls {
+				if want := map[string]string{"locked": hash}[pair.ShortURL]; pair.PasswordHash != want {
+					t.Errorf("Expected %s password hash %q, got %q", pair.ShortURL, want, pair.PasswordHash)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeClick(t *testing.T) {
+	ctx := context.Background()
+	const maxClicks, clients = 5, 20
+	limited := storage.URLPair{UUID: "uuid-1", ShortURL: "limited", OriginalURL: "https://example.com/invite",
+		UserID: "user", MaxClicks: maxClicks, ClicksLeft: maxClicks}
+	unlimited := storage.URLPair{UUID: "uuid-2", ShortURL: "open", OriginalURL: "https://example.com/public", UserID: "user"}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, pair := range []storage.URLPair{limited, unlimited} {
+				if err := s.ImportURL(ctx, pair, false); err != nil {
+					t.Fatalf("ImportURL failed: %v", err)
+				}
+			}
+			if got, err := s.GetRedirect(ctx, "limited"); err != nil || !got.Limited {
+				t.Errorf("Expected limited redirect, got %+v, %v", got, err)
+			}
+
+			// Параллельные переходы списывают ровно maxClicks, остальные получают ErrClicksExhausted.
+			var wg sync.WaitGroup
+			errs := make(chan error, clients)
+			for range clients {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- s.ConsumeClick(ctx, "limited")
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			var consumed, exhausted int
+			for err := range errs {
+				switch {
+				case err == nil:
+					consumed++
+				case errors.Is(err, storage.ErrClicksExhausted):
+					exhausted++
+				default:
+					t.Errorf("Unexpected ConsumeClick error: %v", err)
+				}
+			}
+			if consumed != maxClicks || exhausted != clients-maxClicks {
+				t.Errorf("Expected %d consumed and %d exhausted clicks, got %d and %d",
+					maxClicks, clients-maxClicks, consumed, exhausted)
+			}
+
+			urls, err := s.ListUserURLs(ctx, "user", storage.ListOptions{})
+			if err != nil {
+				t.Fatalf("ListUserURLs failed: %v", err)
+			}
+			for _, pair := range urls {
+				if pair.ShortURL == "limited" && (pair.MaxClicks != maxClicks || pair.ClicksLeft != 0) {
+					t.Errorf("Expected exhausted link with max_clicks %d, got %+v", maxClicks, pair)
+				}
+			}
+
+			if got, err := s.GetRedirect(ctx, "open"); err != nil || got.Limited {
+				t.Errorf("Expected unlimited redirect, got %+v, %v", got, err)
+			}
+			for range 3 {
+				if err := s.ConsumeClick(ctx, "open"); err != nil {
+					t.Errorf("Expected no error for unlimited link, got %v", err)
+				}
+			}
+			if err := s.ConsumeClick(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for missing link, got %v", err)
+			}
+		})
+	}
+}
+
+func TestURLWindow(t *testing.T) {
+	ctx := context.Background()
+	notBefore := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(48 * time.Hour)
+	pair := storage.URLPair{UUID: "uuid-1", ShortURL: "launch", OriginalURL: "https://example.com/sale", UserID: "user",
+		Metadata: storage.Metadata{NotBefore: notBefore}}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.ImportURL(ctx, pair, false); err != nil {
+				t.Fatalf("ImportURL failed: %v", err)
+			}
+			got, err := s.GetRedirect(ctx, "launch")
+			if err != nil || !got.NotBefore.Equal(notBefore) || !got.NotAfter.IsZero() {
+				t.Fatalf("Expected window from %s, got %+v, %v", notBefore, got, err)
+			}
+			if !got.Pending(notBefore.Add(-time.Second)) || got.Pending(notBefore) || got.Expired(notBefore.AddDate(10, 0, 0)) {
+				t.Errorf("Unexpected window state for %+v", got)
+			}
+
+			cleared := time.Time{}
+			updated, err := s.UpdateURLMetadata(ctx, "user", "launch", storage.MetadataPatch{NotBefore: &cleared, NotAfter: &notAfter})
+			if err != nil || !updated.NotBefore.IsZero() || !updated.NotAfter.Equal(notAfter) {
+				t.Fatalf("Expected window until %s, got %+v, %v", notAfter, updated.Metadata, err)
+			}
+			got, err = s.GetRedirect(ctx, "launch")
+			if err != nil || !got.NotBefore.IsZero() || !got.NotAfter.Equal(notAfter) {
+				t.Errorf("Expected stored window until %s, got %+v, %v", notAfter, got, err)
+			}
+			if got.Expired(notAfter.Add(-time.Second)) || !got.Expired(notAfter) {
+				t.Errorf("Expected link to expire exactly at %s", notAfter)
+			}
+
+			// Итоговое окно проверяется и при изменении только одной границы.
+			late := notAfter.Add(time.Hour)
+			if _, err := s.UpdateURLMetadata(ctx, "user", "launch", storage.MetadataPatch{NotBefore: &late}); !errors.Is(err, storage.ErrEmptyWindow) {
+				t.Errorf("Expected ErrEmptyWindow for not_before after not_after, got %v", err)
+			}
+			if got, _ := s.GetRedirect(ctx, "launch"); !got.NotBefore.IsZero() || !got.NotAfter.Equal(notAfter) {
+				t.Errorf("Expected rejected update to keep the window, got %+v", got)
+			}
+		})
+	}
+}
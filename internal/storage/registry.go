@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shorturl/internal/config"
+	"sort"
+	"sync"
+)
+
+// Имена встроенных бэкендов хранилища.
+const (
+	BackendPostgres = "postgres"
+	BackendFile     = "file"
+	BackendMemory   = "memory"
+)
+
+// ErrUnknownBackend возвращается при запросе незарегистрированного бэкенда.
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+// Store — операции, которые обязан поддерживать любой бэкенд хранилища.
+type Store interface {
+	CreateShortURL(ctx context.Context, userID, originalURL string) (string, error)
+	CreateURL(ctx context.Context, pair URLPair) (string, error)
+	UpdateURLMetadata(ctx context.Context, userID, shortID string, patch MetadataPatch) (URLPair, error)
+	UpdateOriginalURL(ctx context.Context, userID, shortID, originalURL string) (URLPair, error)
+	GetURLHistory(ctx context.Context, userID, shortID string) ([]HistoryEntry, error)
+	SetURLPreview(ctx context.Context, shortID, originalURL string, preview Preview) error
+	SetURLHealth(ctx context.Context, shortID, originalURL string, health LinkHealth) error
+	// GetOriginalURL возвращает адрес перенаправления ссылки (URLPair.RedirectURL)
+	// или пустую строку, если ссылки нет.
+	GetOriginalURL(ctx context.Context, shortID string) (string, error)
+	// GetRedirect возвращает сведения для перехода по ссылке; ErrNotFound, если ссылки нет.
+	GetRedirect(ctx context.Context, shortID string) (Redirect, error)
+	// ConsumeClick атомарно списывает переход по ссылке с ограничением числа
+	// переходов: ErrClicksExhausted, если они закончились, ErrNotFound, если
+	// ссылки нет. Для ссылки без ограничения ничего не делает.
+	ConsumeClick(ctx context.Context, shortID string) error
+	GetURLsByUserID(ctx context.Context, userID string) ([]URLPair, error)
+	ForEachUserURL(ctx context.Context, userID string, fn func(URLPair) error) error
+	ListUserURLs(ctx context.Context, userID string, opts ListOptions) ([]URLPair, error)
+	Searcher
+}
+
+// Factory открывает хранилище по конфигурации.
+type Factory interface {
+	Open(cfg *config.Config) (Store, error)
+}
+
+// FactoryFunc позволяет использовать функцию в качестве Factory.
+type FactoryFunc func(cfg *config.Config) (Store, error)
+
+// Open реализует Factory.
+func (f FactoryFunc) Open(cfg *config.Config) (Store, error) {
+	return f(cfg)
+}
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// Register регистрирует фабрику бэкенда под именем name.
+// Вызывается из init() файла бэкенда; повторная регистрация имени — ошибка программиста.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("storage: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("storage: Register called twice for backend " + name)
+	}
+	factories[name] = factory
+}
+
+// Backends возвращает отсортированный список зарегистрированных бэкендов.
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open открывает бэкенд name с помощью зарегистрированной фабрики.
+func Open(name string, cfg *config.Config) (Store, error) {
+	registryMu.RLock()
+	factory, ok := factories[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownBackend, name, Backends())
+	}
+	store, err := factory.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", name, err)
+	}
+	return store, nil
+}
+
+// DefaultBackend выбирает бэкенд, если STORAGE_BACKEND не задан: sqlite при DSN
+// со схемой sqlite://, postgres при любом другом DSN, file при заданном пути к файлу, иначе memory.
+func DefaultBackend(cfg *config.Config) string {
+	switch {
+	case IsSQLiteDSN(cfg.DatabaseDSN):
+		return BackendSQLite
+	case cfg.DatabaseDSN != "":
+		return BackendPostgres
+	case cfg.FileStoragePath != "":
+		return BackendFile
+	default:
+		return BackendMemory
+	}
+}
+
+// FallbackChain — порядок бэкендов при включенном STORAGE_FALLBACK:
+// при ошибке открытия выбранного бэкенда пробуются следующие за ним.
+var FallbackChain = []string{BackendPostgres, BackendSQLite, BackendFile, BackendMemory}
@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"errors"
+	"path/filepath"
+	"shorturl/internal/config"
+	"shorturl/internal/storage"
+	"testing"
+)
+
+func TestDefaultBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected string
+	}{
+		{"DSN wins", config.Config{DatabaseDSN: "postgres://x", FileStoragePath: "/tmp/x"}, storage.BackendPostgres},
+		{"SQLite DSN", config.Config{DatabaseDSN: "sqlite:///tmp/x.sqlite"}, storage.BackendSQLite},
+		{"File path", config.Config{FileStoragePath: "/tmp/x"}, storage.BackendFile},
+		{"Nothing configured", config.Config{}, storage.BackendMemory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storage.DefaultBackend(&tt.cfg); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, name := range []string{storage.BackendPostgres, storage.BackendSQLite, storage.BackendFile, storage.BackendMemory} {
+		found := false
+		for _, registered := range storage.Backends() {
+			found = found || registered == name
+		}
+		if !found {
+			t.Errorf("Backend %s is not registered", name)
+		}
+	}
+
+	if _, err := storage.Open("nosuch", &config.Config{}); !errors.Is(err, storage.ErrUnknownBackend) {
+		t.Errorf("Expected ErrUnknownBackend, got %v", err)
+	}
+	if _, err := storage.Open(storage.BackendPostgres, &config.Config{}); err == nil {
+		t.Errorf("Expected error opening postgres without DSN")
+	}
+	if _, err := storage.Open(storage.BackendMemory, &config.Config{}); err != nil {
+		t.Errorf("Failed to open memory storage: %v", err)
+	}
+	cfg := &config.Config{FileStoragePath: filepath.Join(t.TempDir(), "urls.json")}
+	if _, err := storage.Open(storage.BackendFile, cfg); err != nil {
+		t.Errorf("Failed to open file storage: %v", err)
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic on duplicate registration")
+		}
+	}()
+	storage.Register(storage.BackendMemory, storage.FactoryFunc(func(*config.Config) (storage.Store, error) {
+		return storage.NewInMemoryStorage(), nil
+	}))
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Searcher — полнотекстовый поиск по ссылкам пользователя.
+type Searcher interface {
+	SearchUserURLs(ctx context.Context, userID string, opts SearchOptions) ([]SearchHit, error)
+}
+
+// SearchOptions — параметры поиска. Query разбивается на термы (SearchTerms);
+// ссылка подходит, если каждый терм совпадает со словом ее URL, заголовка или
+// тегов либо является его префиксом.
+type SearchOptions struct {
+	Query  string
+	Limit  int // максимум результатов; 0 — без ограничения
+	Offset int // пропустить столько лучших результатов
+}
+
+// SearchHit — найденная ссылка и ее релевантность: чем больше Score, тем выше
+// ссылка в выдаче. Шкала Score зависит от бэкенда.
+type SearchHit struct {
+	URLPair
+	Score float64
+}
+
+// Веса совпадения терма запроса со словом ссылки.
+const (
+	searchExactWeight  = 1.0
+	searchPrefixWeight = 0.5
+)
+
+// SearchTerms разбивает строку на термы в нижнем регистре: последовательности
+// букв и цифр. Повторы удаляются, порядок сохраняется.
+func SearchTerms(s string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchWords возвращает слова ссылки, по которым ведется поиск: слова
+// оригинального URL, заголовка и тегов. Схема и префикс www. есть почти у
+// любой ссылки и в поиск не попадают; процентное кодирование раскрывается,
+// чтобы находились и закодированные слова.
+func searchWords(pair URLPair) []string {
+	fields := append([]string{pair.OriginalURL, pair.Title}, pair.Tags...)
+	if u, err := url.Parse(pair.OriginalURL); err == nil {
+		query := u.RawQuery
+		if unescaped, err := url.QueryUnescape(query); err == nil {
+			query = unescaped
+		}
+		fields[0] = strings.Join([]string{strings.TrimPrefix(strings.ToLower(u.Host), "www."), u.Path, query, u.Fragment}, " ")
+	}
+	return SearchTerms(strings.Join(fields, " "))
+}
+
+// searchText — слова ссылки одной строкой, как их хранит колонка search_text.
+func searchText(pair URLPair) string {
+	return strings.Join(searchWords(pair), " ")
+}
+
+// searchScore оценивает ссылку со словами words по термам запроса. Ссылка
+// подходит, только если совпал каждый терм; за терм засчитывается лучшее
+// совпадение: точное или по префиксу.
+func searchScore(terms, words []string) (float64, bool) {
+	var score float64
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range words {
+			switch {
+			case word == term:
+				best = searchExactWeight
+			case best < searchPrefixWeight && strings.HasPrefix(word, term):
+				best = searchPrefixWeight
+			}
+			if best == searchExactWeight {
+				break
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		score += best
+	}
+	return score, true
+}
+
+// rankHits упорядочивает результаты по убыванию Score, при равенстве — сначала
+// новые, и вырезает страницу по opts.
+func rankHits(hits []SearchHit, opts SearchOptions) []SearchHit {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return CursorOf(hits[j].URLPair).less(CursorOf(hits[i].URLPair))
+	})
+	if opts.Offset >= len(hits) {
+		return nil
+	}
+	hits = hits[opts.Offset:]
+	if opts.Limit > 0 && len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+	return hits
+}
+
+// scanSearch ищет перебором всех ссылок пользователя. Используется бэкендами
+// без поискового индекса.
+func scanSearch(forEach func(fn func(URLPair) error) error, opts SearchOptions) ([]SearchHit, error) {
+	terms := SearchTerms(opts.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	var hits []SearchHit
+	err := forEach(func(pair URLPair) error {
+		if score, ok := searchScore(terms, searchWords(pair)); ok {
+			hits = append(hits, SearchHit{URLPair: pair, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rankHits(hits, opts), nil
+}
+
+// searchIndex — инвертированный индекс in-process хранилищ: для каждого
+// пользователя слово ссылки → короткие ID ссылок с этим словом. Индекс не
+// потокобезопасен и защищается мьютексом хранилища.
+type searchIndex map[string]map[string]map[string]struct{}
+
+// add добавляет слова записи в индекс.
+func (idx searchIndex) add(pair URLPair) {
+	words := idx[pair.UserID]
+	if words == nil {
+		words = make(map[string]map[string]struct{})
+		idx[pair.UserID] = words
+	}
+	for _, word := range searchWords(pair) {
+		ids := words[word]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			words[word] = ids
+		}
+		ids[pair.ShortURL] = struct{}{}
+	}
+}
+
+// remove удаляет слова записи из индекса.
+func (idx searchIndex) remove(pair URLPair) {
+	words := idx[pair.UserID]
+	for _, word := range searchWords(pair) {
+		ids := words[word]
+		delete(ids, pair.ShortURL)
+		if len(ids) == 0 {
+			delete(words, word)
+		}
+	}
+	if len(words) == 0 {
+		delete(idx, pair.UserID)
+	}
+}
+
+// search находит ссылки пользователя из urls по индексу: кандидаты — ссылки,
+// у которых есть слово с префиксом каждого терма, они оцениваются searchScore.
+func (idx searchIndex) search(urls map[string]URLPair, userID string, opts SearchOptions) []SearchHit {
+	terms := SearchTerms(opts.Query)
+	words := idx[userID]
+	if len(terms) == 0 || len(words) == 0 {
+		return nil
+	}
+
+	var candidates map[string]struct{}
+	for _, term := range terms {
+		matched := make(map[string]struct{})
+		for word, ids := range words {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+			for id := range ids {
+				if _, ok := candidates[id]; ok || candidates == nil {
+					matched[id] = struct{}{}
+				}
+			}
+		}
+		if len(matched) == 0 {
+			return nil
+		}
+		candidates = matched
+	}
+
+	hits := make([]SearchHit, 0, len(candidates))
+	for id := range candidates {
+		pair, ok := urls[id]
+		if !ok {
+			continue
+		}
+		if score, ok := searchScore(terms, searchWords(pair)); ok {
+			hits = append(hits, SearchHit{URLPair: pair, Score: score})
+		}
+	}
+	return rankHits(hits, opts)
+}
@@ -0,0 +1,104 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"shorturl/internal/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchTerms(t *testing.T) {
+	got := storage.SearchTerms("Go.dev/Doc?q=Effective_Go  Привет-мир")
+	want := []string{"go", "dev", "doc", "q", "effective", "привет", "мир"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestSearchUserURLs(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pairs := []storage.URLPair{
+		{ShortURL: "golang", OriginalURL: "https://go.dev/doc/effective_go"},
+		{ShortURL: "godocs", OriginalURL: "https://pkg.go.dev/net/http"},
+		{ShortURL: "gopher", OriginalURL: "https://example.com/gophers/gallery"},
+		{ShortURL: "rustlg", OriginalURL: "https://doc.rust-lang.org/book/"},
+		{ShortURL: "encode", OriginalURL: "https://example.com/%D0%BA%D0%BE%D1%82"},
+		{ShortURL: "legacy", OriginalURL: "https://go.dev/blog"},
+	}
+	for i := range pairs {
+		pairs[i].UUID = fmt.Sprintf("uuid-%d", i)
+		pairs[i].UserID = "user"
+		if pairs[i].ShortURL != "legacy" {
+			pairs[i].CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		}
+	}
+	other := storage.URLPair{UUID: "uuid-x", ShortURL: "otherx", OriginalURL: "https://go.dev/other", UserID: "user-2", CreatedAt: base}
+
+	ids := func(hits []storage.SearchHit) string {
+		out := make([]string, len(hits))
+		for i, hit := range hits {
+			out[i] = hit.ShortURL
+		}
+		return strings.Join(out, ",")
+	}
+
+	for name, s := range openListableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, pair := range append(pairs, other) {
+				if err := s.ImportURL(ctx, pair, false); err != nil {
+					t.Fatalf("ImportURL failed: %v", err)
+				}
+			}
+
+			tests := []struct {
+				name string
+				opts storage.SearchOptions
+				want string
+			}{
+				// Точное совпадение «go» важнее префикса (gophers); при равной
+				// релевантности сначала новые, ссылка без времени — последней.
+				{"ranked", storage.SearchOptions{Query: "go"}, "godocs,golang,legacy,gopher"},
+				{"all terms", storage.SearchOptions{Query: "go doc"}, "golang"},
+				{"prefix", storage.SearchOptions{Query: "Effect"}, "golang"},
+				{"case and punctuation", storage.SearchOptions{Query: "NET/HTTP"}, "godocs"},
+				{"percent-encoded", storage.SearchOptions{Query: "кот"}, "encode"},
+				{"scheme ignored", storage.SearchOptions{Query: "https"}, ""},
+				{"no match", storage.SearchOptions{Query: "python"}, ""},
+				{"empty", storage.SearchOptions{Query: "  ?! "}, ""},
+				{"page", storage.SearchOptions{Query: "go", Limit: 2, Offset: 1}, "golang,legacy"},
+				{"past the end", storage.SearchOptions{Query: "go", Offset: 10}, ""},
+			}
+			for _, tt := range tests {
+				hits, err := s.SearchUserURLs(ctx, "user", tt.opts)
+				if err != nil {
+					t.Fatalf("%s: SearchUserURLs failed: %v", tt.name, err)
+				}
+				if got := ids(hits); got != tt.want {
+					t.Errorf("%s: expected [%s], got [%s]", tt.name, tt.want, got)
+				}
+			}
+
+			hits, _ := s.SearchUserURLs(ctx, "user", storage.SearchOptions{Query: "go"})
+			if len(hits) != 4 || !reflect.DeepEqual(hits[0].URLPair, pairs[1]) || hits[0].Score <= hits[3].Score {
+				t.Errorf("Expected full records ranked by score, got %+v", hits)
+			}
+
+			// Перезапись заменяет слова ссылки в индексе.
+			moved := pairs[3]
+			moved.OriginalURL = "https://www.python.org/"
+			if err := s.ImportURL(ctx, moved, true); err != nil {
+				t.Fatalf("ImportURL with overwrite failed: %v", err)
+			}
+			if hits, _ := s.SearchUserURLs(ctx, "user", storage.SearchOptions{Query: "python"}); ids(hits) != "rustlg" {
+				t.Errorf("Expected overwritten record to be found by new words, got [%s]", ids(hits))
+			}
+			if hits, _ := s.SearchUserURLs(ctx, "user", storage.SearchOptions{Query: "rust"}); len(hits) != 0 {
+				t.Errorf("Expected old words to be removed, got [%s]", ids(hits))
+			}
+		})
+	}
+}
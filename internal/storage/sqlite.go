@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"shorturl/internal/config"
+	"shorturl/internal/logger"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// BackendSQLite — встраиваемая база SQLite, путь берется из DATABASE_DSN вида sqlite://path.
+const BackendSQLite = "sqlite"
+
+const sqliteScheme = "sqlite://"
+
+func init() {
+	Register(BackendSQLite, FactoryFunc(func(cfg *config.Config) (Store, error) {
+		if !IsSQLiteDSN(cfg.DatabaseDSN) {
+			return nil, fmt.Errorf("DATABASE_DSN must have %s scheme", sqliteScheme)
+		}
+		return NewSQLiteStorage(strings.TrimPrefix(cfg.DatabaseDSN, sqliteScheme))
+	}))
+}
+
+// IsSQLiteDSN сообщает, указывает ли DSN на файл SQLite (схема sqlite://).
+func IsSQLiteDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, sqliteScheme)
+}
+
+// SQLiteStorage хранит ссылки в файле SQLite. Схема, запросы и семантика
+// ErrConflict те же, что у DatabaseStorage.
+type SQLiteStorage struct {
+	*DatabaseStorage
+}
+
+// NewSQLiteStorage открывает (или создает) базу SQLite по пути path.
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	if path == "" {
+		return nil, errors.New("sqlite path is empty")
+	}
+
+	// Время пишется в формате SQLite с явной зоной, а не через time.Time.String():
+	// строки created_at в UTC тогда сравниваются в порядке времени.
+	dsn := path + "?_time_format=sqlite"
+	if strings.Contains(path, "?") {
+		dsn = path + "&_time_format=sqlite"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite допускает только одного писателя: одно соединение избавляет
+	// от SQLITE_BUSY при конкурентных транзакциях внутри процесса.
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+		"PRAGMA busy_timeout = 5000",
+	} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to apply %q: %w", pragma, err)
+		}
+	}
+
+	if err := migrate(ctx, db, dbSystemSQLite); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	logger.Logger.Info("Successfully opened SQLite database and ensured table 'urls' exists")
+	return &SQLiteStorage{DatabaseStorage: &DatabaseStorage{db: db, system: dbSystemSQLite}}, nil
+}
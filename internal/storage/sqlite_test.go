@@ -0,0 +1,149 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"shorturl/internal/config"
+	"shorturl/internal/storage"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.sqlite")
+
+	opened, err := storage.Open(storage.BackendSQLite, &config.Config{DatabaseDSN: "sqlite://" + path})
+	if err != nil {
+		t.Fatalf("Failed to open sqlite storage: %v", err)
+	}
+	s := opened.(*storage.SQLiteStorage)
+
+	first, err := s.CreateShortURL(ctx, "user-1", "https://example.com/a")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if _, err := s.CreateShortURL(ctx, "user-1", "https://example.com/b"); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	existing, err := s.CreateShortURL(ctx, "user-2", "https://example.com/a")
+	var conflictErr *storage.ErrConflict
+	if !errors.As(err, &conflictErr) || existing != first {
+		t.Fatalf("Expected conflict with %s, got %s, %v", first, existing, err)
+	}
+
+	// Конкурентные вставки не должны упираться в блокировку базы.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := s.CreateShortURL(ctx, "user-3", "https://example.com/c/"+string(rune('a'+i))); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent CreateShortURL failed: %v", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	s, err = storage.NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen sqlite storage: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := s.PingContext(ctx); err != nil {
+		t.Errorf("PingContext failed: %v", err)
+	}
+	original, err := s.GetOriginalURL(ctx, first)
+	if err != nil || original != "https://example.com/a" {
+		t.Errorf("Expected original URL, got %q, %v", original, err)
+	}
+	if missing, err := s.GetOriginalURL(ctx, "missing1"); err != nil || missing != "" {
+		t.Errorf("Expected empty result for missing ID, got %q, %v", missing, err)
+	}
+	urls, err := s.GetURLsByUserID(ctx, "user-1")
+	if err != nil || len(urls) != 2 {
+		t.Errorf("Expected 2 URLs for user-1, got %d, %v", len(urls), err)
+	}
+	for _, u := range urls {
+		if u.CreatedAt.IsZero() || time.Since(u.CreatedAt) > time.Minute {
+			t.Errorf("Expected recent created_at, got %v", u.CreatedAt)
+		}
+	}
+	urls, err = s.GetURLsByUserID(ctx, "user-3")
+	if err != nil || len(urls) != 20 {
+		t.Errorf("Expected 20 URLs for user-3, got %d, %v", len(urls), err)
+	}
+
+	var streamed []string
+	err = s.ForEachUserURL(ctx, "user-1", func(pair storage.URLPair) error {
+		streamed = append(streamed, pair.ShortURL)
+		return nil
+	})
+	if err != nil || len(streamed) != 2 || streamed[0] != first {
+		t.Errorf("Expected 2 URLs for user-1 in creation order, got %v, %v", streamed, err)
+	}
+}
+
+func TestSQLiteDSNScheme(t *testing.T) {
+	if _, err := storage.Open(storage.BackendSQLite, &config.Config{DatabaseDSN: "postgres://x"}); err == nil {
+		t.Errorf("Expected error opening sqlite with postgres DSN")
+	}
+	if _, err := storage.Open(storage.BackendPostgres, &config.Config{DatabaseDSN: "sqlite:///tmp/x"}); err == nil {
+		t.Errorf("Expected error opening postgres with sqlite DSN")
+	}
+}
+
+func TestSQLiteStorageImportExport(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "urls.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite storage: %v", err)
+	}
+	defer s.Close()
+
+	pairs := []storage.URLPair{
+		{UUID: "u2", ShortURL: "bbb", OriginalURL: "https://example.com/b", UserID: "user-1",
+			CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)},
+		{UUID: "u1", ShortURL: "aaa", OriginalURL: "https://example.com/a"},
+	}
+	for _, pair := range pairs {
+		if err := s.ImportURL(ctx, pair, false); err != nil {
+			t.Fatalf("ImportURL failed: %v", err)
+		}
+	}
+
+	// Совпадение оригинального URL — конфликт; overwrite заменяет прежнюю запись.
+	dup := storage.URLPair{UUID: "u3", ShortURL: "ccc", OriginalURL: "https://example.com/a"}
+	var conflictErr *storage.ErrConflict
+	if err := s.ImportURL(ctx, dup, false); !errors.As(err, &conflictErr) || conflictErr.ExistingShortID != "aaa" {
+		t.Fatalf("Expected conflict with aaa, got %v", err)
+	}
+	if err := s.ImportURL(ctx, dup, true); err != nil {
+		t.Fatalf("ImportURL with overwrite failed: %v", err)
+	}
+
+	var got []storage.URLPair
+	err = s.ForEachURL(ctx, "", func(pair storage.URLPair) error {
+		got = append(got, pair)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachURL failed: %v", err)
+	}
+	if len(got) != 2 || !reflect.DeepEqual(got[0], pairs[0]) || !reflect.DeepEqual(got[1], dup) {
+		t.Errorf("Expected [bbb ccc] in order, got %+v", got)
+	}
+}
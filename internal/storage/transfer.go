@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// exportBatchSize — число записей, читаемых за один запрос или транзакцию при экспорте.
+const exportBatchSize = 1000
+
+// Exporter — бэкенд, умеющий потоково перечислять все записи в порядке
+// возрастания короткого ID. after позволяет продолжить прерванный экспорт:
+// перечисляются только записи с ShortURL > after.
+type Exporter interface {
+	ForEachURL(ctx context.Context, after string, fn func(URLPair) error) error
+}
+
+// Importer — бэкенд, принимающий записи с заданными коротким ID, UUID и
+// пользователем. Если запись с тем же коротким ID (или, в бэкендах с
+// уникальностью оригинального URL, с тем же оригинальным URL) уже есть,
+// без overwrite возвращается *ErrConflict, а с overwrite она заменяется.
+type Importer interface {
+	ImportURL(ctx context.Context, pair URLPair, overwrite bool) error
+}
+
+// forEachSorted перечисляет записи карты in-process хранилища по возрастанию
+// ключа. Карта копируется под блокировкой пачками, а fn вызывается без нее.
+func forEachSorted(ctx context.Context, mu *sync.RWMutex, urls *map[string]URLPair, after string, fn func(URLPair) error) error {
+	mu.RLock()
+	keys := make([]string, 0, len(*urls))
+	for key := range *urls {
+		if key > after {
+			keys = append(keys, key)
+		}
+	}
+	mu.RUnlock()
+	sort.Strings(keys)
+
+	batch := make([]URLPair, 0, exportBatchSize)
+	for start := 0; start < len(keys); start += exportBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		mu.RLock()
+		for _, key := range keys[start:min(start+exportBatchSize, len(keys))] {
+			// Записи, удаленные после снятия списка ключей, пропускаются.
+			if pair, ok := (*urls)[key]; ok {
+				batch = append(batch, pair)
+			}
+		}
+		mu.RUnlock()
+		for _, pair := range batch {
+			if err := fn(pair); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Middleware создает серверный спан для каждого запроса. Если запрос содержит
+// заголовок traceparent, спан становится частью входящей трассы.
+// Должен подключаться раньше logger.Middleware, чтобы в логи попадал trace_id.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := Extract(r.Header); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, sc)
+		}
+		ctx, span := Start(ctx, "HTTP "+r.Method,
+			WithKind(SpanKindServer),
+			WithAttributes(
+				String("http.request.method", r.Method),
+				String("url.path", r.URL.Path),
+				String("client.address", r.RemoteAddr),
+			),
+		)
+		defer span.End()
+
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(String("http.route", pattern))
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, http.StatusText(status))
+		}
+	})
+}
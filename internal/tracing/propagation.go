@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader — заголовок W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+const sampledFlag = 0x01
+
+// ParseTraceparent разбирает значение заголовка traceparent версии 00:
+// "00-<trace-id>-<parent-id>-<flags>".
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("traceparent: expected 4 fields, got %d", len(parts))
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid version %q", version)
+	}
+	// Версия 00 допускает ровно 4 поля; будущие версии могут добавлять поля.
+	if version == "00" && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("traceparent: unexpected fields for version 00")
+	}
+
+	var sc SpanContext
+	if len(traceID) != 32 || strings.ToLower(traceID) != traceID {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid trace-id %q", traceID)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid trace-id: %w", err)
+	}
+	if len(spanID) != 16 || strings.ToLower(spanID) != spanID {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid parent-id %q", spanID)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid parent-id: %w", err)
+	}
+	var flagBytes [1]byte
+	if len(flags) != 2 {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid flags %q", flags)
+	}
+	if _, err := hex.Decode(flagBytes[:], []byte(flags)); err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid flags: %w", err)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent: all-zero trace-id or parent-id")
+	}
+	sc.Sampled = flagBytes[0]&sampledFlag != 0
+	return sc, nil
+}
+
+// FormatTraceparent формирует значение заголовка traceparent для sc.
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Extract возвращает контекст удаленного родителя из заголовков запроса.
+func Extract(h http.Header) (SpanContext, bool) {
+	value := h.Get(TraceparentHeader)
+	if value == "" {
+		return SpanContext{}, false
+	}
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Inject записывает контекст спана в заголовки исходящего запроса.
+func Inject(span *Span, h http.Header) {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, FormatTraceparent(sc))
+}
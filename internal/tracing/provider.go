@@ -0,0 +1,219 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Параметры пакетной отправки спанов по умолчанию.
+const (
+	defaultQueueSize     = 2048
+	defaultBatchSize     = 256
+	defaultFlushInterval = 5 * time.Second
+)
+
+// Exporter отправляет завершенные спаны во внешнюю систему.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Provider создает спаны и в фоне пакетами передает завершенные спаны экспортеру.
+// Provider без экспортера создает спаны (их идентификаторы попадают в логи), но не отправляет их.
+type Provider struct {
+	exporter Exporter
+	log      *zap.Logger
+
+	queue    chan SpanData
+	flushReq chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+	dropped  atomic.Int64
+}
+
+var global atomic.Pointer[Provider]
+
+func init() {
+	global.Store(&Provider{})
+}
+
+// SetProvider делает p глобальным провайдером, используемым функцией Start.
+func SetProvider(p *Provider) {
+	global.Store(p)
+}
+
+// NewProvider создает провайдер и запускает фоновую отправку спанов в exporter.
+// При exporter == nil возвращается провайдер, который спаны не экспортирует.
+func NewProvider(exporter Exporter, log *zap.Logger) *Provider {
+	if exporter == nil {
+		return &Provider{}
+	}
+	p := &Provider{
+		exporter: exporter,
+		log:      log,
+		queue:    make(chan SpanData, defaultQueueSize),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Start создает спан name, дочерний по отношению к спану в ctx
+// или к удаленному родителю, извлеченному из входящего запроса.
+func (p *Provider) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	data := SpanData{
+		Name:      name,
+		Kind:      SpanKindInternal,
+		StartTime: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&data)
+	}
+
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.SpanContext()
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = remote
+	}
+
+	if parent.IsValid() {
+		data.SpanContext = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		data.ParentSpanID = parent.SpanID
+	} else {
+		data.SpanContext = SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	}
+
+	span := &Span{data: data}
+	if p.exporter != nil {
+		span.provider = p
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// QueueLen возвращает число спанов, ожидающих отправки.
+func (p *Provider) QueueLen() int {
+	return len(p.queue)
+}
+
+// QueueCap возвращает емкость очереди спанов.
+func (p *Provider) QueueCap() int {
+	return cap(p.queue)
+}
+
+// Dropped возвращает число спанов, отброшенных из-за переполнения очереди.
+func (p *Provider) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// ForceFlush отправляет накопленные спаны и ждет завершения отправки.
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p.exporter == nil {
+		return nil
+	}
+	ack := make(chan struct{})
+	select {
+	case p.flushReq <- ack:
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown отправляет оставшиеся спаны и останавливает экспортер.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.exporter == nil {
+		return nil
+	}
+	p.once.Do(func() { close(p.done) })
+	select {
+	case <-p.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.exporter.Shutdown(ctx)
+}
+
+// Close реализует io.Closer для использования при остановке приложения.
+func (p *Provider) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+func (p *Provider) enqueue(data SpanData) {
+	select {
+	case p.queue <- data:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *Provider) run() {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(defaultFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, defaultBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := p.exporter.ExportSpans(ctx, batch); err != nil && p.log != nil {
+			p.log.Error("Failed to export spans", zap.Error(err), zap.Int("spans", len(batch)))
+		}
+		batch = make([]SpanData, 0, defaultBatchSize)
+	}
+
+	for {
+		select {
+		case data := <-p.queue:
+			batch = append(batch, data)
+			if len(batch) >= defaultBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ack := <-p.flushReq:
+			p.drain(&batch, flush)
+			flush()
+			close(ack)
+		case <-p.done:
+			p.drain(&batch, flush)
+			flush()
+			return
+		}
+	}
+}
+
+// drain переносит все спаны из очереди в пакет, отправляя полные пакеты.
+func (p *Provider) drain(batch *[]SpanData, flush func()) {
+	for {
+		select {
+		case data := <-p.queue:
+			*batch = append(*batch, data)
+			if len(*batch) >= defaultBatchSize {
+				flush()
+			}
+		default:
+			return
+		}
+	}
+}
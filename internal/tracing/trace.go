@@ -0,0 +1,221 @@
+// Package tracing реализует распределенную трассировку в модели OpenTelemetry:
+// спаны с идентификаторами W3C Trace Context, распространение через заголовок
+// traceparent и экспорт в stdout или OTLP/HTTP (JSON).
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID — 16-байтный идентификатор трассы.
+type TraceID [16]byte
+
+// SpanID — 8-байтный идентификатор спана.
+type SpanID [8]byte
+
+// IsValid сообщает, что идентификатор не нулевой.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String возвращает идентификатор в шестнадцатеричном виде.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid сообщает, что идентификатор не нулевой.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// String возвращает идентификатор в шестнадцатеричном виде.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext — неизменяемая часть спана, передаваемая между сервисами.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+// IsValid сообщает, что контекст содержит корректные идентификаторы.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// SpanKind — тип спана по классификации OpenTelemetry.
+type SpanKind int
+
+// Значения совпадают с перечислением SpanKind в OTLP.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode — итоговый статус спана.
+type StatusCode int
+
+// Значения совпадают с перечислением Status.StatusCode в OTLP.
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute — атрибут спана.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String создает строковый атрибут.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int создает целочисленный атрибут.
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Bool создает логический атрибут.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData — снимок завершенного спана, передаваемый экспортеру.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+// Span — выполняемая операция трассы. Методы безопасны для nil-спана,
+// поэтому код может не проверять, включена ли трассировка.
+type Span struct {
+	mu       sync.Mutex
+	data     SpanData
+	ended    atomic.Bool
+	provider *Provider
+}
+
+// SpanContext возвращает контекст спана.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetName переименовывает спан (например, после определения маршрута).
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes добавляет атрибуты спана.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// SetStatus устанавливает итоговый статус спана.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.StatusCode = code
+	s.data.StatusMessage = message
+	s.mu.Unlock()
+}
+
+// RecordError помечает спан как ошибочный, если err не nil.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End завершает спан и передает его экспортеру. Повторные вызовы игнорируются.
+func (s *Span) End() {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+	s.mu.Lock()
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.provider != nil && data.SpanContext.Sampled {
+		s.provider.enqueue(data)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan возвращает контекст, содержащий span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext возвращает текущий спан или nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+type remoteKey struct{}
+
+// ContextWithRemoteSpanContext сохраняет контекст удаленного родителя,
+// полученный из входящего запроса.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// TraceIDFromContext возвращает идентификатор текущей трассы, если он есть.
+func TraceIDFromContext(ctx context.Context) (TraceID, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext().TraceID, true
+	}
+	return TraceID{}, false
+}
+
+// StartOption настраивает создаваемый спан.
+type StartOption func(*SpanData)
+
+// WithKind задает тип спана.
+func WithKind(kind SpanKind) StartOption {
+	return func(d *SpanData) { d.Kind = kind }
+}
+
+// WithAttributes задает начальные атрибуты спана.
+func WithAttributes(attrs ...Attribute) StartOption {
+	return func(d *SpanData) { d.Attributes = append(d.Attributes, attrs...) }
+}
+
+// Start создает дочерний спан текущего спана контекста (или удаленного родителя)
+// с помощью глобального провайдера.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	return global.Load().Start(ctx, name, opts...)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,132 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"shorturl/internal/tracing"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+		sampled bool
+	}{
+		{"Valid sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"Valid not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"Zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true, false},
+		{"Uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true, false},
+		{"Forbidden version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"Too short", "00-4bf92f35-00f067aa0ba902b7-01", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := tracing.ParseTraceparent(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("Expected sampled %v, got %v", tt.sampled, sc.Sampled)
+			}
+			if got := tracing.FormatTraceparent(sc); got != tt.value {
+				t.Errorf("Round trip mismatch: %s != %s", got, tt.value)
+			}
+		})
+	}
+}
+
+// TestMiddlewarePropagation проверяет, что серверный и дочерние спаны
+// продолжают трассу из входящего заголовка traceparent.
+func TestMiddlewarePropagation(t *testing.T) {
+	var buf bytes.Buffer
+	provider := tracing.NewProvider(tracing.NewStdoutExporter(&buf), nil)
+	tracing.SetProvider(provider)
+	defer tracing.SetProvider(tracing.NewProvider(nil, nil))
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Get("/{shortID}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "child")
+		span.End()
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/abcdefgh", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	spans := map[string]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var span map[string]any
+		if err := json.Unmarshal([]byte(line), &span); err != nil {
+			t.Fatalf("Failed to decode span: %v", err)
+		}
+		spans[span["name"].(string)] = span
+	}
+
+	server, ok := spans["GET /{shortID}"]
+	if !ok {
+		t.Fatalf("Server span not exported: %v", spans)
+	}
+	child, ok := spans["child"]
+	if !ok {
+		t.Fatalf("Child span not exported: %v", spans)
+	}
+	if server["trace_id"] != traceID || child["trace_id"] != traceID {
+		t.Errorf("Spans do not continue incoming trace")
+	}
+	if server["parent_span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("Server span parent mismatch: %v", server["parent_span_id"])
+	}
+	if child["parent_span_id"] != server["span_id"] {
+		t.Errorf("Child span is not a child of server span")
+	}
+}
+
+// TestOTLPExporter проверяет формат запроса OTLP/HTTP JSON.
+func TestOTLPExporter(t *testing.T) {
+	var got map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	provider := tracing.NewProvider(tracing.NewOTLPExporter(collector.URL, "shorturl-test"), nil)
+	_, span := provider.Start(context.Background(), "op", tracing.WithAttributes(tracing.Int("n", 1)))
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	resourceSpans, _ := got["resourceSpans"].([]any)
+	if len(resourceSpans) != 1 {
+		t.Fatalf("Expected one resourceSpans entry, got %v", got)
+	}
+	scopeSpans := resourceSpans[0].(map[string]any)["scopeSpans"].([]any)
+	spans := scopeSpans[0].(map[string]any)["spans"].([]any)
+	if len(spans) != 1 || spans[0].(map[string]any)["name"] != "op" {
+		t.Errorf("Unexpected spans: %v", spans)
+	}
+}
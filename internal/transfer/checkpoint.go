@@ -0,0 +1,68 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// checkpoint — прогресс переноса, сохраняемый рядом с файлом выгрузки.
+// Offset — позиция в файле после последней записанной (или загруженной) записи,
+// Last — короткий ID последней выгруженной записи.
+type checkpoint struct {
+	Offset   int64  `json:"offset"`
+	Records  int    `json:"records"`
+	Imported int    `json:"imported,omitempty"`
+	Skipped  int    `json:"skipped,omitempty"`
+	Last     string `json:"last_short_url,omitempty"`
+}
+
+func (c checkpoint) stats() Stats {
+	return Stats{Records: c.Records, Imported: c.Imported, Skipped: c.Skipped}
+}
+
+// CheckpointPath возвращает путь файла прогресса для файла выгрузки path.
+func CheckpointPath(path string) string {
+	return path + ".progress"
+}
+
+// loadCheckpoint читает контрольную точку; ok=false, если ее нет.
+func loadCheckpoint(path string) (checkpoint, bool, error) {
+	var cp checkpoint
+	data, err := os.ReadFile(CheckpointPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, false, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return cp, true, nil
+}
+
+// saveCheckpoint атомарно заменяет файл прогресса.
+func saveCheckpoint(path string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	target := CheckpointPath(path)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func removeCheckpoint(path string) error {
+	if err := os.Remove(CheckpointPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
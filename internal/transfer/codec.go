@@ -0,0 +1,227 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"shorturl/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format — формат файла выгрузки.
+type Format string
+
+// Поддерживаемые форматы: JSON Lines (по объекту URLPair на строку) и CSV
+// с заголовком id,short_url,original_url,user_id,created_at,title,tags,notes,folder,password_hash,
+// max_clicks,clicks_left,not_before,not_after (теги через запятую, время — RFC 3339).
+// При импорте колонки сопоставляются по именам.
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCSV   Format = "csv"
+)
+
+// csvHeader — колонки CSV в порядке записи.
+var csvHeader = []string{"id", "short_url", "original_url", "user_id", "created_at", "title", "tags", "notes", "folder",
+	"password_hash", "max_clicks", "clicks_left", "not_before", "not_after"}
+
+// ParseFormat разбирает имя формата.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSONL, FormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (expected jsonl or csv)", s)
+	}
+}
+
+// encoder пишет записи в выбранном формате. Flush сбрасывает буфер в нижележащий writer.
+type encoder interface {
+	Encode(pair storage.URLPair) error
+	Flush() error
+}
+
+// newEncoder создает encoder; header управляет записью заголовка CSV
+// (при продолжении выгрузки он уже есть в файле).
+func newEncoder(w io.Writer, format Format, header bool) (encoder, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if header {
+			if err := cw.Write(csvHeader); err != nil {
+				return nil, err
+			}
+		}
+		return &csvEncoder{w: cw}, nil
+	default:
+		bw := bufio.NewWriter(w)
+		return &jsonlEncoder{w: bw, enc: json.NewEncoder(bw)}, nil
+	}
+}
+
+type jsonlEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(pair storage.URLPair) error { return e.enc.Encode(pair) }
+func (e *jsonlEncoder) Flush() error                      { return e.w.Flush() }
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(pair storage.URLPair) error {
+	var maxClicks, clicksLeft string
+	if pair.MaxClicks > 0 {
+		maxClicks, clicksLeft = strconv.Itoa(pair.MaxClicks), strconv.Itoa(pair.ClicksLeft)
+	}
+	return e.w.Write([]string{pair.UUID, pair.ShortURL, pair.OriginalURL, pair.UserID, formatTime(pair.CreatedAt),
+		pair.Title, strings.Join(pair.Tags, ","), pair.Notes, pair.Folder, pair.PasswordHash, maxClicks, clicksLeft,
+		formatTime(pair.NotBefore), formatTime(pair.NotAfter)})
+}
+
+// formatTime записывает время в формате RFC 3339; нулевое время — пустая строка.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// decoder читает записи. Offset — число байт входа, занятых прочитанными записями.
+type decoder interface {
+	Decode() (storage.URLPair, error)
+	Offset() int64
+}
+
+func newDecoder(r io.Reader, format Format) (decoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVDecoder(r)
+	default:
+		return &jsonlDecoder{r: bufio.NewReader(r)}, nil
+	}
+}
+
+type jsonlDecoder struct {
+	r      *bufio.Reader
+	offset int64
+	line   int
+}
+
+func (d *jsonlDecoder) Decode() (storage.URLPair, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return storage.URLPair{}, err
+		}
+		if len(line) == 0 && errors.Is(err, io.EOF) {
+			return storage.URLPair{}, io.EOF
+		}
+		d.offset += int64(len(line))
+		d.line++
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+		var pair storage.URLPair
+		if err := json.Unmarshal(line, &pair); err != nil {
+			return storage.URLPair{}, fmt.Errorf("line %d: %w", d.line, err)
+		}
+		return pair, nil
+	}
+}
+
+func (d *jsonlDecoder) Offset() int64 { return d.offset }
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// newCSVDecoder читает заголовок и сопоставляет колонки по именам, поэтому
+// порядок колонок во входном файле может быть любым.
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{"short_url", "original_url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header has no %q column", required)
+		}
+	}
+	return &csvDecoder{r: cr, columns: columns}, nil
+}
+
+func (d *csvDecoder) Decode() (storage.URLPair, error) {
+	record, err := d.r.Read()
+	if err != nil {
+		return storage.URLPair{}, err
+	}
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	pair := storage.URLPair{
+		UUID:        field("id"),
+		ShortURL:    field("short_url"),
+		OriginalURL: field("original_url"),
+		UserID:      field("user_id"),
+		// Без хэша пароля защищенная ссылка после переноса открывалась бы без пароля.
+		PasswordHash: field("password_hash"),
+		Metadata: storage.Metadata{
+			Title:  field("title"),
+			Tags:   storage.NormalizeTags(strings.Split(field("tags"), ",")),
+			Notes:  field("notes"),
+			Folder: field("folder"),
+		},
+	}
+	for _, col := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_at", &pair.CreatedAt}, {"not_before", &pair.NotBefore}, {"not_after", &pair.NotAfter}} {
+		value := field(col.name)
+		if value == "" {
+			continue
+		}
+		if *col.dst, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			line, _ := d.r.FieldPos(0)
+			return storage.URLPair{}, fmt.Errorf("line %d: invalid %s: %w", line, col.name, err)
+		}
+	}
+	// Остаток переносится вместе с ограничением, иначе исчерпанная ссылка снова открылась бы.
+	for _, col := range []struct {
+		name string
+		dst  *int
+	}{{"max_clicks", &pair.MaxClicks}, {"clicks_left", &pair.ClicksLeft}} {
+		value := field(col.name)
+		if value == "" {
+			continue
+		}
+		if *col.dst, err = strconv.Atoi(value); err != nil || *col.dst < 0 {
+			line, _ := d.r.FieldPos(0)
+			return storage.URLPair{}, fmt.Errorf("line %d: invalid %s %q", line, col.name, value)
+		}
+	}
+	return pair, nil
+}
+
+func (d *csvDecoder) Offset() int64 { return d.r.InputOffset() }
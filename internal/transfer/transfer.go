@@ -0,0 +1,324 @@
+// Package transfer переносит ссылки между хранилищами через файлы выгрузки
+// в форматах JSON Lines и CSV.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"shorturl/internal/storage"
+	"strings"
+)
+
+// DefaultCheckpointEvery — через сколько записей сохраняется прогресс.
+const DefaultCheckpointEvery = 1000
+
+// ConflictMode определяет реакцию импорта на уже существующую запись.
+type ConflictMode string
+
+const (
+	ConflictSkip      ConflictMode = "skip"      // оставить существующую запись
+	ConflictOverwrite ConflictMode = "overwrite" // заменить импортируемой
+	ConflictFail      ConflictMode = "fail"      // прервать импорт
+)
+
+// ParseConflictMode разбирает режим разрешения конфликтов.
+func ParseConflictMode(s string) (ConflictMode, error) {
+	switch m := ConflictMode(strings.ToLower(s)); m {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown conflict mode %q (expected skip, overwrite or fail)", s)
+	}
+}
+
+// Stats — счетчики переноса. Records — обработанные записи, Imported и
+// Skipped заполняются только при импорте.
+type Stats struct {
+	Records  int
+	Imported int
+	Skipped  int
+}
+
+// Options — параметры экспорта и импорта.
+type Options struct {
+	Format   Format
+	Conflict ConflictMode // только для импорта; по умолчанию fail
+
+	// Resume продолжает прерванный перенос с последней контрольной точки.
+	// Доступен только для файлов, не для stdin/stdout.
+	Resume bool
+	// CheckpointEvery — период сохранения прогресса в записях.
+	CheckpointEvery int
+	// Progress вызывается на каждой контрольной точке и по завершении.
+	Progress func(Stats)
+}
+
+func (o Options) checkpointEvery() int {
+	if o.CheckpointEvery > 0 {
+		return o.CheckpointEvery
+	}
+	return DefaultCheckpointEvery
+}
+
+func (o Options) progress(stats Stats) {
+	if o.Progress != nil {
+		o.Progress(stats)
+	}
+}
+
+// Export выгружает все записи src в w.
+func Export(ctx context.Context, src storage.Exporter, w io.Writer, opts Options) (Stats, error) {
+	if opts.Resume {
+		return Stats{}, errors.New("resume requires an output file")
+	}
+	enc, err := newEncoder(w, opts.Format, true)
+	if err != nil {
+		return Stats{}, err
+	}
+	var stats Stats
+	err = export(ctx, src, enc, "", &stats, opts, func(string) error { return nil })
+	return stats, err
+}
+
+// ExportFile выгружает все записи src в файл path. С opts.Resume выгрузка
+// продолжается с контрольной точки: файл обрезается до последней
+// сохраненной позиции, а чтение хранилища начинается после записанного ID.
+func ExportFile(ctx context.Context, src storage.Exporter, path string, opts Options) (Stats, error) {
+	var cp checkpoint
+	var resumed bool
+	if opts.Resume {
+		var err error
+		if cp, resumed, err = loadCheckpoint(path); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if !resumed {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer f.Close()
+	if resumed {
+		if err := f.Truncate(cp.Offset); err != nil {
+			return Stats{}, fmt.Errorf("failed to truncate output file: %w", err)
+		}
+		if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+			return Stats{}, fmt.Errorf("failed to seek output file: %w", err)
+		}
+	}
+
+	cw := &countingWriter{w: f, n: cp.Offset}
+	enc, err := newEncoder(cw, opts.Format, !resumed)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := cp.stats()
+	save := func(last string) error {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		return saveCheckpoint(path, checkpoint{
+			Offset:  cw.n,
+			Records: stats.Records,
+			Last:    last,
+		})
+	}
+
+	if err := export(ctx, src, enc, cp.Last, &stats, opts, save); err != nil {
+		return stats, err
+	}
+	if err := f.Sync(); err != nil {
+		return stats, fmt.Errorf("failed to sync output file: %w", err)
+	}
+	return stats, removeCheckpoint(path)
+}
+
+// export пишет записи в enc, вызывая save каждые CheckpointEvery записей и
+// при ошибке, чтобы прерванную выгрузку можно было продолжить.
+func export(ctx context.Context, src storage.Exporter, enc encoder, after string, stats *Stats, opts Options, save func(last string) error) error {
+	every := opts.checkpointEvery()
+	last := after
+	err := src.ForEachURL(ctx, after, func(pair storage.URLPair) error {
+		if err := enc.Encode(pair); err != nil {
+			return err
+		}
+		last = pair.ShortURL
+		stats.Records++
+		if stats.Records%every == 0 {
+			if err := save(last); err != nil {
+				return err
+			}
+			opts.progress(*stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("export failed after %d records: %w", stats.Records, err), save(last))
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	opts.progress(*stats)
+	return nil
+}
+
+// Import загружает записи из r в dst.
+func Import(ctx context.Context, dst storage.Importer, r io.Reader, opts Options) (Stats, error) {
+	if opts.Resume {
+		return Stats{}, errors.New("resume requires an input file")
+	}
+	dec, err := newDecoder(r, opts.Format)
+	if err != nil {
+		return Stats{}, err
+	}
+	var stats Stats
+	err = importRecords(ctx, dst, dec, &stats, opts, func(int64) error { return nil })
+	return stats, err
+}
+
+// ImportFile загружает записи из файла path. С opts.Resume чтение начинается
+// с позиции последней контрольной точки.
+func ImportFile(ctx context.Context, dst storage.Importer, path string, opts Options) (Stats, error) {
+	var cp checkpoint
+	var resumed bool
+	if opts.Resume {
+		var err error
+		if cp, resumed, err = loadCheckpoint(path); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	var base int64
+	if resumed {
+		r, base, err = seekInput(f, cp.Offset, opts.Format)
+		if err != nil {
+			return Stats{}, err
+		}
+	}
+	dec, err := newDecoder(r, opts.Format)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := cp.stats()
+	save := func(offset int64) error {
+		return saveCheckpoint(path, checkpoint{
+			Offset:   base + offset,
+			Records:  stats.Records,
+			Imported: stats.Imported,
+			Skipped:  stats.Skipped,
+		})
+	}
+
+	if err := importRecords(ctx, dst, dec, &stats, opts, save); err != nil {
+		return stats, err
+	}
+	return stats, removeCheckpoint(path)
+}
+
+// seekInput переходит к позиции offset входного файла. Для CSV перед
+// продолжением заново подставляется заголовок, а base компенсирует его длину,
+// чтобы смещения декодера оставались абсолютными.
+func seekInput(f *os.File, offset int64, format Format) (io.Reader, int64, error) {
+	var header []byte
+	if format == FormatCSV {
+		dec, err := newCSVDecoder(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		if header, err = readPrefix(f, dec.Offset()); err != nil {
+			return nil, 0, err
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to seek input file: %w", err)
+	}
+	if len(header) == 0 {
+		return f, offset, nil
+	}
+	return io.MultiReader(strings.NewReader(string(header)), f), offset - int64(len(header)), nil
+}
+
+// readPrefix читает первые n байт файла.
+func readPrefix(f *os.File, n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("failed to read input header: %w", err)
+	}
+	return buf, nil
+}
+
+// importRecords загружает записи из dec. save получает смещение входа после
+// последней полностью обработанной записи.
+func importRecords(ctx context.Context, dst storage.Importer, dec decoder, stats *Stats, opts Options, save func(offset int64) error) error {
+	every := opts.checkpointEvery()
+	var done int64
+	fail := func(err error) error {
+		return errors.Join(fmt.Errorf("import failed after %d records: %w", stats.Records, err), save(done))
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		pair, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if pair.ShortURL == "" || pair.OriginalURL == "" {
+			return fail(fmt.Errorf("record %d: short_url and original_url are required", stats.Records+1))
+		}
+
+		err = dst.ImportURL(ctx, pair, opts.Conflict == ConflictOverwrite)
+		var conflictErr *storage.ErrConflict
+		switch {
+		case err == nil:
+			stats.Imported++
+		case errors.As(err, &conflictErr) && opts.Conflict == ConflictSkip:
+			stats.Skipped++
+		case errors.As(err, &conflictErr):
+			return fail(fmt.Errorf("record %d (%s): %w", stats.Records+1, pair.ShortURL, err))
+		default:
+			return fail(err)
+		}
+		stats.Records++
+		done = dec.Offset()
+
+		if stats.Records%every == 0 {
+			if err := save(done); err != nil {
+				return err
+			}
+			opts.progress(*stats)
+		}
+	}
+	opts.progress(*stats)
+	return nil
+}
+
+// countingWriter считает байты, переданные нижележащему writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
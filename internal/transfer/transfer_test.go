@@ -0,0 +1,240 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"shorturl/internal/storage"
+	"shorturl/internal/transfer"
+	"testing"
+	"time"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+func seed(t *testing.T, n int) *storage.InMemoryStorage {
+	t.Helper()
+	s := storage.NewInMemoryStorage()
+	for i := 0; i < n; i++ {
+		pair := storage.URLPair{
+			UUID:        fmt.Sprintf("uuid-%03d", i),
+			ShortURL:    fmt.Sprintf("id%03d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d?q=a,b", i),
+			UserID:      fmt.Sprintf("user-%d", i%3),
+		}
+		// У части записей время создания неизвестно, как у ссылок из старых версий.
+		if i%2 == 0 {
+			pair.CreatedAt = time.Date(2025, 1, 1, 0, 0, i, 1000, time.UTC)
+		}
+		if i%3 == 0 {
+			pair.Metadata = storage.Metadata{
+				Title:  fmt.Sprintf("Page %d, \"quoted\"", i),
+				Tags:   []string{"promo", fmt.Sprintf("batch-%d", i/10)},
+				Notes:  "first line\nsecond line",
+				Folder: "campaigns/2025",
+			}
+		}
+		if i%5 == 0 {
+			pair.PasswordHash = fmt.Sprintf("$2a$10$hash%03d", i)
+		}
+		if i%7 == 0 {
+			pair.MaxClicks, pair.ClicksLeft = 10, i%10
+		}
+		if i%6 == 0 {
+			pair.NotBefore = pair.CreatedAt.Add(time.Hour)
+			pair.NotAfter = pair.NotBefore.Add(24 * time.Hour)
+		}
+		if err := s.ImportURL(context.Background(), pair, false); err != nil {
+			t.Fatalf("Failed to seed storage: %v", err)
+		}
+	}
+	return s
+}
+
+func dump(t *testing.T, s storage.Exporter) []storage.URLPair {
+	t.Helper()
+	var pairs []storage.URLPair
+	err := s.ForEachURL(context.Background(), "", func(pair storage.URLPair) error {
+		pairs = append(pairs, pair)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachURL failed: %v", err)
+	}
+	return pairs
+}
+
+func assertSameRecords(t *testing.T, want, got []storage.URLPair) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Fatalf("Expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(want[i], got[i]) {
+			t.Fatalf("Record %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestRoundTripBetweenBackends(t *testing.T) {
+	ctx := context.Background()
+	src := seed(t, 25)
+
+	for _, format := range []transfer.Format{transfer.FormatJSONL, transfer.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "urls."+string(format))
+			opts := transfer.Options{Format: format, CheckpointEvery: 10}
+
+			stats, err := transfer.ExportFile(ctx, src, path, opts)
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if stats.Records != 25 {
+				t.Errorf("Expected 25 exported records, got %d", stats.Records)
+			}
+
+			dst, err := storage.NewBoltStorage(filepath.Join(dir, "urls.db"))
+			if err != nil {
+				t.Fatalf("Failed to open bolt storage: %v", err)
+			}
+			defer dst.Close()
+			if stats, err = transfer.ImportFile(ctx, dst, path, opts); err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if stats.Imported != 25 {
+				t.Errorf("Expected 25 imported records, got %d", stats.Imported)
+			}
+			assertSameRecords(t, dump(t, src), dump(t, dst))
+
+			if urls, _ := dst.GetURLsByUserID(ctx, "user-1"); len(urls) != 8 {
+				t.Errorf("Expected 8 URLs for user-1, got %d", len(urls))
+			}
+			if _, err := os.Stat(transfer.CheckpointPath(path)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected checkpoint to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestImportConflictModes(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if _, err := transfer.Export(ctx, seed(t, 3), &buf, transfer.Options{Format: transfer.FormatJSONL}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	existing := storage.URLPair{ShortURL: "id001", OriginalURL: "https://example.com/existing", UserID: "owner"}
+
+	tests := []struct {
+		mode         transfer.ConflictMode
+		wantErr      bool
+		wantImported int
+		wantSkipped  int
+		wantOriginal string
+	}{
+		{transfer.ConflictSkip, false, 2, 1, existing.OriginalURL},
+		{transfer.ConflictOverwrite, false, 3, 0, "https://example.com/1?q=a,b"},
+		{transfer.ConflictFail, true, 1, 0, existing.OriginalURL},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			dst := storage.NewInMemoryStorage()
+			_ = dst.ImportURL(ctx, existing, false)
+
+			opts := transfer.Options{Format: transfer.FormatJSONL, Conflict: tt.mode}
+			stats, err := transfer.Import(ctx, dst, bytes.NewReader(buf.Bytes()), opts)
+			var conflictErr *storage.ErrConflict
+			if tt.wantErr != errors.As(err, &conflictErr) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if stats.Imported != tt.wantImported || stats.Skipped != tt.wantSkipped {
+				t.Errorf("Expected %d imported and %d skipped, got %+v", tt.wantImported, tt.wantSkipped, stats)
+			}
+			if original, _ := dst.GetOriginalURL(ctx, "id001"); original != tt.wantOriginal {
+				t.Errorf("Expected id001 -> %q, got %q", tt.wantOriginal, original)
+			}
+		})
+	}
+}
+
+// flakyExporter прерывает выгрузку после limit записей.
+type flakyExporter struct {
+	storage.Exporter
+	limit int
+}
+
+func (e flakyExporter) ForEachURL(ctx context.Context, after string, fn func(storage.URLPair) error) error {
+	n := 0
+	return e.Exporter.ForEachURL(ctx, after, func(pair storage.URLPair) error {
+		if n == e.limit {
+			return errInterrupted
+		}
+		n++
+		return fn(pair)
+	})
+}
+
+// flakyImporter отказывает после limit записей.
+type flakyImporter struct {
+	storage.Importer
+	limit *int
+}
+
+func (i flakyImporter) ImportURL(ctx context.Context, pair storage.URLPair, overwrite bool) error {
+	if *i.limit == 0 {
+		return errInterrupted
+	}
+	*i.limit--
+	return i.Importer.ImportURL(ctx, pair, overwrite)
+}
+
+func TestResumeAfterInterruption(t *testing.T) {
+	ctx := context.Background()
+	src := seed(t, 25)
+
+	for _, format := range []transfer.Format{transfer.FormatJSONL, transfer.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "urls."+string(format))
+			opts := transfer.Options{Format: format, CheckpointEvery: 10}
+
+			if _, err := transfer.ExportFile(ctx, flakyExporter{src, 13}, path, opts); !errors.Is(err, errInterrupted) {
+				t.Fatalf("Expected interrupted export, got %v", err)
+			}
+			opts.Resume = true
+			stats, err := transfer.ExportFile(ctx, src, path, opts)
+			if err != nil {
+				t.Fatalf("Resumed export failed: %v", err)
+			}
+			if stats.Records != 25 {
+				t.Errorf("Expected 25 records after resume, got %d", stats.Records)
+			}
+
+			dst := storage.NewInMemoryStorage()
+			limit := 17
+			opts.Resume = false
+			if _, err := transfer.ImportFile(ctx, flakyImporter{dst, &limit}, path, opts); !errors.Is(err, errInterrupted) {
+				t.Fatalf("Expected interrupted import, got %v", err)
+			}
+			// Без -resume повторный импорт упал бы на конфликте уже загруженных записей.
+			opts.Resume = true
+			if stats, err = transfer.ImportFile(ctx, dst, path, opts); err != nil {
+				t.Fatalf("Resumed import failed: %v", err)
+			}
+			if stats.Records != 25 || stats.Imported != 25 {
+				t.Errorf("Expected 25 imported records after resume, got %+v", stats)
+			}
+			assertSameRecords(t, dump(t, src), dump(t, dst))
+		})
+	}
+}
+
+func TestResumeRequiresFile(t *testing.T) {
+	opts := transfer.Options{Format: transfer.FormatJSONL, Resume: true}
+	if _, err := transfer.Export(context.Background(), storage.NewInMemoryStorage(), &bytes.Buffer{}, opts); err == nil {
+		t.Error("Expected error for resumed export to a stream")
+	}
+}